@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type Profile struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Name      *string   `json:"name,omitempty"`
+	AvatarURL *string   `json:"avatar_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Profile returns the user the token belongs to.
+func (c *Client) Profile(ctx context.Context) (*Profile, error) {
+	var p Profile
+	if err := c.do(ctx, http.MethodGet, "/api/profile", nil, nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+type TestEndpointRequest struct {
+	Endpoint string            `json:"endpoint"`
+	Method   string            `json:"method"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     *string           `json:"body,omitempty"`
+
+	Transport           *Transport `json:"transport,omitempty"`
+	ClientCertificateID string     `json:"client_certificate_id,omitempty"`
+	Auth                *Auth      `json:"auth,omitempty"`
+	Redirects           *Redirects `json:"redirects,omitempty"`
+}
+
+// TestEndpointResult is the target's response as relayed by the server.
+// Body holds the response JSON, or a JSON string if it was not JSON.
+type TestEndpointResult struct {
+	Status     int               `json:"status"`
+	StatusText string            `json:"status_text"`
+	Headers    map[string]string `json:"headers"`
+	Body       json.RawMessage   `json:"body"`
+	Redirects  []Hop             `json:"redirects,omitempty"` // set if the request was redirected
+}
+
+// TestEndpoint has the server call an endpoint once without saving a job.
+// A target that cannot be reached is reported as an error matching
+// ErrUpstream; a reachable target with a non-2xx status is not an error.
+func (c *Client) TestEndpoint(ctx context.Context, req TestEndpointRequest) (*TestEndpointResult, error) {
+	var res TestEndpointResult
+	if err := c.do(ctx, http.MethodPost, "/api/jobs/test", nil, req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CleanupLogs prunes the run history of every job down to the newest entries.
+func (c *Client) CleanupLogs(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/jobs/cleanup-logs", nil, nil, nil)
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Certificate is a client certificate presented by jobs to endpoints that
+// require mutual TLS. The server never returns the private key.
+type Certificate struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Subject     string    `json:"subject"`
+	Fingerprint string    `json:"fingerprint"` // hex SHA-256 of the leaf
+	NotAfter    time.Time `json:"not_after"`
+	CreatedAt   time.Time `json:"created_at"`
+	Certificate string    `json:"certificate"` // PEM
+	HasCABundle bool      `json:"has_ca_bundle"`
+}
+
+// CreateCertificateRequest is the body of POST /api/certificates. All
+// values are PEM encoded.
+type CreateCertificateRequest struct {
+	Name        string  `json:"name"`
+	Certificate string  `json:"certificate"`
+	PrivateKey  string  `json:"private_key"`
+	CABundle    *string `json:"ca_bundle,omitempty"`
+}
+
+func (c *Client) ListCertificates(ctx context.Context) ([]Certificate, error) {
+	var certs []Certificate
+	err := c.do(ctx, http.MethodGet, "/api/certificates", nil, nil, &certs)
+	return certs, err
+}
+
+func (c *Client) CreateCertificate(ctx context.Context, req CreateCertificateRequest) (*Certificate, error) {
+	var cert Certificate
+	if err := c.do(ctx, http.MethodPost, "/api/certificates", nil, req, &cert); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// DeleteCertificate fails with ErrConflict while jobs still use the certificate.
+func (c *Client) DeleteCertificate(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/certificates/"+url.PathEscape(id), nil, nil, nil)
+}
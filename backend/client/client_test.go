@@ -0,0 +1,284 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"cronix.ashutosh.net/client"
+	"cronix.ashutosh.net/internals/config"
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/netguard"
+	"cronix.ashutosh.net/internals/outbound"
+	"cronix.ashutosh.net/internals/router"
+	"cronix.ashutosh.net/internals/services"
+	"cronix.ashutosh.net/internals/store/memory"
+)
+
+// server is the real API on an in-memory store. Jobs may call loopback
+// addresses, so targets can be httptest servers; the rest of the internal
+// ranges stay blocked.
+type server struct {
+	url   string
+	token string
+	sched *services.Scheduler
+}
+
+func newServer(t *testing.T) server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+
+	st := memory.New()
+	guard, err := netguard.New([]string{"127.0.0.0/8", "::1/128"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob, err := outbound.New(outbound.Options{}, guard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := services.NewAuthService(st, "test-secret")
+	certs := services.NewCertificatesService(st, nil, ob)
+	jobs := services.NewJobsService(st, services.JobsSettings{
+		TestTimeout:      5 * time.Second,
+		MaxResponseBytes: 1 << 20,
+		LogsPerJob:       10,
+		Outbound:         ob,
+		Certificates:     certs,
+	})
+	sched := services.NewScheduler(jobs, 5*time.Second)
+	srv := httptest.NewServer(router.New(router.Deps{
+		Auth:           auth,
+		AuthConfig:     &config.AuthConfig{},
+		AuthHTTPClient: http.DefaultClient,
+		Jobs:           jobs,
+		Certificates:   certs,
+		Scheduler:      sched,
+		Driver:         "memory",
+		AllowedOrigins: []string{"http://localhost:3000"},
+	}))
+	t.Cleanup(srv.Close)
+
+	user, err := st.CreateUser(ctx, db.CreateUserParams{Email: "dev@example.com", Provider: "google"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.GenerateJWT(user.ID.String(), user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server{url: srv.URL, token: token, sched: sched}
+}
+
+// target answers every request with 200.
+func target(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestJobRoundTrip(t *testing.T) {
+	s := newServer(t)
+	c := client.New(s.url, s.token)
+	ctx := context.Background()
+
+	follow := false
+	created, err := c.CreateJob(ctx, client.CreateJobRequest{
+		Name:      "refresh",
+		Schedule:  "0 */5 * * * *",
+		Endpoint:  target(t) + "/hook",
+		Method:    "POST",
+		Headers:   map[string]string{"X-Team": "infra"},
+		Transport: &client.Transport{TLSMinVersion: "1.2"},
+		Redirects: &client.Redirects{Follow: &follow},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Headers, transport and redirects come back as base64 JSONB
+	job, err := c.GetJob(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Headers["X-Team"] != "infra" {
+		t.Errorf("headers = %v", job.Headers)
+	}
+	if job.Transport.TLSMinVersion != "1.2" {
+		t.Errorf("transport = %+v", job.Transport)
+	}
+	if job.Redirects.Follow == nil || *job.Redirects.Follow {
+		t.Errorf("redirects = %+v", job.Redirects)
+	}
+	if job.Type != client.TypeHTTP || job.Config != nil {
+		t.Errorf("type = %q, config = %v", job.Type, job.Config)
+	}
+
+	next, err := c.CreateJob(ctx, client.CreateJobRequest{
+		Name:     "after",
+		Schedule: "@daily",
+		Endpoint: target(t),
+		Method:   "GET",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	triggers := client.Triggers{OnSuccess: []string{next.ID}, PassBody: true}
+	updated, err := c.UpdateJob(ctx, job.ID, client.UpdateJobRequest{Triggers: &triggers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Triggers.OnSuccess) != 1 || updated.Triggers.OnSuccess[0] != next.ID || !updated.Triggers.PassBody {
+		t.Errorf("triggers = %+v", updated.Triggers)
+	}
+
+	log, err := c.RunJob(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.Status != "success" || log.ResponseCode == nil || *log.ResponseCode != 200 {
+		t.Errorf("run = %+v", log)
+	}
+	if log.RunGroup == nil || *log.RunGroup == "" {
+		t.Error("run has no run group")
+	}
+}
+
+// An update that leaves Active out keeps the job active or paused as it
+// was.
+func TestUpdateKeepsActive(t *testing.T) {
+	s := newServer(t)
+	c := client.New(s.url, s.token)
+	ctx := context.Background()
+
+	job, err := c.CreateJob(ctx, client.CreateJobRequest{
+		Name: "kept", Schedule: "@daily", Endpoint: target(t), Method: "GET", Active: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := func(req client.UpdateJobRequest, active bool, scheduled int) {
+		t.Helper()
+		updated, err := c.UpdateJob(ctx, job.ID, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Active != active {
+			t.Errorf("active = %v, want %v", updated.Active, active)
+		}
+		if n := s.sched.Len(); n != scheduled {
+			t.Errorf("scheduled %d jobs, want %d", n, scheduled)
+		}
+	}
+	name := "renamed"
+	update(client.UpdateJobRequest{Name: &name}, true, 1)
+	update(client.UpdateJobRequest{Triggers: &client.Triggers{}}, true, 1)
+
+	paused := false
+	update(client.UpdateJobRequest{Active: &paused}, false, 0)
+	update(client.UpdateJobRequest{Name: &name}, false, 0)
+}
+
+// Headers are replaced as a whole, so an empty map clears them.
+func TestUpdateClearsHeaders(t *testing.T) {
+	s := newServer(t)
+	c := client.New(s.url, s.token)
+	ctx := context.Background()
+
+	job, err := c.CreateJob(ctx, client.CreateJobRequest{
+		Name: "headers", Schedule: "@daily", Endpoint: target(t), Method: "GET",
+		Headers: map[string]string{"X-Team": "infra"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "renamed"
+	updated, err := c.UpdateJob(ctx, job.ID, client.UpdateJobRequest{Name: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Headers["X-Team"] != "infra" {
+		t.Errorf("headers after an update without them = %v", updated.Headers)
+	}
+	updated, err = c.UpdateJob(ctx, job.ID, client.UpdateJobRequest{Headers: &map[string]string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Headers) != 0 {
+		t.Errorf("headers after clearing = %v", updated.Headers)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := newServer(t)
+	c := client.New(s.url, s.token)
+	ctx := context.Background()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	job, err := c.CreateJob(ctx, client.CreateJobRequest{Name: "existing", Schedule: "@daily", Endpoint: target(t), Method: "GET"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	internal := "http://10.1.2.3/"
+
+	tests := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"missing job", func() error {
+			_, err := c.GetJob(ctx, "7a0f9b3e-2c1d-4e5f-8a9b-0c1d2e3f4a5b")
+			return err
+		}, client.ErrNotFound},
+		{"malformed id", func() error {
+			_, err := c.GetJob(ctx, "nope")
+			return err
+		}, client.ErrBadRequest},
+		{"no token", func() error {
+			_, err := client.New(s.url, "").ListJobs(ctx, 10, 0)
+			return err
+		}, client.ErrUnauthorized},
+		{"unknown type", func() error {
+			_, err := c.CreateJob(ctx, client.CreateJobRequest{Name: "x", Schedule: "@daily", Endpoint: target(t), Type: "smtp"})
+			return err
+		}, client.ErrBadRequest},
+		{"unreachable endpoint", func() error {
+			_, err := c.CreateJob(ctx, client.CreateJobRequest{Name: "x", Schedule: "@daily", Endpoint: closed.URL, Method: "GET"})
+			return err
+		}, client.ErrEndpointTest},
+		{"internal endpoint", func() error {
+			_, err := c.TestEndpoint(ctx, client.TestEndpointRequest{Endpoint: internal, Method: "GET"})
+			return err
+		}, client.ErrEndpointNotAllowed},
+		{"creating with an internal endpoint", func() error {
+			_, err := c.CreateJob(ctx, client.CreateJobRequest{Name: "x", Schedule: "@daily", Endpoint: internal, Method: "GET"})
+			return err
+		}, client.ErrEndpointNotAllowed},
+		{"updating to an internal endpoint", func() error {
+			_, err := c.UpdateJob(ctx, job.ID, client.UpdateJobRequest{Endpoint: &internal})
+			return err
+		}, client.ErrEndpointNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			var apiErr *client.APIError
+			if !errors.As(err, &apiErr) || apiErr.Code == "" || apiErr.RequestID == "" {
+				t.Fatalf("err = %#v, want an APIError with code and request id", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors matched by *APIError through errors.Is, so callers can
+// write errors.Is(err, client.ErrNotFound) without inspecting status codes.
+var (
+	ErrBadRequest   = errors.New("cronix: bad request")
+	ErrUnauthorized = errors.New("cronix: unauthorized")
+	ErrForbidden    = errors.New("cronix: forbidden")
+	ErrNotFound     = errors.New("cronix: not found")
+	ErrConflict     = errors.New("cronix: conflict")
+	ErrServer       = errors.New("cronix: server error")
+	ErrUpstream     = errors.New("cronix: upstream error")
+
+	// ErrEndpointTest is reported when the server refused to save a job
+	// because calling its endpoint failed.
+	ErrEndpointTest = errors.New("cronix: endpoint test failed")
+
+	// ErrEndpointNotAllowed is reported when an endpoint resolves to a
+	// loopback, private or other internal address the server may not call.
+	ErrEndpointNotAllowed = errors.New("cronix: endpoint not allowed")
+)
+
+// APIError is returned for any non-2xx response and carries the fields of
+// the API's error envelope.
+type APIError struct {
+	StatusCode int
+	Code       string // e.g. "job_not_found", "validation_failed"
+	Message    string
+	Details    []string
+	RequestID  string // quote this when reporting a problem
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("cronix: %d %s", e.StatusCode, e.Message)
+	if len(e.Details) > 0 {
+		msg += ": " + strings.Join(e.Details, "; ")
+	}
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrUpstream:
+		return e.StatusCode == http.StatusBadGateway || e.StatusCode == http.StatusGatewayTimeout
+	case ErrServer:
+		return e.StatusCode >= 500
+	case ErrEndpointTest:
+		return e.Code == "endpoint_test_failed"
+	case ErrEndpointNotAllowed:
+		return e.Code == "endpoint_not_allowed"
+	}
+	return false
+}
+
+func decodeError(resp *http.Response) error {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    http.StatusText(resp.StatusCode),
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	var body struct {
+		Code      string          `json:"code"`
+		Message   string          `json:"message"`
+		Details   json.RawMessage `json:"details"`
+		RequestID string          `json:"request_id"`
+	}
+	if json.Unmarshal(raw, &body) != nil {
+		if s := strings.TrimSpace(string(raw)); s != "" {
+			apiErr.Message = s
+		}
+		return apiErr
+	}
+	apiErr.Code = body.Code
+	if body.Message != "" {
+		apiErr.Message = body.Message
+	}
+	if body.RequestID != "" {
+		apiErr.RequestID = body.RequestID
+	}
+	if len(body.Details) > 0 {
+		var one string
+		var many []string
+		if json.Unmarshal(body.Details, &one) == nil && one != "" {
+			apiErr.Details = []string{one}
+		} else if json.Unmarshal(body.Details, &many) == nil {
+			apiErr.Details = many
+		}
+	}
+	return apiErr
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	all := []error{ErrBadRequest, ErrUnauthorized, ErrForbidden, ErrNotFound, ErrConflict, ErrServer, ErrUpstream, ErrEndpointTest, ErrEndpointNotAllowed}
+	tests := []struct {
+		status int
+		code   string
+		want   []error
+	}{
+		{400, "validation_failed", []error{ErrBadRequest}},
+		{401, "unauthorized", []error{ErrUnauthorized}},
+		{403, "forbidden", []error{ErrForbidden}},
+		{404, "job_not_found", []error{ErrNotFound}},
+		{409, "conflict", []error{ErrConflict}},
+		{500, "internal_error", []error{ErrServer}},
+		{502, "endpoint_test_failed", []error{ErrUpstream, ErrServer, ErrEndpointTest}},
+		{504, "", []error{ErrUpstream, ErrServer}},
+		{400, "endpoint_not_allowed", []error{ErrBadRequest, ErrEndpointNotAllowed}},
+	}
+	for _, tt := range tests {
+		err := error(&APIError{StatusCode: tt.status, Code: tt.code})
+		for _, sentinel := range all {
+			want := false
+			for _, w := range tt.want {
+				want = want || w == sentinel
+			}
+			if got := errors.Is(err, sentinel); got != want {
+				t.Errorf("%d %q: errors.Is(%v) = %v, want %v", tt.status, tt.code, sentinel, got, want)
+			}
+		}
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want APIError
+	}{
+		{"envelope with details", `{"code":"validation_failed","message":"invalid job","details":["name is required","bad schedule"],"request_id":"r1"}`,
+			APIError{StatusCode: 400, Code: "validation_failed", Message: "invalid job", Details: []string{"name is required", "bad schedule"}, RequestID: "r1"}},
+		{"single detail", `{"code":"validation_failed","message":"invalid job","details":"name is required"}`,
+			APIError{StatusCode: 400, Code: "validation_failed", Message: "invalid job", Details: []string{"name is required"}, RequestID: "hdr"}},
+		{"not json", "upstream broke\n",
+			APIError{StatusCode: 400, Message: "upstream broke", RequestID: "hdr"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: 400,
+				Header:     http.Header{"X-Request-Id": {"hdr"}},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+			var got *APIError
+			if !errors.As(decodeError(resp), &got) {
+				t.Fatal("not an APIError")
+			}
+			if got.StatusCode != tt.want.StatusCode || got.Code != tt.want.Code || got.Message != tt.want.Message ||
+				got.RequestID != tt.want.RequestID || strings.Join(got.Details, "|") != strings.Join(tt.want.Details, "|") {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
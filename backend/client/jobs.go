@@ -0,0 +1,328 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Job is a scheduled HTTP call as returned by the API.
+type Job struct {
+	ID        string            `json:"id"`
+	UserID    string            `json:"user_id"`
+	Name      string            `json:"name"`
+	Schedule  string            `json:"schedule"`
+	Endpoint  string            `json:"endpoint"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      *string           `json:"body,omitempty"`
+	Active    bool              `json:"active"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Transport Transport         `json:"transport"`
+
+	ClientCertificateID *string   `json:"client_certificate_id,omitempty"`
+	Auth                Auth      `json:"auth"` // secret never returned
+	Redirects           Redirects `json:"redirects"`
+
+	Type   string         `json:"type"`             // http, tcp, dns, tls_cert or grpc
+	Config map[string]any `json:"config,omitempty"` // settings of the type
+
+	Triggers Triggers `json:"triggers"`
+}
+
+// Job types.
+const (
+	TypeHTTP    = "http"
+	TypeTCP     = "tcp"      // Endpoint is host:port; passes when it accepts a connection
+	TypeDNS     = "dns"      // Endpoint is a host name; Config record_type and expected
+	TypeTLSCert = "tls_cert" // Endpoint is host[:port]; Config min_days_remaining
+	TypeGRPC    = "grpc"     // Endpoint is host:port; Config method, request, metadata, plaintext, descriptor_set
+)
+
+// Auth types.
+const (
+	AuthOAuth2ClientCredentials = "oauth2_client_credentials"
+	AuthBasic                   = "basic"
+	AuthAPIKeyQuery             = "api_key_query"
+)
+
+// Auth is how a job authenticates to its endpoint. Only the secret field of
+// its Type is used: ClientSecret, Password or APIKey. The server stores it
+// encrypted and never returns it; on update it may be left empty to keep
+// the stored one while the type is unchanged.
+type Auth struct {
+	Type string `json:"type,omitempty" yaml:"type,omitempty"` // empty for none
+
+	TokenURL     string   `json:"token_url,omitempty" yaml:"token_url,omitempty"`
+	ClientID     string   `json:"client_id,omitempty" yaml:"client_id,omitempty"`
+	ClientSecret string   `json:"client_secret,omitempty" yaml:"client_secret,omitempty"`
+	Scopes       []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+
+	Param  string `json:"param,omitempty" yaml:"param,omitempty"` // query parameter carrying APIKey
+	APIKey string `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+}
+
+// Transport overrides the server's outbound HTTP settings for one job.
+// Unset fields use the server configuration.
+type Transport struct {
+	// Proxy is an http, https or socks5 proxy URL; a pointer to "" sends
+	// the job's requests directly even if the server uses a proxy.
+	Proxy         *string `json:"proxy,omitempty" yaml:"proxy,omitempty"`
+	HTTP2         *bool   `json:"http2,omitempty" yaml:"http2,omitempty"`
+	TLSMinVersion string  `json:"tls_min_version,omitempty" yaml:"tls_min_version,omitempty"` // 1.0, 1.1, 1.2 or 1.3
+}
+
+// Redirects is how a job follows redirects. The zero value follows up to
+// 10 redirects to any host.
+type Redirects struct {
+	Follow         *bool `json:"follow,omitempty" yaml:"follow,omitempty"`                     // false returns the redirect response itself
+	MaxHops        int   `json:"max_hops,omitempty" yaml:"max_hops,omitempty"`                 // 1 to 10; 0 means 10
+	AllowCrossHost *bool `json:"allow_cross_host,omitempty" yaml:"allow_cross_host,omitempty"` // false fails runs redirected to another host
+}
+
+// Triggers are the jobs the scheduler runs after a scheduled run of a job,
+// by id; manifests use job names instead. Triggered jobs run even if they
+// are inactive, and all runs of a chain share a run group.
+type Triggers struct {
+	OnSuccess []string `json:"on_success,omitempty" yaml:"on_success,omitempty"`
+	OnFailure []string `json:"on_failure,omitempty" yaml:"on_failure,omitempty"`
+	PassBody  bool     `json:"pass_body,omitempty" yaml:"pass_body,omitempty"` // send the response body as the request body of triggered http jobs
+}
+
+// Hop is one response of a redirected request.
+type Hop struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+}
+
+// UnmarshalJSON accepts the server representation, in which headers,
+// transport, auth, redirects, config and triggers are raw JSONB columns and therefore arrive base64
+// encoded.
+func (j *Job) UnmarshalJSON(b []byte) error {
+	type alias Job
+	var wire struct {
+		alias
+		Headers   json.RawMessage `json:"headers"`
+		Transport json.RawMessage `json:"transport"`
+		Auth      json.RawMessage `json:"auth"`
+		Redirects json.RawMessage `json:"redirects"`
+		Config    json.RawMessage `json:"config"`
+		Triggers  json.RawMessage `json:"triggers"`
+	}
+	if err := json.Unmarshal(b, &wire); err != nil {
+		return err
+	}
+	*j = Job(wire.alias)
+	j.Headers = nil
+	j.Transport = Transport{}
+	j.Auth = Auth{}
+	j.Redirects = Redirects{}
+	j.Config = nil
+	j.Triggers = Triggers{}
+	if err := decodeJSONB(wire.Headers, &j.Headers); err != nil {
+		return err
+	}
+	if err := decodeJSONB(wire.Transport, &j.Transport); err != nil {
+		return err
+	}
+	if err := decodeJSONB(wire.Auth, &j.Auth); err != nil {
+		return err
+	}
+	if err := decodeJSONB(wire.Redirects, &j.Redirects); err != nil {
+		return err
+	}
+	if err := decodeJSONB(wire.Config, &j.Config); err != nil {
+		return err
+	}
+	if len(j.Config) == 0 {
+		j.Config = nil
+	}
+	if err := decodeJSONB(wire.Triggers, &j.Triggers); err != nil {
+		return err
+	}
+	return nil
+}
+
+// decodeJSONB decodes a JSONB column sent either base64 encoded or as
+// plain JSON.
+func decodeJSONB(raw json.RawMessage, v any) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var encoded []byte
+	if err := json.Unmarshal(raw, &encoded); err == nil {
+		if len(encoded) == 0 {
+			return nil
+		}
+		return json.Unmarshal(encoded, v)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// CreateJobRequest is the body of POST /api/jobs. The server calls the
+// endpoint, or runs the check, once before saving and rejects the job if
+// that fails.
+type CreateJobRequest struct {
+	Name     string            `json:"name"`
+	Schedule string            `json:"schedule"`
+	Endpoint string            `json:"endpoint"`
+	Method   string            `json:"method,omitempty"` // required for http jobs
+	Type     string            `json:"type,omitempty"`   // TypeHTTP if empty
+	Config   map[string]any    `json:"config,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     *string           `json:"body,omitempty"`
+	Active   bool              `json:"active"`
+
+	Transport           *Transport `json:"transport,omitempty"`
+	ClientCertificateID string     `json:"client_certificate_id,omitempty"`
+	Auth                *Auth      `json:"auth,omitempty"`
+	Redirects           *Redirects `json:"redirects,omitempty"`
+	Triggers            *Triggers  `json:"triggers,omitempty"`
+}
+
+// UpdateJobRequest changes only the fields that are set; a job stays
+// active or paused unless Active is set.
+type UpdateJobRequest struct {
+	Name     *string            `json:"name,omitempty"`
+	Schedule *string            `json:"schedule,omitempty"`
+	Endpoint *string            `json:"endpoint,omitempty"`
+	Method   *string            `json:"method,omitempty"`
+	Headers  *map[string]string `json:"headers,omitempty"` // replaces all headers; a pointer to an empty map clears them
+	Body     *string            `json:"body,omitempty"`
+	Active   *bool              `json:"active,omitempty"`
+
+	Transport *Transport `json:"transport,omitempty"` // replaces all overrides when set
+
+	// ClientCertificateID attaches a client certificate; a pointer to ""
+	// detaches the current one.
+	ClientCertificateID *string `json:"client_certificate_id,omitempty"`
+
+	// Auth replaces the job's auth; a pointer to an empty Auth removes it.
+	Auth *Auth `json:"auth,omitempty"`
+
+	Redirects *Redirects `json:"redirects,omitempty"` // replaces the whole policy when set
+
+	Type   *string        `json:"type,omitempty"`
+	Config map[string]any `json:"config,omitempty"` // replaces the whole config when set
+
+	Triggers *Triggers `json:"triggers,omitempty"` // replaces all triggers when set
+}
+
+// Log is one run of a job.
+type Log struct {
+	ID           string     `json:"id"`
+	JobID        string     `json:"job_id"`
+	Status       string     `json:"status"` // success, failure or aborted
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	DurationMs   *int32     `json:"duration_ms,omitempty"`
+	ResponseCode *int32     `json:"response_code,omitempty"`
+	Error        *string    `json:"error,omitempty"`
+	ResponseBody *string    `json:"response_body,omitempty"`
+	Timings      Timings    `json:"timings"`
+	// Redirects lists the responses of a redirected run in order, ending
+	// with the final one; nil if the run was not redirected.
+	Redirects []Hop `json:"redirects,omitempty"`
+	// RunGroup is shared by the runs of a chain of triggered jobs; it is
+	// the run's own id when nothing triggered it.
+	RunGroup *string `json:"run_group,omitempty"`
+}
+
+// Timings breaks a run down by phase, in milliseconds. Phases that did not
+// happen, such as DNS and connect on a reused connection, are nil.
+type Timings struct {
+	DNSMs      *int32 `json:"dns_ms,omitempty"`
+	ConnectMs  *int32 `json:"connect_ms,omitempty"`
+	TLSMs      *int32 `json:"tls_ms,omitempty"`
+	TTFBMs     *int32 `json:"ttfb_ms,omitempty"`
+	DownloadMs *int32 `json:"download_ms,omitempty"`
+}
+
+func (c *Client) ListJobs(ctx context.Context, limit, offset int) ([]Job, error) {
+	q := url.Values{}
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("offset", strconv.Itoa(offset))
+	var jobs []Job
+	err := c.do(ctx, http.MethodGet, "/api/jobs", q, nil, &jobs)
+	return jobs, err
+}
+
+func (c *Client) GetJob(ctx context.Context, id string) (*Job, error) {
+	var job Job
+	if err := c.do(ctx, http.MethodGet, "/api/jobs/"+url.PathEscape(id), nil, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (c *Client) CreateJob(ctx context.Context, req CreateJobRequest) (*Job, error) {
+	var job Job
+	if err := c.do(ctx, http.MethodPost, "/api/jobs", nil, req, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (c *Client) UpdateJob(ctx context.Context, id string, req UpdateJobRequest) (*Job, error) {
+	var job Job
+	if err := c.do(ctx, http.MethodPut, "/api/jobs/"+url.PathEscape(id), nil, req, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (c *Client) DeleteJob(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/jobs/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// SigningSecret returns the secret the job signs its requests with. It is
+// ErrNotFound if the job's requests are not signed.
+func (c *Client) SigningSecret(ctx context.Context, jobID string) (string, error) {
+	var out struct {
+		Secret string `json:"secret"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/jobs/"+url.PathEscape(jobID)+"/signing-secret", nil, nil, &out); err != nil {
+		return "", err
+	}
+	return out.Secret, nil
+}
+
+// RotateSigningSecret makes the job sign its requests with a new secret,
+// which it returns. The previous secret stops being used immediately.
+func (c *Client) RotateSigningSecret(ctx context.Context, jobID string) (string, error) {
+	var out struct {
+		Secret string `json:"secret"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/jobs/"+url.PathEscape(jobID)+"/signing-secret", nil, nil, &out); err != nil {
+		return "", err
+	}
+	return out.Secret, nil
+}
+
+// DisableSigning stops the job from signing its requests.
+func (c *Client) DisableSigning(ctx context.Context, jobID string) error {
+	return c.do(ctx, http.MethodDelete, "/api/jobs/"+url.PathEscape(jobID)+"/signing-secret", nil, nil, nil)
+}
+
+// RunJob executes the job immediately and returns the resulting log entry.
+func (c *Client) RunJob(ctx context.Context, id string) (*Log, error) {
+	var l Log
+	if err := c.do(ctx, http.MethodPost, "/api/jobs/"+url.PathEscape(id)+"/run", nil, nil, &l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// ListLogs returns the most recent runs of a job, newest first.
+func (c *Client) ListLogs(ctx context.Context, jobID string) ([]Log, error) {
+	var logs []Log
+	err := c.do(ctx, http.MethodGet, "/api/jobs/"+url.PathEscape(jobID)+"/logs", nil, nil, &logs)
+	return logs, err
+}
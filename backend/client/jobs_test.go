@@ -0,0 +1,67 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+func TestJobUnmarshalJSON(t *testing.T) {
+	b64 := func(s string) string {
+		return `"` + base64.StdEncoding.EncodeToString([]byte(s)) + `"`
+	}
+	tests := []struct {
+		name  string
+		jsonb func(string) string // how JSONB columns are sent
+	}{
+		{"base64", b64},
+		{"plain", func(s string) string { return s }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := `{"id":"j1","name":"n","type":"dns",` +
+				`"headers":` + tt.jsonb(`{"X-A":"1"}`) + `,` +
+				`"transport":` + tt.jsonb(`{"http2":false}`) + `,` +
+				`"auth":` + tt.jsonb(`{"type":"basic","username":"u"}`) + `,` +
+				`"redirects":` + tt.jsonb(`{"max_hops":3}`) + `,` +
+				`"config":` + tt.jsonb(`{"record_type":"TXT"}`) + `,` +
+				`"triggers":` + tt.jsonb(`{"on_failure":["j2"]}`) + `}`
+			var j Job
+			if err := json.Unmarshal([]byte(raw), &j); err != nil {
+				t.Fatal(err)
+			}
+			if j.ID != "j1" || j.Type != TypeDNS {
+				t.Errorf("plain fields: %+v", j)
+			}
+			if j.Headers["X-A"] != "1" {
+				t.Errorf("headers = %v", j.Headers)
+			}
+			if j.Transport.HTTP2 == nil || *j.Transport.HTTP2 {
+				t.Errorf("transport = %+v", j.Transport)
+			}
+			if j.Auth.Type != AuthBasic || j.Auth.Username != "u" {
+				t.Errorf("auth = %+v", j.Auth)
+			}
+			if j.Redirects.MaxHops != 3 {
+				t.Errorf("redirects = %+v", j.Redirects)
+			}
+			if j.Config["record_type"] != "TXT" {
+				t.Errorf("config = %v", j.Config)
+			}
+			if len(j.Triggers.OnFailure) != 1 || j.Triggers.OnFailure[0] != "j2" {
+				t.Errorf("triggers = %+v", j.Triggers)
+			}
+		})
+	}
+
+	t.Run("empty and null", func(t *testing.T) {
+		var j Job
+		raw := `{"id":"j1","headers":null,"transport":"","config":` + b64(`{}`) + `}`
+		if err := json.Unmarshal([]byte(raw), &j); err != nil {
+			t.Fatal(err)
+		}
+		if j.Headers != nil || j.Config != nil || j.Transport != (Transport{}) {
+			t.Errorf("got %+v", j)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Manifest mirrors the document served by /api/jobs/export and accepted by
+// /api/jobs/apply. It carries yaml tags so it can be read from files in git.
+type Manifest struct {
+	APIVersion string            `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string            `json:"kind" yaml:"kind"`
+	Jobs       []JobSpec         `json:"jobs" yaml:"jobs"`
+	Secrets    map[string]string `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+}
+
+type JobSpec struct {
+	Name          string            `json:"name" yaml:"name"`
+	Schedule      string            `json:"schedule" yaml:"schedule"`
+	Endpoint      string            `json:"endpoint" yaml:"endpoint"`
+	Method        string            `json:"method,omitempty" yaml:"method,omitempty"`
+	Type          string            `json:"type,omitempty" yaml:"type,omitempty"` // http if empty
+	Config        map[string]any    `json:"config,omitempty" yaml:"config,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	SecretHeaders map[string]string `json:"secret_headers,omitempty" yaml:"secret_headers,omitempty"`
+	Body          *string           `json:"body,omitempty" yaml:"body,omitempty"`
+	Active        *bool             `json:"active,omitempty" yaml:"active,omitempty"`
+	Transport     *Transport        `json:"transport,omitempty" yaml:"transport,omitempty"`
+
+	// ClientCertificate is the name of an uploaded client certificate.
+	ClientCertificate string `json:"client_certificate,omitempty" yaml:"client_certificate,omitempty"`
+	// Auth is exported without its secret; supply it in Manifest.Secrets
+	// under "<job>/auth" or leave it out to keep the stored one.
+	Auth      *Auth      `json:"auth,omitempty" yaml:"auth,omitempty"`
+	Redirects *Redirects `json:"redirects,omitempty" yaml:"redirects,omitempty"`
+	// Triggers names other jobs of the manifest.
+	Triggers *Triggers `json:"triggers,omitempty" yaml:"triggers,omitempty"`
+}
+
+type PlanOperation struct {
+	Action  string   `json:"action"`
+	Name    string   `json:"name"`
+	JobID   string   `json:"job_id,omitempty"`
+	Changes []string `json:"changes,omitempty"`
+}
+
+type ApplyResult struct {
+	DryRun     bool            `json:"dry_run"`
+	Operations []PlanOperation `json:"operations"`
+	Summary    map[string]int  `json:"summary"`
+}
+
+func (c *Client) Export(ctx context.Context) (*Manifest, error) {
+	q := url.Values{}
+	q.Set("format", "json")
+	var m Manifest
+	if err := c.do(ctx, http.MethodGet, "/api/jobs/export", q, nil, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Apply reconciles the caller's jobs with m. With dryRun set the server
+// only reports the plan.
+func (c *Client) Apply(ctx context.Context, m Manifest, dryRun bool) (*ApplyResult, error) {
+	q := url.Values{}
+	q.Set("dry_run", strconv.FormatBool(dryRun))
+	var res ApplyResult
+	if err := c.do(ctx, http.MethodPost, "/api/jobs/apply", q, m, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+
+	"cronix.ashutosh.net/client"
+)
+
+func exportCmd(args []string) error {
+	fs, g := newFlagSet("export")
+	format := fs.String("format", "yaml", "manifest format: yaml or json")
+	fs.Parse(args)
+
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	m, err := c.Export(context.Background())
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "yaml":
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		defer enc.Close()
+		return enc.Encode(m)
+	case "json":
+		g.output = "json"
+		return g.print(m, nil)
+	}
+	return fmt.Errorf("unknown manifest format %q", *format)
+}
+
+// applyCmd sends a manifest to the server. Secret header references in the
+// manifest are filled from -secret flags; references left unset keep the
+// values already stored on the server.
+func applyCmd(args []string) error {
+	fs, g := newFlagSet("apply")
+	file := fs.String("f", "", "manifest file, YAML or JSON (- for stdin)")
+	dryRun := fs.Bool("dry-run", false, "show the plan without changing anything")
+	secrets := keyValues{}
+	fs.Var(secrets, "secret", "secret value ref=value (repeatable)")
+	fs.Parse(args)
+	if *file == "" {
+		return fmt.Errorf("apply: -f is required")
+	}
+
+	var data []byte
+	var err error
+	if *file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return err
+	}
+	// YAML is a superset of JSON, so one decoder handles both.
+	var m client.Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("parse %s: %w", *file, err)
+	}
+	if len(secrets) > 0 {
+		if m.Secrets == nil {
+			m.Secrets = map[string]string{}
+		}
+		for k, v := range secrets {
+			m.Secrets[k] = v
+		}
+	}
+
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	res, err := c.Apply(context.Background(), m, *dryRun)
+	if err != nil {
+		return err
+	}
+	return g.print(res, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ACTION\tNAME\tID\tCHANGES")
+		for _, op := range res.Operations {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", op.Action, op.Name, op.JobID, strings.Join(op.Changes, ","))
+		}
+		fmt.Fprintln(w)
+		prefix := ""
+		if res.DryRun {
+			prefix = "(dry run) "
+		}
+		fmt.Fprintf(w, "%s%d to create, %d to update, %d to delete, %d unchanged\n", prefix,
+			res.Summary["create"], res.Summary["update"], res.Summary["delete"], res.Summary["unchanged"])
+	})
+}
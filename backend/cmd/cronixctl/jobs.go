@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"cronix.ashutosh.net/client"
+)
+
+func jobsList(args []string) error {
+	fs, g := newFlagSet("jobs list")
+	limit := fs.Int("limit", 50, "maximum number of jobs")
+	offset := fs.Int("offset", 0, "number of jobs to skip")
+	fs.Parse(args)
+
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	jobs, err := c.ListJobs(context.Background(), *limit, *offset)
+	if err != nil {
+		return err
+	}
+	return g.print(jobs, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tSCHEDULE\tMETHOD\tENDPOINT\tACTIVE")
+		for _, j := range jobs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%t\n", j.ID, j.Name, j.Schedule, j.Method, j.Endpoint, j.Active)
+		}
+	})
+}
+
+func jobsGet(args []string) error {
+	fs, g := newFlagSet("jobs get")
+	fs.Parse(args)
+	if err := requireArgs(fs, 1, "<id>"); err != nil {
+		return err
+	}
+
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	job, err := c.GetJob(context.Background(), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return g.print(job, func(w *tabwriter.Writer) { printJob(w, job) })
+}
+
+func jobsCreate(args []string) error {
+	fs, g := newFlagSet("jobs create")
+	file := fs.String("f", "", "read the job from a JSON file (- for stdin)")
+	name := fs.String("name", "", "job name")
+	schedule := fs.String("schedule", "", "cron spec with seconds, e.g. \"0 */5 * * * *\"")
+	endpoint := fs.String("endpoint", "", "URL to call")
+	method := fs.String("method", "GET", "HTTP method")
+	body := fs.String("body", "", "request body")
+	active := fs.Bool("active", true, "schedule the job immediately")
+	headers := keyValues{}
+	fs.Var(headers, "H", "request header key=value (repeatable)")
+	fs.Parse(args)
+
+	var req client.CreateJobRequest
+	if *file != "" {
+		if err := readJSON(*file, &req); err != nil {
+			return err
+		}
+	} else {
+		req = client.CreateJobRequest{
+			Name:     *name,
+			Schedule: *schedule,
+			Endpoint: *endpoint,
+			Method:   *method,
+			Headers:  headers,
+			Active:   *active,
+		}
+		if *body != "" {
+			req.Body = body
+		}
+	}
+
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	job, err := c.CreateJob(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	return g.print(job, func(w *tabwriter.Writer) { printJob(w, job) })
+}
+
+// editableJob is the document opened in $EDITOR by jobs edit.
+type editableJob struct {
+	Name     string            `json:"name"`
+	Schedule string            `json:"schedule"`
+	Endpoint string            `json:"endpoint"`
+	Method   string            `json:"method"`
+	Headers  map[string]string `json:"headers"`
+	Body     *string           `json:"body"`
+	Active   bool              `json:"active"`
+}
+
+func jobsEdit(args []string) error {
+	fs, g := newFlagSet("jobs edit")
+	fs.Parse(args)
+	if err := requireArgs(fs, 1, "<id>"); err != nil {
+		return err
+	}
+
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	job, err := c.GetJob(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	before := editableJob{job.Name, job.Schedule, job.Endpoint, job.Method, job.Headers, job.Body, job.Active}
+	if before.Headers == nil {
+		before.Headers = map[string]string{}
+	}
+	var after editableJob
+	if err := editJSON(before, &after); err != nil {
+		return err
+	}
+	if after.Headers == nil {
+		// "headers": null or a deleted key clears them like {}
+		after.Headers = map[string]string{}
+	}
+
+	// Send every field so that edits back to the zero value (e.g. active
+	// false) are not dropped.
+	req := client.UpdateJobRequest{
+		Name:     &after.Name,
+		Schedule: &after.Schedule,
+		Endpoint: &after.Endpoint,
+		Method:   &after.Method,
+		Headers:  &after.Headers,
+		Body:     after.Body,
+		Active:   &after.Active,
+	}
+	updated, err := c.UpdateJob(ctx, job.ID, req)
+	if err != nil {
+		return err
+	}
+	return g.print(updated, func(w *tabwriter.Writer) { printJob(w, updated) })
+}
+
+func jobsDelete(args []string) error {
+	fs, g := newFlagSet("jobs delete")
+	fs.Parse(args)
+	if err := requireArgs(fs, 1, "<id>"); err != nil {
+		return err
+	}
+
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	if err := c.DeleteJob(context.Background(), fs.Arg(0)); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "job %s deleted\n", fs.Arg(0))
+	return nil
+}
+
+func jobsRun(args []string) error {
+	fs, g := newFlagSet("jobs run")
+	fs.Parse(args)
+	if err := requireArgs(fs, 1, "<id>"); err != nil {
+		return err
+	}
+
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	l, err := c.RunJob(context.Background(), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return g.print(l, func(w *tabwriter.Writer) {
+		printLogHeader(w)
+		printLog(w, *l)
+	})
+}
+
+func printJob(w *tabwriter.Writer, j *client.Job) {
+	fmt.Fprintf(w, "ID:\t%s\n", j.ID)
+	fmt.Fprintf(w, "Name:\t%s\n", j.Name)
+	fmt.Fprintf(w, "Schedule:\t%s\n", j.Schedule)
+	fmt.Fprintf(w, "Method:\t%s\n", j.Method)
+	fmt.Fprintf(w, "Endpoint:\t%s\n", j.Endpoint)
+	fmt.Fprintf(w, "Active:\t%t\n", j.Active)
+	for k, v := range j.Headers {
+		fmt.Fprintf(w, "Header:\t%s: %s\n", k, v)
+	}
+	if j.Body != nil {
+		fmt.Fprintf(w, "Body:\t%s\n", *j.Body)
+	}
+	fmt.Fprintf(w, "Created:\t%s\n", j.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(w, "Updated:\t%s\n", j.UpdatedAt.Format(time.RFC3339))
+}
+
+func printLogHeader(w *tabwriter.Writer) {
+	fmt.Fprintln(w, "STARTED\tSTATUS\tCODE\tDURATION\tERROR")
+}
+
+func printLog(w *tabwriter.Writer, l client.Log) {
+	started, code, dur, errStr := "-", "-", "-", ""
+	if l.StartedAt != nil {
+		started = l.StartedAt.Local().Format(time.RFC3339)
+	}
+	if l.ResponseCode != nil {
+		code = strconv.Itoa(int(*l.ResponseCode))
+	}
+	if l.DurationMs != nil {
+		dur = fmt.Sprintf("%dms", *l.DurationMs)
+	}
+	if l.Error != nil {
+		errStr = *l.Error
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", started, l.Status, code, dur, errStr)
+}
+
+func readJSON(path string, v any) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// editJSON writes in to a temporary file, opens it in $EDITOR (vi if unset)
+// and decodes the saved result into out.
+func editJSON(in, out any) error {
+	f, err := os.CreateTemp("", "cronixctl-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(in); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	cmd := exec.Command(editor, f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor: %w", err)
+	}
+	if err := readJSON(f.Name(), out); err != nil {
+		return fmt.Errorf("edited job is not valid JSON: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"cronix.ashutosh.net/client"
+)
+
+// logsTail prints the recent runs of a job and then polls for new ones until
+// interrupted. The API only keeps the last few runs per job, so a poll
+// interval longer than the job's schedule can miss entries.
+func logsTail(args []string) error {
+	fs, g := newFlagSet("logs tail")
+	interval := fs.Duration("interval", 5*time.Second, "poll interval")
+	follow := fs.Bool("f", true, "keep polling for new runs")
+	fs.Parse(args)
+	if err := requireArgs(fs, 1, "<job-id>"); err != nil {
+		return err
+	}
+	if g.output != "table" && g.output != "json" {
+		return fmt.Errorf("unknown output format %q", g.output)
+	}
+
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	enc := json.NewEncoder(os.Stdout)
+	if g.output == "table" {
+		printLogHeader(w)
+	}
+
+	seen := map[string]bool{}
+	for {
+		logs, err := c.ListLogs(ctx, fs.Arg(0))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		// Logs arrive newest first; print oldest first like tail(1).
+		for i := len(logs) - 1; i >= 0; i-- {
+			l := logs[i]
+			if seen[l.ID] {
+				continue
+			}
+			seen[l.ID] = true
+			printTailEntry(w, enc, g.output, l)
+		}
+		w.Flush()
+
+		if !*follow {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+func printTailEntry(w *tabwriter.Writer, enc *json.Encoder, output string, l client.Log) {
+	if output == "json" {
+		_ = enc.Encode(l)
+		return
+	}
+	printLog(w, l)
+}
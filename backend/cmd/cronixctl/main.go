@@ -0,0 +1,164 @@
+// Command cronixctl manages CroniX jobs from the terminal.
+//
+// It talks to the REST API with a bearer token taken from -token or the
+// CRONIX_TOKEN environment variable, against the server given by -server
+// or CRONIX_SERVER (default http://localhost:8080).
+//
+// The token is the session token the server issues at sign-in (the
+// auth_token cookie of the web app). It grants everything the signed-in
+// user can do, expires 24 hours after sign-in and cannot be revoked before
+// then short of rotating JWT_SECRET, which signs out every user. It is
+// meant for interactive use; do not store it in CI or other long-lived
+// places.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"cronix.ashutosh.net/client"
+)
+
+const usage = `Usage: cronixctl <command> [flags] [args]
+
+Commands:
+  jobs list                  List jobs
+  jobs get <id>              Show one job
+  jobs create                Create a job from flags or -f file.json
+  jobs edit <id>             Edit a job in $EDITOR
+  jobs delete <id>           Delete a job
+  jobs run <id>              Run a job now and print the log entry
+  logs tail <job-id>         Follow a job's run logs
+  schedule preview <spec>    Show the next fire times of a cron spec
+  export                     Print all jobs as a manifest
+  apply -f <manifest>        Reconcile jobs with a YAML or JSON manifest
+
+Every command accepts -server, -token and -o (table|json).
+
+The token is your session token from signing in to the web app (the
+auth_token cookie). It expires 24 hours after sign-in, cannot be revoked
+until then and allows everything your account can do, so keep it out of
+CI and shared shells.
+`
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("no command given")
+	}
+
+	cmd, rest := args[0], args[1:]
+	switch cmd {
+	case "jobs", "logs", "schedule":
+		if len(rest) == 0 {
+			fmt.Fprint(os.Stderr, usage)
+			return fmt.Errorf("%s: missing subcommand", cmd)
+		}
+		cmd, rest = cmd+" "+rest[0], rest[1:]
+	}
+
+	switch cmd {
+	case "jobs list":
+		return jobsList(rest)
+	case "jobs get":
+		return jobsGet(rest)
+	case "jobs create":
+		return jobsCreate(rest)
+	case "jobs edit":
+		return jobsEdit(rest)
+	case "jobs delete":
+		return jobsDelete(rest)
+	case "jobs run":
+		return jobsRun(rest)
+	case "logs tail":
+		return logsTail(rest)
+	case "schedule preview":
+		return schedulePreview(rest)
+	case "export":
+		return exportCmd(rest)
+	case "apply":
+		return applyCmd(rest)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return nil
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return fmt.Errorf("unknown command %q", cmd)
+}
+
+// globals holds the flags every command shares.
+type globals struct {
+	server string
+	token  string
+	output string
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *globals) {
+	g := &globals{}
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	server := os.Getenv("CRONIX_SERVER")
+	if server == "" {
+		server = "http://localhost:8080"
+	}
+	fs.StringVar(&g.server, "server", server, "CroniX server URL (env CRONIX_SERVER)")
+	fs.StringVar(&g.token, "token", os.Getenv("CRONIX_TOKEN"), "API token (env CRONIX_TOKEN)")
+	fs.StringVar(&g.output, "o", "table", "output format: table or json")
+	return fs, g
+}
+
+func (g *globals) client() (*client.Client, error) {
+	if g.token == "" {
+		return nil, fmt.Errorf("no API token: pass -token or set CRONIX_TOKEN")
+	}
+	return client.New(g.server, g.token), nil
+}
+
+// print writes v as JSON when -o json was given, otherwise calls table.
+func (g *globals) print(v any, table func(w *tabwriter.Writer)) error {
+	switch g.output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	}
+	return fmt.Errorf("unknown output format %q", g.output)
+}
+
+// requireArgs checks the positional argument count after flag parsing.
+func requireArgs(fs *flag.FlagSet, n int, names string) error {
+	if fs.NArg() != n {
+		return fmt.Errorf("%s: expected %s", fs.Name(), names)
+	}
+	return nil
+}
+
+// keyValues is a repeatable -flag key=value.
+type keyValues map[string]string
+
+func (kv keyValues) String() string { return "" }
+
+func (kv keyValues) Set(s string) error {
+	// Accept both key=value and curl-style "Key: value", splitting at
+	// whichever separator comes first.
+	i := strings.IndexAny(s, "=:")
+	if i <= 0 {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	kv[strings.TrimSpace(s[:i])] = strings.TrimSpace(s[i+1:])
+	return nil
+}
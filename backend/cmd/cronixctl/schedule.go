@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// specParser accepts the same six-field specs as the server's scheduler.
+var specParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// schedulePreview evaluates a cron spec locally; it needs no server.
+func schedulePreview(args []string) error {
+	fs, g := newFlagSet("schedule preview")
+	count := fs.Int("n", 5, "number of fire times to show")
+	tz := fs.String("tz", "Local", "time zone to display, e.g. UTC or Europe/Berlin")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return fmt.Errorf("schedule preview: expected <spec>")
+	}
+	// Allow the spec unquoted: schedule preview 0 */5 * * * *
+	spec := strings.Join(fs.Args(), " ")
+
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	sched, err := specParser.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+
+	times := make([]time.Time, 0, *count)
+	t := time.Now().In(loc)
+	for i := 0; i < *count; i++ {
+		t = sched.Next(t)
+		if t.IsZero() {
+			break
+		}
+		times = append(times, t)
+	}
+
+	return g.print(times, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "#\tFIRE TIME\tIN")
+		now := time.Now()
+		for i, t := range times {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", i+1, t.Format(time.RFC3339), t.Sub(now).Round(time.Second))
+		}
+	})
+}
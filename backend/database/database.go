@@ -0,0 +1,62 @@
+// Package database embeds the goose migrations so the server binary knows
+// which schema version it was built against.
+//
+// Postgres migrations live in migrations/ and SQLite ones in sqlite/. The
+// SQLite set mirrors the Postgres one file for file, with the same
+// versions, so both backends report the same schema version.
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// Storage drivers, as named by database.driver in the config.
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// VersionTable is the table goose records applied migrations in, as set in goose.yaml.
+const VersionTable = "goose_db_version"
+
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+//go:embed sqlite/*.sql
+var SQLiteMigrations embed.FS
+
+// MigrationsFS returns the migrations for driver, rooted at their directory.
+func MigrationsFS(driver string) (fs.FS, error) {
+	switch driver {
+	case Postgres:
+		return fs.Sub(Migrations, "migrations")
+	case SQLite:
+		return fs.Sub(SQLiteMigrations, "sqlite")
+	}
+	return nil, fmt.Errorf("unknown database driver %q", driver)
+}
+
+// LatestVersion returns the version of the newest embedded migration for
+// driver, taken from the numeric prefix of its file name as goose does.
+func LatestVersion(driver string) int64 {
+	fsys, err := MigrationsFS(driver)
+	if err != nil {
+		return 0
+	}
+	entries, _ := fs.ReadDir(fsys, ".")
+	var latest int64
+	for _, e := range entries {
+		prefix, _, ok := strings.Cut(e.Name(), "_")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.ParseInt(prefix, 10, 64); err == nil && v > latest {
+			latest = v
+		}
+	}
+	return latest
+}
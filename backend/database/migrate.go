@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// NewMigrator returns a goose provider over the embedded migrations for
+// driver. On Postgres an advisory lock keeps instances booting together
+// from migrating at the same time; SQLite serialises writers itself.
+func NewMigrator(db *sql.DB, driver string) (*goose.Provider, error) {
+	fsys, err := MigrationsFS(driver)
+	if err != nil {
+		return nil, err
+	}
+	opts := []goose.ProviderOption{
+		goose.WithTableName(VersionTable),
+		goose.WithSlog(slog.Default()),
+	}
+	dialect := goose.DialectSQLite3
+	if driver == Postgres {
+		locker, err := lock.NewPostgresSessionLocker()
+		if err != nil {
+			return nil, err
+		}
+		dialect = goose.DialectPostgres
+		opts = append(opts, goose.WithSessionLocker(locker))
+	}
+	return goose.NewProvider(dialect, db, fsys, opts...)
+}
+
+// Verify returns an error unless the database has every embedded migration applied.
+func Verify(ctx context.Context, m *goose.Provider) error {
+	current, err := m.GetDBVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("read migration version: %w", err)
+	}
+	sources := m.ListSources()
+	if len(sources) == 0 {
+		return nil
+	}
+	if expected := sources[len(sources)-1].Version; current < expected {
+		return fmt.Errorf("database is at migration %d, this build expects %d; run migrations first", current, expected)
+	}
+	return nil
+}
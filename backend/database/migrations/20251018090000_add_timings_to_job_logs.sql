@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE job_logs ADD COLUMN IF NOT EXISTS dns_ms INT;
+ALTER TABLE job_logs ADD COLUMN IF NOT EXISTS connect_ms INT;
+ALTER TABLE job_logs ADD COLUMN IF NOT EXISTS tls_ms INT;
+ALTER TABLE job_logs ADD COLUMN IF NOT EXISTS ttfb_ms INT;
+ALTER TABLE job_logs ADD COLUMN IF NOT EXISTS download_ms INT;
+
+-- +goose Down
+ALTER TABLE job_logs DROP COLUMN IF EXISTS download_ms;
+ALTER TABLE job_logs DROP COLUMN IF EXISTS ttfb_ms;
+ALTER TABLE job_logs DROP COLUMN IF EXISTS tls_ms;
+ALTER TABLE job_logs DROP COLUMN IF EXISTS connect_ms;
+ALTER TABLE job_logs DROP COLUMN IF EXISTS dns_ms;
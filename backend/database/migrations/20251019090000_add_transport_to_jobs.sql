@@ -0,0 +1,7 @@
+-- +goose Up
+-- Per-job overrides of the outbound HTTP transport, such as a proxy or
+-- the minimum TLS version. An empty object uses the server settings.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS transport JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN IF EXISTS transport;
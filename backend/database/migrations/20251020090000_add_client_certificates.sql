@@ -0,0 +1,27 @@
+-- +goose Up
+-- Client certificates presented to job endpoints that require mutual TLS.
+-- private_key holds the PEM key sealed by the secretbox package; the
+-- certificate and CA bundle are public and stored as PEM.
+CREATE TABLE IF NOT EXISTS client_certificates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    certificate TEXT NOT NULL,
+    private_key BYTEA NOT NULL,
+    ca_bundle TEXT,
+    subject TEXT NOT NULL,
+    fingerprint TEXT NOT NULL, -- SHA-256 of the leaf certificate, hex
+    not_after TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+-- A certificate cannot be deleted while jobs use it.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS client_certificate_id UUID REFERENCES client_certificates(id);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_client_certificate_id ON jobs(client_certificate_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_jobs_client_certificate_id;
+ALTER TABLE jobs DROP COLUMN IF EXISTS client_certificate_id;
+DROP TABLE IF EXISTS client_certificates;
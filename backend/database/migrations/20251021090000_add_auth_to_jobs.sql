@@ -0,0 +1,11 @@
+-- +goose Up
+-- How a job authenticates to its endpoint. auth holds the public settings
+-- (type, token URL, client id, ...) and is an empty object for none;
+-- auth_secret holds the client secret, password or API key, sealed with
+-- the server's secrets key.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS auth JSONB NOT NULL DEFAULT '{}'::jsonb;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS auth_secret BYTEA;
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN IF EXISTS auth_secret;
+ALTER TABLE jobs DROP COLUMN IF EXISTS auth;
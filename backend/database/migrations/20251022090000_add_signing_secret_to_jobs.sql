@@ -0,0 +1,7 @@
+-- +goose Up
+-- Secret requests of the job are signed with (X-Cronix-Signature), sealed
+-- with the server's secrets key. NULL means requests are not signed.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS signing_secret BYTEA;
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN IF EXISTS signing_secret;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Per-job redirect policy (follow, max hops, cross-host); an empty object
+-- follows up to ten redirects to any host.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS redirects JSONB NOT NULL DEFAULT '{}'::jsonb;
+-- The requests of a run that was redirected, each with its URL and status;
+-- NULL when the run saw no redirect.
+ALTER TABLE job_logs ADD COLUMN IF NOT EXISTS redirects JSONB;
+
+-- +goose Down
+ALTER TABLE job_logs DROP COLUMN IF EXISTS redirects;
+ALTER TABLE jobs DROP COLUMN IF EXISTS redirects;
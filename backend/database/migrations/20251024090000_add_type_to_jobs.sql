@@ -0,0 +1,10 @@
+-- +goose Up
+-- The executor that runs a job. Existing jobs are HTTP requests; tcp, dns
+-- and tls_cert jobs check the endpoint instead of calling it.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS type TEXT NOT NULL DEFAULT 'http';
+-- Settings of the executor, e.g. the expected records of a dns job.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS config JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN IF EXISTS config;
+ALTER TABLE jobs DROP COLUMN IF EXISTS type;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Jobs run after a job finishes: {"on_success": [ids], "on_failure": [ids],
+-- "pass_body": bool}. An empty object triggers nothing.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS triggers JSONB NOT NULL DEFAULT '{}'::jsonb;
+-- Shared by the runs of one chain of triggered jobs; the run id of the
+-- first run, which a run that triggered nothing has to itself.
+ALTER TABLE job_logs ADD COLUMN IF NOT EXISTS run_group TEXT;
+CREATE INDEX IF NOT EXISTS idx_job_logs_run_group ON job_logs(run_group);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_job_logs_run_group;
+ALTER TABLE job_logs DROP COLUMN IF EXISTS run_group;
+ALTER TABLE jobs DROP COLUMN IF EXISTS triggers;
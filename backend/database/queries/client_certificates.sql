@@ -0,0 +1,17 @@
+-- name: CreateClientCertificate :one
+INSERT INTO client_certificates (user_id, name, certificate, private_key, ca_bundle, subject, fingerprint, not_after)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetClientCertificate :one
+SELECT * FROM client_certificates
+WHERE id = $1 AND user_id = $2;
+
+-- name: ListClientCertificatesByUser :many
+SELECT * FROM client_certificates
+WHERE user_id = $1
+ORDER BY name ASC, created_at ASC;
+
+-- name: DeleteClientCertificate :exec
+DELETE FROM client_certificates
+WHERE id = $1 AND user_id = $2;
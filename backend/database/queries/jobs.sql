@@ -1,6 +1,9 @@
 -- name: CreateJob :one
-INSERT INTO jobs (user_id, name, schedule, endpoint, method, headers, body, active)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+INSERT INTO jobs (user_id, name, schedule, endpoint, method, headers, body, active, transport, client_certificate_id, auth, auth_secret, redirects, type, config, triggers)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, COALESCE(sqlc.narg(transport)::jsonb, '{}'::jsonb), sqlc.narg(client_certificate_id),
+  COALESCE(sqlc.narg(auth)::jsonb, '{}'::jsonb), sqlc.narg(auth_secret), COALESCE(sqlc.narg(redirects)::jsonb, '{}'::jsonb),
+  COALESCE(NULLIF(sqlc.arg(type)::text, ''), 'http'), COALESCE(sqlc.narg(config)::jsonb, '{}'::jsonb),
+  COALESCE(sqlc.narg(triggers)::jsonb, '{}'::jsonb))
 RETURNING *;
 
 -- name: GetJob :one
@@ -12,6 +15,11 @@ WHERE user_id = $1
 ORDER BY created_at DESC
 LIMIT $2 OFFSET $3;
 
+-- name: ListAllJobsByUser :many
+SELECT * FROM jobs
+WHERE user_id = $1
+ORDER BY name ASC, created_at ASC;
+
 -- name: UpdateJob :one
 UPDATE jobs
 SET
@@ -21,17 +29,39 @@ SET
   method = COALESCE(NULLIF($5, ''), method),
   headers = COALESCE($6, headers),
   body = COALESCE($7, body),
-  active = COALESCE($8, active),
+  active = COALESCE(sqlc.narg(active)::bool, active),
+  transport = COALESCE(sqlc.narg(transport)::jsonb, transport),
+  client_certificate_id = CASE WHEN sqlc.arg(set_client_certificate)::bool
+    THEN sqlc.narg(client_certificate_id)::uuid ELSE client_certificate_id END,
+  auth = COALESCE(sqlc.narg(auth)::jsonb, auth),
+  auth_secret = CASE WHEN sqlc.narg(auth)::jsonb IS NULL
+    THEN auth_secret ELSE sqlc.narg(auth_secret)::bytea END,
+  redirects = COALESCE(sqlc.narg(redirects)::jsonb, redirects),
+  type = COALESCE(NULLIF(sqlc.arg(type)::text, ''), type),
+  config = COALESCE(sqlc.narg(config)::jsonb, config),
+  triggers = COALESCE(sqlc.narg(triggers)::jsonb, triggers),
   updated_at = NOW()
 WHERE id = $1
 RETURNING *;
 
+-- name: SetJobSigningSecret :one
+UPDATE jobs
+SET signing_secret = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetJobTriggers :one
+UPDATE jobs
+SET triggers = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
 -- name: DeleteJob :exec
 DELETE FROM jobs WHERE id = $1;
 
 -- name: InsertJobLog :one
-INSERT INTO job_logs (job_id, started_at, finished_at, duration_ms, status, response_code, error, response_body)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+INSERT INTO job_logs (job_id, started_at, finished_at, duration_ms, status, response_code, error, response_body, dns_ms, connect_ms, tls_ms, ttfb_ms, download_ms, redirects, run_group)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 RETURNING *;
 
 
@@ -47,7 +77,7 @@ SELECT *
 FROM job_logs
 WHERE job_id = $1
 ORDER BY started_at DESC
-LIMIT 5;
+LIMIT $2;
 
 -- name: DeleteOldJobLogs :exec
 DELETE FROM job_logs AS outer_logs
@@ -57,7 +87,7 @@ AND outer_logs.started_at < (
     FROM job_logs AS inner_logs
     WHERE inner_logs.job_id = $1
     ORDER BY inner_logs.started_at DESC
-    LIMIT 1 OFFSET 4
+    LIMIT 1 OFFSET sqlc.arg(keep)::int - 1
 );
 
 -- name: ListActiveJobs :many
@@ -74,5 +104,5 @@ WHERE id NOT IN (
                ROW_NUMBER() OVER (PARTITION BY job_id ORDER BY started_at DESC) as rn
         FROM job_logs
     ) ranked
-    WHERE rn <= 5
+    WHERE rn <= sqlc.arg(keep)::int
 );
\ No newline at end of file
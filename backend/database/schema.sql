@@ -1,3 +1,8 @@
+-- Schema after all migrations in database/migrations have been applied.
+-- Reference only: sqlc and goose read the migrations, so change those and
+-- keep this file in step with them. schema_test.go migrates a database and
+-- fails when its tables, columns or indexes differ from this file.
+
 CREATE EXTENSION IF NOT EXISTS "pgcrypto";
 
 CREATE TABLE IF NOT EXISTS users (
@@ -8,4 +13,68 @@ CREATE TABLE IF NOT EXISTS users (
     provider TEXT NOT NULL DEFAULT 'google',
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW()
-);
\ No newline at end of file
+);
+
+CREATE TABLE IF NOT EXISTS client_certificates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    certificate TEXT NOT NULL,
+    private_key BYTEA NOT NULL, -- sealed by the secretbox package
+    ca_bundle TEXT,
+    subject TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    not_after TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    schedule TEXT NOT NULL, -- cron spec with seconds, like "0 */5 * * * *"
+    endpoint TEXT NOT NULL,
+    method TEXT NOT NULL DEFAULT 'GET',
+    headers JSONB NOT NULL DEFAULT '{}'::jsonb,
+    body TEXT,
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    transport JSONB NOT NULL DEFAULT '{}'::jsonb, -- per-job outbound transport overrides
+    client_certificate_id UUID REFERENCES client_certificates(id),
+    auth JSONB NOT NULL DEFAULT '{}'::jsonb, -- public auth settings
+    auth_secret BYTEA, -- sealed client secret, password or API key
+    signing_secret BYTEA, -- sealed request signing secret
+    redirects JSONB NOT NULL DEFAULT '{}'::jsonb, -- redirect policy
+    type TEXT NOT NULL DEFAULT 'http', -- executor that runs the job: http, tcp, dns, tls_cert or grpc
+    config JSONB NOT NULL DEFAULT '{}'::jsonb, -- settings of the executor
+    triggers JSONB NOT NULL DEFAULT '{}'::jsonb -- jobs run after this one finishes
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id ON jobs(user_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_active ON jobs(active);
+CREATE INDEX IF NOT EXISTS idx_jobs_client_certificate_id ON jobs(client_certificate_id);
+
+CREATE TABLE IF NOT EXISTS job_logs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    duration_ms INT,
+    status TEXT NOT NULL, -- success|failure|aborted
+    response_code INT,
+    error TEXT,
+    response_body TEXT,
+    dns_ms INT,
+    connect_ms INT,
+    tls_ms INT,
+    ttfb_ms INT,
+    download_ms INT,
+    redirects JSONB, -- requests of a redirected run
+    run_group TEXT -- shared by the runs of a chain of triggered jobs
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_logs_job_id ON job_logs(job_id);
+CREATE INDEX IF NOT EXISTS idx_job_logs_run_group ON job_logs(run_group);
+CREATE INDEX IF NOT EXISTS idx_job_logs_started_at ON job_logs(started_at);
@@ -0,0 +1,295 @@
+package database_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"cronix.ashutosh.net/database"
+	"cronix.ashutosh.net/internals/store/sqlite"
+)
+
+// postgresDSNEnv names a Postgres database the tests may create schemas
+// in; the Postgres tests are skipped without it.
+const postgresDSNEnv = "CRONIX_TEST_POSTGRES_DSN"
+
+type column struct {
+	name    string
+	typ     string // as written in schema.sql, upper case
+	notNull bool
+}
+
+type schema struct {
+	tables  map[string][]column
+	indexes []string
+}
+
+var (
+	createTable = regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS (\w+) \($`)
+	createIndex = regexp.MustCompile(`^CREATE INDEX IF NOT EXISTS (\w+) ON `)
+)
+
+// readSchema parses the tables, columns and indexes of schema.sql. It
+// relies on the file's layout: one column or constraint per line.
+func readSchema(t *testing.T) schema {
+	t.Helper()
+	f, err := os.Open("schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	s := schema{tables: map[string][]column{}}
+	var table string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "--")
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case table == "":
+			if m := createTable.FindStringSubmatch(line); m != nil {
+				table = m[1]
+				s.tables[table] = nil
+			} else if m := createIndex.FindStringSubmatch(line); m != nil {
+				s.indexes = append(s.indexes, m[1])
+			}
+		case line == ");":
+			table = ""
+		default:
+			fields := strings.Fields(strings.TrimSuffix(line, ","))
+			switch fields[0] {
+			case "UNIQUE", "PRIMARY", "FOREIGN", "CONSTRAINT", "CHECK":
+				continue
+			}
+			upper := strings.ToUpper(line)
+			s.tables[table] = append(s.tables[table], column{
+				name:    fields[0],
+				typ:     strings.ToUpper(fields[1]),
+				notNull: strings.Contains(upper, "NOT NULL") || strings.Contains(upper, "PRIMARY KEY"),
+			})
+		}
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.tables) == 0 {
+		t.Fatal("no tables found in schema.sql")
+	}
+	slices.Sort(s.indexes)
+	return s
+}
+
+// compare reports every difference between the columns of table in
+// schema.sql and in the migrated database. sameType compares a schema.sql
+// type with the database's.
+func compare(t *testing.T, table string, want, got []column, sameType func(want, got string) bool) {
+	t.Helper()
+	byName := map[string]column{}
+	for _, c := range got {
+		byName[c.name] = c
+	}
+	for _, w := range want {
+		g, ok := byName[w.name]
+		if !ok {
+			t.Errorf("%s.%s is in schema.sql but not created by the migrations", table, w.name)
+			continue
+		}
+		delete(byName, w.name)
+		if !sameType(w.typ, g.typ) {
+			t.Errorf("%s.%s is %s in schema.sql but %s after migrating", table, w.name, w.typ, g.typ)
+		}
+		if w.notNull != g.notNull {
+			t.Errorf("%s.%s: NOT NULL is %v in schema.sql but %v after migrating", table, w.name, w.notNull, g.notNull)
+		}
+	}
+	for name := range byName {
+		t.Errorf("%s.%s is created by the migrations but missing from schema.sql", table, name)
+	}
+}
+
+// sqliteTypes maps schema.sql types to the SQLite migrations' storage.
+var sqliteTypes = map[string]string{
+	"UUID":        "TEXT",
+	"TEXT":        "TEXT",
+	"TIMESTAMPTZ": "TEXT",
+	"JSONB":       "TEXT",
+	"BYTEA":       "BLOB",
+	"BOOLEAN":     "INTEGER",
+	"INT":         "INTEGER",
+}
+
+func TestSQLiteMigrationsMatchSchema(t *testing.T) {
+	ctx := context.Background()
+	sqlDB, err := sqlite.Open(filepath.Join(t.TempDir(), "schema.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	m, err := database.NewMigrator(sqlDB, database.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := readSchema(t)
+	tables := queryStrings(t, sqlDB, `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name != ?`, database.VersionTable)
+	for _, table := range tables {
+		if _, ok := want.tables[table]; !ok {
+			t.Errorf("table %s is created by the migrations but missing from schema.sql", table)
+		}
+	}
+	for table, cols := range want.tables {
+		if !slices.Contains(tables, table) {
+			t.Errorf("table %s is in schema.sql but not created by the migrations", table)
+			continue
+		}
+		rows, err := sqlDB.QueryContext(ctx, `SELECT name, type, "notnull", pk FROM pragma_table_info(?)`, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []column
+		for rows.Next() {
+			var c column
+			var pk int
+			if err := rows.Scan(&c.name, &c.typ, &c.notNull, &pk); err != nil {
+				t.Fatal(err)
+			}
+			c.notNull = c.notNull || pk > 0
+			got = append(got, c)
+		}
+		rows.Close()
+		compare(t, table, cols, got, func(w, g string) bool { return sqliteTypes[w] == strings.ToUpper(g) })
+	}
+
+	indexes := queryStrings(t, sqlDB, `SELECT name FROM sqlite_master WHERE type = 'index' AND name NOT LIKE 'sqlite_autoindex_%'`)
+	if !slices.Equal(indexes, want.indexes) {
+		t.Errorf("indexes after migrating = %v, schema.sql has %v", indexes, want.indexes)
+	}
+}
+
+// postgresTypes maps schema.sql types to information_schema udt names.
+var postgresTypes = map[string]string{
+	"UUID":        "uuid",
+	"TEXT":        "text",
+	"TIMESTAMPTZ": "timestamptz",
+	"JSONB":       "jsonb",
+	"BYTEA":       "bytea",
+	"BOOLEAN":     "bool",
+	"INT":         "int4",
+}
+
+func TestPostgresMigrationsMatchSchema(t *testing.T) {
+	sqlDB, name := postgresSchema(t)
+	ctx := context.Background()
+	m, err := database.NewMigrator(sqlDB, database.Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := readSchema(t)
+	tables := queryStrings(t, sqlDB, `SELECT table_name FROM information_schema.tables WHERE table_schema = $1 AND table_name != $2`, name, database.VersionTable)
+	for _, table := range tables {
+		if _, ok := want.tables[table]; !ok {
+			t.Errorf("table %s is created by the migrations but missing from schema.sql", table)
+		}
+	}
+	for table, cols := range want.tables {
+		rows, err := sqlDB.QueryContext(ctx, `SELECT column_name, udt_name, is_nullable = 'NO'
+			FROM information_schema.columns WHERE table_schema = $1 AND table_name = $2`, name, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []column
+		for rows.Next() {
+			var c column
+			if err := rows.Scan(&c.name, &c.typ, &c.notNull); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, c)
+		}
+		rows.Close()
+		if len(got) == 0 {
+			t.Errorf("table %s is in schema.sql but not created by the migrations", table)
+			continue
+		}
+		compare(t, table, cols, got, func(w, g string) bool { return postgresTypes[w] == g })
+	}
+
+	indexes := queryStrings(t, sqlDB, `SELECT indexname FROM pg_indexes WHERE schemaname = $1 AND indexname LIKE 'idx_%'`, name)
+	if !slices.Equal(indexes, want.indexes) {
+		t.Errorf("indexes after migrating = %v, schema.sql has %v", indexes, want.indexes)
+	}
+}
+
+// postgresSchema opens the database named by CRONIX_TEST_POSTGRES_DSN with
+// a new, empty schema first on the search path, dropped after the test.
+func postgresSchema(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skip(postgresDSNEnv + " is not set")
+	}
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	name := "cronix_test_" + hex.EncodeToString(b)
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.Exec(`CREATE SCHEMA ` + name); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec(`DROP SCHEMA ` + name + ` CASCADE`)
+		admin.Close()
+	})
+
+	cfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.RuntimeParams["search_path"] = name + ",public"
+	sqlDB := stdlib.OpenDB(*cfg)
+	t.Cleanup(func() { sqlDB.Close() })
+	return sqlDB, name
+}
+
+func queryStrings(t *testing.T, sqlDB *sql.DB, query string, args ...any) []string {
+	t.Helper()
+	rows, err := sqlDB.Query(query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, s)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(out)
+	return out
+}
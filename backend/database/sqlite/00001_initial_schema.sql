@@ -0,0 +1,15 @@
+-- +goose Up
+-- SQLite mirror of migrations/00001_initial_schema.sql. IDs are UUID text
+-- and timestamps are fixed-width UTC text, so they sort as they compare.
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    email TEXT UNIQUE NOT NULL,
+    name TEXT,
+    avatar_url TEXT,
+    provider TEXT NOT NULL DEFAULT 'google',
+    created_at TEXT DEFAULT (strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000Z'),
+    updated_at TEXT DEFAULT (strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000Z')
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS users;
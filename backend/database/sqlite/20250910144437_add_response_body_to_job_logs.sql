@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE job_logs ADD COLUMN response_body TEXT;
+
+-- +goose Down
+ALTER TABLE job_logs DROP COLUMN response_body;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE job_logs ADD COLUMN dns_ms INTEGER;
+ALTER TABLE job_logs ADD COLUMN connect_ms INTEGER;
+ALTER TABLE job_logs ADD COLUMN tls_ms INTEGER;
+ALTER TABLE job_logs ADD COLUMN ttfb_ms INTEGER;
+ALTER TABLE job_logs ADD COLUMN download_ms INTEGER;
+
+-- +goose Down
+ALTER TABLE job_logs DROP COLUMN download_ms;
+ALTER TABLE job_logs DROP COLUMN ttfb_ms;
+ALTER TABLE job_logs DROP COLUMN tls_ms;
+ALTER TABLE job_logs DROP COLUMN connect_ms;
+ALTER TABLE job_logs DROP COLUMN dns_ms;
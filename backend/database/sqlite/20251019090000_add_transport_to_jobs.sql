@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE jobs ADD COLUMN transport TEXT NOT NULL DEFAULT '{}'; -- JSON object
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN transport;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS client_certificates (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    certificate TEXT NOT NULL,
+    private_key BLOB NOT NULL, -- sealed by the secretbox package
+    ca_bundle TEXT,
+    subject TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    not_after TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000Z'),
+    UNIQUE (user_id, name)
+);
+
+ALTER TABLE jobs ADD COLUMN client_certificate_id TEXT REFERENCES client_certificates(id);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_client_certificate_id ON jobs(client_certificate_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_jobs_client_certificate_id;
+ALTER TABLE jobs DROP COLUMN client_certificate_id;
+DROP TABLE IF EXISTS client_certificates;
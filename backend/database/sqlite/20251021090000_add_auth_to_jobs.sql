@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE jobs ADD COLUMN auth TEXT NOT NULL DEFAULT '{}'; -- JSON object
+ALTER TABLE jobs ADD COLUMN auth_secret BLOB;
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN auth_secret;
+ALTER TABLE jobs DROP COLUMN auth;
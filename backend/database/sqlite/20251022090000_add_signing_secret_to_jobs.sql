@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE jobs ADD COLUMN signing_secret BLOB;
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN signing_secret;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE jobs ADD COLUMN redirects TEXT NOT NULL DEFAULT '{}'; -- JSON object
+ALTER TABLE job_logs ADD COLUMN redirects TEXT; -- JSON array
+
+-- +goose Down
+ALTER TABLE job_logs DROP COLUMN redirects;
+ALTER TABLE jobs DROP COLUMN redirects;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE jobs ADD COLUMN type TEXT NOT NULL DEFAULT 'http';
+ALTER TABLE jobs ADD COLUMN config TEXT NOT NULL DEFAULT '{}'; -- JSON object
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN config;
+ALTER TABLE jobs DROP COLUMN type;
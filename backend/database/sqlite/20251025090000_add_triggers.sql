@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE jobs ADD COLUMN triggers TEXT NOT NULL DEFAULT '{}'; -- JSON object
+ALTER TABLE job_logs ADD COLUMN run_group TEXT;
+CREATE INDEX IF NOT EXISTS idx_job_logs_run_group ON job_logs(run_group);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_job_logs_run_group;
+ALTER TABLE job_logs DROP COLUMN run_group;
+ALTER TABLE jobs DROP COLUMN triggers;
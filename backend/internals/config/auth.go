@@ -1,31 +1,51 @@
-package config 
+package config
 
 import (
-	"os"
 	"golang.org/x/oauth2"
-    "golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/google"
 )
-// auth config struct 
-type AuthConfig struct {
-	GooglelOauthConfig *oauth2.Config
-	JWTSecret string
-}
 
+// auth config struct
+type AuthConfig struct {
+	GoogleClientID     string `yaml:"google_client_id"`     // GOOGLE_CLIENT_ID
+	GoogleClientSecret string `yaml:"google_client_secret"` // GOOGLE_CLIENT_SECRET
+	GoogleRedirectURL  string `yaml:"google_redirect_url"`  // GOOGLE_REDIRECT_URL
+	JWTSecret          string `yaml:"jwt_secret"`           // JWT_SECRET
+	FrontendURL        string `yaml:"frontend_url"`         // FRONTEND_URL, where login redirects to
 
-func LoadAuthConfig() *AuthConfig{
-	return &AuthConfig{
-		GooglelOauthConfig: &oauth2.Config{
-			ClientID: os.Getenv("GOOGLE_CLIENT_ID"),
-			ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-			RedirectURL: os.Getenv("GOOGLE_REDIRECT_URL"),
-			Scopes: []string{
-				"https://www.googleapis.com/auth/userinfo.email",
-                "https://www.googleapis.com/auth/userinfo.profile",
-			},
-			Endpoint: google.Endpoint,
+	// GooglelOauthConfig is built from the fields above by Load.
+	GooglelOauthConfig *oauth2.Config `yaml:"-"`
+}
 
+func newGoogleOAuthConfig(a AuthConfig) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     a.GoogleClientID,
+		ClientSecret: a.GoogleClientSecret,
+		RedirectURL:  a.GoogleRedirectURL,
+		Scopes: []string{
+			"https://www.googleapis.com/auth/userinfo.email",
+			"https://www.googleapis.com/auth/userinfo.profile",
 		},
-		JWTSecret: os.Getenv("JWT_SECRET"),
+		Endpoint: google.Endpoint,
 	}
 }
 
+func (a *AuthConfig) problems() []string {
+	var p []string
+	if a.JWTSecret == "" {
+		p = append(p, "auth.jwt_secret is required (JWT_SECRET)")
+	}
+	if a.GoogleClientID == "" {
+		p = append(p, "auth.google_client_id is required (GOOGLE_CLIENT_ID)")
+	}
+	if a.GoogleClientSecret == "" {
+		p = append(p, "auth.google_client_secret is required (GOOGLE_CLIENT_SECRET)")
+	}
+	if a.GoogleRedirectURL == "" {
+		p = append(p, "auth.google_redirect_url is required (GOOGLE_REDIRECT_URL)")
+	}
+	if a.FrontendURL == "" {
+		p = append(p, "auth.frontend_url is required (FRONTEND_URL)")
+	}
+	return p
+}
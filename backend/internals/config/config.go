@@ -0,0 +1,426 @@
+// Package config loads the server configuration into one typed struct.
+//
+// Values come from, in increasing order of precedence: built-in defaults,
+// an optional YAML file (config.yaml unless another path is given), and
+// environment variables. The environment variable for each field is noted
+// next to it.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cronix.ashutosh.net/internals/netguard"
+	"cronix.ashutosh.net/internals/secretbox"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is read when no config file is named; it may be absent.
+const DefaultPath = "config.yaml"
+
+type Config struct {
+	Server     ServerConfig     `yaml:"server"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Auth       AuthConfig       `yaml:"auth"`
+	CORS       CORSConfig       `yaml:"cors"`
+	Scheduler  SchedulerConfig  `yaml:"scheduler"`
+	HTTPClient HTTPClientConfig `yaml:"http_client"`
+	Retention  RetentionConfig  `yaml:"retention"`
+	Secrets    SecretsConfig    `yaml:"secrets"`
+	Metrics    MetricsConfig    `yaml:"metrics"`
+}
+
+type ServerConfig struct {
+	Port                string        `yaml:"port"`                  // PORT
+	ShutdownDrainPeriod time.Duration `yaml:"shutdown_drain_period"` // SHUTDOWN_DRAIN_PERIOD
+	MigrateOnBoot       string        `yaml:"migrate_on_boot"`       // MIGRATE_ON_BOOT: off, verify or up; up on SQLite and off on Postgres when unset
+}
+
+type DatabaseConfig struct {
+	Driver   string `yaml:"driver"`    // DB_DRIVER: postgres or sqlite
+	Path     string `yaml:"path"`      // DB_PATH, the SQLite database file
+	URL      string `yaml:"url"`       // DATABASE_URL, used as is when set
+	Host     string `yaml:"host"`      // DB_HOST
+	Port     string `yaml:"port"`      // DB_PORT
+	User     string `yaml:"user"`      // DB_USER
+	Password string `yaml:"password"`  // DB_PASSWORD
+	Name     string `yaml:"name"`      // DB_NAME
+	SSLMode  string `yaml:"sslmode"`   // DB_SSLMODE
+	MaxConns int32  `yaml:"max_conns"` // DB_MAX_CONNS, 0 keeps the pgxpool default
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"` // ALLOWED_ORIGINS, comma-separated
+}
+
+type SchedulerConfig struct {
+	RunTimeout time.Duration `yaml:"run_timeout"` // SCHEDULER_RUN_TIMEOUT
+}
+
+type HTTPClientConfig struct {
+	Timeout          time.Duration `yaml:"timeout"`            // HTTP_CLIENT_TIMEOUT, for endpoint tests
+	MaxResponseBytes int64         `yaml:"max_response_bytes"` // HTTP_CLIENT_MAX_RESPONSE_BYTES
+
+	// AllowedNetworks exempts CIDRs or addresses from the block on
+	// loopback, link-local, private and metadata destinations.
+	AllowedNetworks []string `yaml:"allowed_networks"` // HTTP_CLIENT_ALLOWED_NETWORKS, comma-separated
+
+	MaxIdleConns        int           `yaml:"max_idle_conns"`          // HTTP_CLIENT_MAX_IDLE_CONNS
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host"` // HTTP_CLIENT_MAX_IDLE_CONNS_PER_HOST
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout"`       // HTTP_CLIENT_IDLE_CONN_TIMEOUT
+	HTTP2               bool          `yaml:"http2"`                   // HTTP_CLIENT_HTTP2
+	Proxy               string        `yaml:"proxy"`                   // HTTP_CLIENT_PROXY, for http and https targets
+	NoProxy             string        `yaml:"no_proxy"`                // HTTP_CLIENT_NO_PROXY, hosts reached directly
+	CAFile              string        `yaml:"ca_file"`                 // HTTP_CLIENT_CA_FILE, PEM trusted besides the system roots
+	TLSMinVersion       string        `yaml:"tls_min_version"`         // HTTP_CLIENT_TLS_MIN_VERSION: 1.0, 1.1, 1.2 or 1.3
+	MaxTransports       int           `yaml:"max_transports"`          // HTTP_CLIENT_MAX_TRANSPORTS, kept for distinct job transport settings
+}
+
+type RetentionConfig struct {
+	LogsPerJob int32 `yaml:"logs_per_job"` // LOG_RETENTION_PER_JOB
+}
+
+type SecretsConfig struct {
+	// Key encrypts secrets stored in the database, such as the private keys
+	// of client certificates, job auth secrets and request signing secrets;
+	// those features are off while it is unset.
+	Key string `yaml:"key"` // SECRETS_KEY, base64 of 32 random bytes
+}
+
+type MetricsConfig struct {
+	// Token must be presented as a bearer token to scrape /metrics, which
+	// is not served while it is unset.
+	Token string `yaml:"token"` // METRICS_TOKEN
+}
+
+func defaults() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:                "8080",
+			ShutdownDrainPeriod: 30 * time.Second,
+		},
+		Database:  DatabaseConfig{Driver: "postgres", Path: "cronix.db", Port: "5432", SSLMode: "disable"},
+		Auth:      AuthConfig{FrontendURL: "http://localhost:5173"},
+		Scheduler: SchedulerConfig{RunTimeout: 30 * time.Second},
+		HTTPClient: HTTPClientConfig{
+			Timeout:             30 * time.Second,
+			MaxResponseBytes:    1 << 20,
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+			HTTP2:               true,
+			TLSMinVersion:       "1.2",
+			MaxTransports:       64,
+		},
+		Retention: RetentionConfig{LogsPerJob: 5},
+	}
+}
+
+// Load reads the configuration from path, or from DefaultPath if path is
+// empty and that file exists, and applies environment overrides. It does
+// not validate; call Validate before using the result.
+func Load(path string) (*Config, error) {
+	cfg := defaults()
+
+	explicit := path != ""
+	if !explicit {
+		path = DefaultPath
+	}
+	raw, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(raw, cfg); err != nil {
+			return nil, fmt.Errorf("config: parse %s: %w", path, err)
+		}
+	case explicit || !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	var problems []string
+	env := envReader{problems: &problems}
+	env.str("PORT", &cfg.Server.Port)
+	env.duration("SHUTDOWN_DRAIN_PERIOD", &cfg.Server.ShutdownDrainPeriod)
+	env.str("MIGRATE_ON_BOOT", &cfg.Server.MigrateOnBoot)
+
+	env.str("DB_DRIVER", &cfg.Database.Driver)
+	env.str("DB_PATH", &cfg.Database.Path)
+	env.str("DATABASE_URL", &cfg.Database.URL)
+	env.str("DB_HOST", &cfg.Database.Host)
+	env.str("DB_PORT", &cfg.Database.Port)
+	env.str("DB_USER", &cfg.Database.User)
+	env.str("DB_PASSWORD", &cfg.Database.Password)
+	env.str("DB_NAME", &cfg.Database.Name)
+	env.str("DB_SSLMODE", &cfg.Database.SSLMode)
+	env.int32("DB_MAX_CONNS", &cfg.Database.MaxConns)
+
+	env.str("GOOGLE_CLIENT_ID", &cfg.Auth.GoogleClientID)
+	env.str("GOOGLE_CLIENT_SECRET", &cfg.Auth.GoogleClientSecret)
+	env.str("GOOGLE_REDIRECT_URL", &cfg.Auth.GoogleRedirectURL)
+	env.str("JWT_SECRET", &cfg.Auth.JWTSecret)
+	env.str("FRONTEND_URL", &cfg.Auth.FrontendURL)
+
+	env.list("ALLOWED_ORIGINS", &cfg.CORS.AllowedOrigins)
+
+	env.duration("SCHEDULER_RUN_TIMEOUT", &cfg.Scheduler.RunTimeout)
+
+	env.duration("HTTP_CLIENT_TIMEOUT", &cfg.HTTPClient.Timeout)
+	env.int64("HTTP_CLIENT_MAX_RESPONSE_BYTES", &cfg.HTTPClient.MaxResponseBytes)
+	env.list("HTTP_CLIENT_ALLOWED_NETWORKS", &cfg.HTTPClient.AllowedNetworks)
+	env.int("HTTP_CLIENT_MAX_IDLE_CONNS", &cfg.HTTPClient.MaxIdleConns)
+	env.int("HTTP_CLIENT_MAX_IDLE_CONNS_PER_HOST", &cfg.HTTPClient.MaxIdleConnsPerHost)
+	env.duration("HTTP_CLIENT_IDLE_CONN_TIMEOUT", &cfg.HTTPClient.IdleConnTimeout)
+	env.bool("HTTP_CLIENT_HTTP2", &cfg.HTTPClient.HTTP2)
+	env.str("HTTP_CLIENT_PROXY", &cfg.HTTPClient.Proxy)
+	env.str("HTTP_CLIENT_NO_PROXY", &cfg.HTTPClient.NoProxy)
+	env.str("HTTP_CLIENT_CA_FILE", &cfg.HTTPClient.CAFile)
+	env.str("HTTP_CLIENT_TLS_MIN_VERSION", &cfg.HTTPClient.TLSMinVersion)
+	env.int("HTTP_CLIENT_MAX_TRANSPORTS", &cfg.HTTPClient.MaxTransports)
+
+	env.int32("LOG_RETENTION_PER_JOB", &cfg.Retention.LogsPerJob)
+
+	env.str("SECRETS_KEY", &cfg.Secrets.Key)
+
+	env.str("METRICS_TOKEN", &cfg.Metrics.Token)
+
+	if len(problems) > 0 {
+		return nil, invalid(problems)
+	}
+	if cfg.Server.MigrateOnBoot == "" {
+		// A SQLite file is usually created by the server itself, so it
+		// brings its schema along unless told otherwise
+		cfg.Server.MigrateOnBoot = "off"
+		if cfg.Database.Driver == "sqlite" {
+			cfg.Server.MigrateOnBoot = "up"
+		}
+	}
+	cfg.Auth.GooglelOauthConfig = newGoogleOAuthConfig(cfg.Auth)
+	return cfg, nil
+}
+
+// Validate reports every missing or out-of-range value at once.
+func (c *Config) Validate() error {
+	var p []string
+	p = append(p, c.Database.problems()...)
+	p = append(p, c.Auth.problems()...)
+	switch c.Server.MigrateOnBoot {
+	case "off", "verify", "up":
+	default:
+		p = append(p, "server.migrate_on_boot must be off, verify or up (MIGRATE_ON_BOOT)")
+	}
+	if c.Server.Port == "" {
+		p = append(p, "server.port is required (PORT)")
+	}
+	if c.Server.ShutdownDrainPeriod < 0 {
+		p = append(p, "server.shutdown_drain_period must not be negative (SHUTDOWN_DRAIN_PERIOD)")
+	}
+	for _, o := range c.CORS.AllowedOrigins {
+		if u, err := url.Parse(o); err != nil || u.Scheme == "" || u.Host == "" {
+			p = append(p, fmt.Sprintf("cors.allowed_origins: %q is not an origin like https://example.com (ALLOWED_ORIGINS)", o))
+		}
+	}
+	if c.Scheduler.RunTimeout <= 0 {
+		p = append(p, "scheduler.run_timeout must be positive (SCHEDULER_RUN_TIMEOUT)")
+	}
+	if c.HTTPClient.Timeout <= 0 {
+		p = append(p, "http_client.timeout must be positive (HTTP_CLIENT_TIMEOUT)")
+	}
+	if c.HTTPClient.MaxResponseBytes <= 0 {
+		p = append(p, "http_client.max_response_bytes must be positive (HTTP_CLIENT_MAX_RESPONSE_BYTES)")
+	}
+	if c.HTTPClient.MaxIdleConns < 0 || c.HTTPClient.MaxIdleConnsPerHost < 0 || c.HTTPClient.IdleConnTimeout < 0 {
+		p = append(p, "http_client idle connection settings must not be negative")
+	}
+	if c.HTTPClient.MaxTransports <= 0 {
+		p = append(p, "http_client.max_transports must be positive (HTTP_CLIENT_MAX_TRANSPORTS)")
+	}
+	switch c.HTTPClient.TLSMinVersion {
+	case "1.0", "1.1", "1.2", "1.3":
+	default:
+		p = append(p, "http_client.tls_min_version must be 1.0, 1.1, 1.2 or 1.3 (HTTP_CLIENT_TLS_MIN_VERSION)")
+	}
+	if c.HTTPClient.Proxy != "" {
+		if u, err := url.Parse(c.HTTPClient.Proxy); err != nil || u.Host == "" {
+			p = append(p, fmt.Sprintf("http_client.proxy: %q is not a URL like http://proxy:3128 (HTTP_CLIENT_PROXY)", c.HTTPClient.Proxy))
+		}
+	}
+	for _, n := range c.HTTPClient.AllowedNetworks {
+		if _, err := netguard.ParsePrefix(n); err != nil {
+			p = append(p, fmt.Sprintf("http_client.allowed_networks: %v (HTTP_CLIENT_ALLOWED_NETWORKS)", err))
+		}
+	}
+	if c.Retention.LogsPerJob < 1 {
+		p = append(p, "retention.logs_per_job must be at least 1 (LOG_RETENTION_PER_JOB)")
+	}
+	if c.Secrets.Key != "" {
+		if _, err := secretbox.ParseKey(c.Secrets.Key); err != nil {
+			p = append(p, fmt.Sprintf("secrets.key: %v (SECRETS_KEY)", err))
+		}
+	}
+	if len(p) > 0 {
+		return invalid(p)
+	}
+	return nil
+}
+
+// Validate checks only the database settings, for commands such as
+// migrate that do not serve requests.
+func (d *DatabaseConfig) Validate() error {
+	if p := d.problems(); len(p) > 0 {
+		return invalid(p)
+	}
+	return nil
+}
+
+func (d *DatabaseConfig) problems() []string {
+	switch d.Driver {
+	case "postgres":
+	case "sqlite":
+		if d.Path == "" {
+			return []string{"database.path is required for the sqlite driver (DB_PATH)"}
+		}
+		return nil
+	default:
+		return []string{fmt.Sprintf("database.driver must be postgres or sqlite, got %q (DB_DRIVER)", d.Driver)}
+	}
+	if d.URL != "" {
+		return nil
+	}
+	var p []string
+	if d.Host == "" {
+		p = append(p, "database.host is required unless database.url is set (DB_HOST or DATABASE_URL)")
+	}
+	if d.User == "" {
+		p = append(p, "database.user is required unless database.url is set (DB_USER or DATABASE_URL)")
+	}
+	if d.Name == "" {
+		p = append(p, "database.name is required unless database.url is set (DB_NAME or DATABASE_URL)")
+	}
+	return p
+}
+
+// DSN returns URL, or a postgres:// URL assembled from the other fields.
+func (d *DatabaseConfig) DSN() string {
+	if d.URL != "" {
+		return d.URL
+	}
+	user := url.User(d.User)
+	if d.Password != "" {
+		user = url.UserPassword(d.User, d.Password)
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     user,
+		Host:     d.Host + ":" + d.Port,
+		Path:     "/" + d.Name,
+		RawQuery: url.Values{"sslmode": {d.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+// AllowedOrigins returns the CORS origins, always including the frontend.
+func (c *Config) AllowedOrigins() []string {
+	origins := append([]string(nil), c.CORS.AllowedOrigins...)
+	if c.Auth.FrontendURL != "" {
+		origins = append(origins, c.Auth.FrontendURL)
+	}
+	return origins
+}
+
+func invalid(problems []string) error {
+	return errors.New("config: invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+}
+
+// envReader overrides fields from set environment variables and collects
+// values that do not parse.
+type envReader struct {
+	problems *[]string
+}
+
+func (e envReader) lookup(name string) (string, bool) {
+	v, ok := os.LookupEnv(name)
+	return strings.TrimSpace(v), ok && strings.TrimSpace(v) != ""
+}
+
+func (e envReader) fail(name, v, want string) {
+	*e.problems = append(*e.problems, fmt.Sprintf("%s=%q is not %s", name, v, want))
+}
+
+func (e envReader) str(name string, dst *string) {
+	if v, ok := e.lookup(name); ok {
+		*dst = v
+	}
+}
+
+func (e envReader) list(name string, dst *[]string) {
+	v, ok := e.lookup(name)
+	if !ok {
+		return
+	}
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	*dst = out
+}
+
+func (e envReader) duration(name string, dst *time.Duration) {
+	if v, ok := e.lookup(name); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			e.fail(name, v, "a duration such as 30s")
+			return
+		}
+		*dst = d
+	}
+}
+
+func (e envReader) bool(name string, dst *bool) {
+	if v, ok := e.lookup(name); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			e.fail(name, v, "true or false")
+			return
+		}
+		*dst = b
+	}
+}
+
+func (e envReader) int(name string, dst *int) {
+	if v, ok := e.lookup(name); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			e.fail(name, v, "an integer")
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e envReader) int32(name string, dst *int32) {
+	if v, ok := e.lookup(name); ok {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			e.fail(name, v, "an integer")
+			return
+		}
+		*dst = int32(n)
+	}
+}
+
+func (e envReader) int64(name string, dst *int64) {
+	if v, ok := e.lookup(name); ok {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			e.fail(name, v, "an integer")
+			return
+		}
+		*dst = n
+	}
+}
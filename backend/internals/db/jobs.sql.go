@@ -20,30 +20,41 @@ WHERE id NOT IN (
                ROW_NUMBER() OVER (PARTITION BY job_id ORDER BY started_at DESC) as rn
         FROM job_logs
     ) ranked
-    WHERE rn <= 5
+    WHERE rn <= $1::int
 )
 `
 
-func (q *Queries) CleanupAllOldLogs(ctx context.Context) error {
-	_, err := q.db.Exec(ctx, cleanupAllOldLogs)
+func (q *Queries) CleanupAllOldLogs(ctx context.Context, keep int32) error {
+	_, err := q.db.Exec(ctx, cleanupAllOldLogs, keep)
 	return err
 }
 
 const createJob = `-- name: CreateJob :one
-INSERT INTO jobs (user_id, name, schedule, endpoint, method, headers, body, active)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at
+INSERT INTO jobs (user_id, name, schedule, endpoint, method, headers, body, active, transport, client_certificate_id, auth, auth_secret, redirects, type, config, triggers)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, COALESCE($9::jsonb, '{}'::jsonb), $10,
+  COALESCE($11::jsonb, '{}'::jsonb), $12, COALESCE($13::jsonb, '{}'::jsonb),
+  COALESCE(NULLIF($14::text, ''), 'http'), COALESCE($15::jsonb, '{}'::jsonb),
+  COALESCE($16::jsonb, '{}'::jsonb))
+RETURNING id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at, transport, client_certificate_id, auth, auth_secret, signing_secret, redirects, type, config, triggers
 `
 
 type CreateJobParams struct {
-	UserID   pgtype.UUID `json:"user_id"`
-	Name     string      `json:"name"`
-	Schedule string      `json:"schedule"`
-	Endpoint string      `json:"endpoint"`
-	Method   string      `json:"method"`
-	Headers  []byte      `json:"headers"`
-	Body     pgtype.Text `json:"body"`
-	Active   bool        `json:"active"`
+	UserID              pgtype.UUID `json:"user_id"`
+	Name                string      `json:"name"`
+	Schedule            string      `json:"schedule"`
+	Endpoint            string      `json:"endpoint"`
+	Method              string      `json:"method"`
+	Headers             []byte      `json:"headers"`
+	Body                pgtype.Text `json:"body"`
+	Active              bool        `json:"active"`
+	Transport           []byte      `json:"transport"`
+	ClientCertificateID pgtype.UUID `json:"client_certificate_id"`
+	Auth                []byte      `json:"auth"`
+	AuthSecret          []byte      `json:"-"`
+	Redirects           []byte      `json:"redirects"`
+	Type                string      `json:"type"`
+	Config              []byte      `json:"config"`
+	Triggers            []byte      `json:"triggers"`
 }
 
 func (q *Queries) CreateJob(ctx context.Context, arg CreateJobParams) (Job, error) {
@@ -56,6 +67,14 @@ func (q *Queries) CreateJob(ctx context.Context, arg CreateJobParams) (Job, erro
 		arg.Headers,
 		arg.Body,
 		arg.Active,
+		arg.Transport,
+		arg.ClientCertificateID,
+		arg.Auth,
+		arg.AuthSecret,
+		arg.Redirects,
+		arg.Type,
+		arg.Config,
+		arg.Triggers,
 	)
 	var i Job
 	err := row.Scan(
@@ -70,6 +89,15 @@ func (q *Queries) CreateJob(ctx context.Context, arg CreateJobParams) (Job, erro
 		&i.Active,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Transport,
+		&i.ClientCertificateID,
+		&i.Auth,
+		&i.AuthSecret,
+		&i.SigningSecret,
+		&i.Redirects,
+		&i.Type,
+		&i.Config,
+		&i.Triggers,
 	)
 	return i, err
 }
@@ -91,17 +119,22 @@ AND outer_logs.started_at < (
     FROM job_logs AS inner_logs
     WHERE inner_logs.job_id = $1
     ORDER BY inner_logs.started_at DESC
-    LIMIT 1 OFFSET 4
+    LIMIT 1 OFFSET $2::int - 1
 )
 `
 
-func (q *Queries) DeleteOldJobLogs(ctx context.Context, jobID pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, deleteOldJobLogs, jobID)
+type DeleteOldJobLogsParams struct {
+	JobID pgtype.UUID `json:"job_id"`
+	Keep  int32       `json:"keep"`
+}
+
+func (q *Queries) DeleteOldJobLogs(ctx context.Context, arg DeleteOldJobLogsParams) error {
+	_, err := q.db.Exec(ctx, deleteOldJobLogs, arg.JobID, arg.Keep)
 	return err
 }
 
 const getJob = `-- name: GetJob :one
-SELECT id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at FROM jobs WHERE id = $1
+SELECT id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at, transport, client_certificate_id, auth, auth_secret, signing_secret, redirects, type, config, triggers FROM jobs WHERE id = $1
 `
 
 func (q *Queries) GetJob(ctx context.Context, id pgtype.UUID) (Job, error) {
@@ -119,14 +152,23 @@ func (q *Queries) GetJob(ctx context.Context, id pgtype.UUID) (Job, error) {
 		&i.Active,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Transport,
+		&i.ClientCertificateID,
+		&i.Auth,
+		&i.AuthSecret,
+		&i.SigningSecret,
+		&i.Redirects,
+		&i.Type,
+		&i.Config,
+		&i.Triggers,
 	)
 	return i, err
 }
 
 const insertJobLog = `-- name: InsertJobLog :one
-INSERT INTO job_logs (job_id, started_at, finished_at, duration_ms, status, response_code, error, response_body)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, job_id, started_at, finished_at, duration_ms, status, response_code, error, response_body
+INSERT INTO job_logs (job_id, started_at, finished_at, duration_ms, status, response_code, error, response_body, dns_ms, connect_ms, tls_ms, ttfb_ms, download_ms, redirects, run_group)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+RETURNING id, job_id, started_at, finished_at, duration_ms, status, response_code, error, response_body, dns_ms, connect_ms, tls_ms, ttfb_ms, download_ms, redirects, run_group
 `
 
 type InsertJobLogParams struct {
@@ -138,6 +180,13 @@ type InsertJobLogParams struct {
 	ResponseCode pgtype.Int4        `json:"response_code"`
 	Error        pgtype.Text        `json:"error"`
 	ResponseBody pgtype.Text        `json:"response_body"`
+	DnsMs        pgtype.Int4        `json:"dns_ms"`
+	ConnectMs    pgtype.Int4        `json:"connect_ms"`
+	TlsMs        pgtype.Int4        `json:"tls_ms"`
+	TtfbMs       pgtype.Int4        `json:"ttfb_ms"`
+	DownloadMs   pgtype.Int4        `json:"download_ms"`
+	Redirects    []byte             `json:"redirects"`
+	RunGroup     pgtype.Text        `json:"run_group"`
 }
 
 func (q *Queries) InsertJobLog(ctx context.Context, arg InsertJobLogParams) (JobLog, error) {
@@ -150,6 +199,13 @@ func (q *Queries) InsertJobLog(ctx context.Context, arg InsertJobLogParams) (Job
 		arg.ResponseCode,
 		arg.Error,
 		arg.ResponseBody,
+		arg.DnsMs,
+		arg.ConnectMs,
+		arg.TlsMs,
+		arg.TtfbMs,
+		arg.DownloadMs,
+		arg.Redirects,
+		arg.RunGroup,
 	)
 	var i JobLog
 	err := row.Scan(
@@ -162,12 +218,19 @@ func (q *Queries) InsertJobLog(ctx context.Context, arg InsertJobLogParams) (Job
 		&i.ResponseCode,
 		&i.Error,
 		&i.ResponseBody,
+		&i.DnsMs,
+		&i.ConnectMs,
+		&i.TlsMs,
+		&i.TtfbMs,
+		&i.DownloadMs,
+		&i.Redirects,
+		&i.RunGroup,
 	)
 	return i, err
 }
 
 const listActiveJobs = `-- name: ListActiveJobs :many
-SELECT id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at FROM jobs 
+SELECT id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at, transport, client_certificate_id, auth, auth_secret, signing_secret, redirects, type, config, triggers FROM jobs 
 WHERE active = true 
 ORDER BY created_at DESC
 `
@@ -193,6 +256,62 @@ func (q *Queries) ListActiveJobs(ctx context.Context) ([]Job, error) {
 			&i.Active,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Transport,
+			&i.ClientCertificateID,
+			&i.Auth,
+			&i.AuthSecret,
+			&i.SigningSecret,
+			&i.Redirects,
+			&i.Type,
+			&i.Config,
+			&i.Triggers,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllJobsByUser = `-- name: ListAllJobsByUser :many
+SELECT id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at, transport, client_certificate_id, auth, auth_secret, signing_secret, redirects, type, config, triggers FROM jobs
+WHERE user_id = $1
+ORDER BY name ASC, created_at ASC
+`
+
+func (q *Queries) ListAllJobsByUser(ctx context.Context, userID pgtype.UUID) ([]Job, error) {
+	rows, err := q.db.Query(ctx, listAllJobsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Job{}
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Name,
+			&i.Schedule,
+			&i.Endpoint,
+			&i.Method,
+			&i.Headers,
+			&i.Body,
+			&i.Active,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Transport,
+			&i.ClientCertificateID,
+			&i.Auth,
+			&i.AuthSecret,
+			&i.SigningSecret,
+			&i.Redirects,
+			&i.Type,
+			&i.Config,
+			&i.Triggers,
 		); err != nil {
 			return nil, err
 		}
@@ -205,7 +324,7 @@ func (q *Queries) ListActiveJobs(ctx context.Context) ([]Job, error) {
 }
 
 const listJobLogs = `-- name: ListJobLogs :many
-SELECT id, job_id, started_at, finished_at, duration_ms, status, response_code, error, response_body
+SELECT id, job_id, started_at, finished_at, duration_ms, status, response_code, error, response_body, dns_ms, connect_ms, tls_ms, ttfb_ms, download_ms, redirects, run_group
 FROM job_logs
 WHERE job_id = $1
 ORDER BY started_at DESC
@@ -237,6 +356,13 @@ func (q *Queries) ListJobLogs(ctx context.Context, arg ListJobLogsParams) ([]Job
 			&i.ResponseCode,
 			&i.Error,
 			&i.ResponseBody,
+			&i.DnsMs,
+			&i.ConnectMs,
+			&i.TlsMs,
+			&i.TtfbMs,
+			&i.DownloadMs,
+			&i.Redirects,
+			&i.RunGroup,
 		); err != nil {
 			return nil, err
 		}
@@ -249,7 +375,7 @@ func (q *Queries) ListJobLogs(ctx context.Context, arg ListJobLogsParams) ([]Job
 }
 
 const listJobsByUser = `-- name: ListJobsByUser :many
-SELECT id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at FROM jobs
+SELECT id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at, transport, client_certificate_id, auth, auth_secret, signing_secret, redirects, type, config, triggers FROM jobs
 WHERE user_id = $1
 ORDER BY created_at DESC
 LIMIT $2 OFFSET $3
@@ -282,6 +408,15 @@ func (q *Queries) ListJobsByUser(ctx context.Context, arg ListJobsByUserParams)
 			&i.Active,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Transport,
+			&i.ClientCertificateID,
+			&i.Auth,
+			&i.AuthSecret,
+			&i.SigningSecret,
+			&i.Redirects,
+			&i.Type,
+			&i.Config,
+			&i.Triggers,
 		); err != nil {
 			return nil, err
 		}
@@ -294,15 +429,20 @@ func (q *Queries) ListJobsByUser(ctx context.Context, arg ListJobsByUserParams)
 }
 
 const listRecentJobLogs = `-- name: ListRecentJobLogs :many
-SELECT id, job_id, started_at, finished_at, duration_ms, status, response_code, error, response_body
+SELECT id, job_id, started_at, finished_at, duration_ms, status, response_code, error, response_body, dns_ms, connect_ms, tls_ms, ttfb_ms, download_ms, redirects, run_group
 FROM job_logs
 WHERE job_id = $1
 ORDER BY started_at DESC
-LIMIT 5
+LIMIT $2
 `
 
-func (q *Queries) ListRecentJobLogs(ctx context.Context, jobID pgtype.UUID) ([]JobLog, error) {
-	rows, err := q.db.Query(ctx, listRecentJobLogs, jobID)
+type ListRecentJobLogsParams struct {
+	JobID pgtype.UUID `json:"job_id"`
+	Limit int32       `json:"limit"`
+}
+
+func (q *Queries) ListRecentJobLogs(ctx context.Context, arg ListRecentJobLogsParams) ([]JobLog, error) {
+	rows, err := q.db.Query(ctx, listRecentJobLogs, arg.JobID, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -320,6 +460,13 @@ func (q *Queries) ListRecentJobLogs(ctx context.Context, jobID pgtype.UUID) ([]J
 			&i.ResponseCode,
 			&i.Error,
 			&i.ResponseBody,
+			&i.DnsMs,
+			&i.ConnectMs,
+			&i.TlsMs,
+			&i.TtfbMs,
+			&i.DownloadMs,
+			&i.Redirects,
+			&i.RunGroup,
 		); err != nil {
 			return nil, err
 		}
@@ -331,6 +478,86 @@ func (q *Queries) ListRecentJobLogs(ctx context.Context, jobID pgtype.UUID) ([]J
 	return items, nil
 }
 
+const setJobSigningSecret = `-- name: SetJobSigningSecret :one
+UPDATE jobs
+SET signing_secret = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at, transport, client_certificate_id, auth, auth_secret, signing_secret, redirects, type, config, triggers
+`
+
+type SetJobSigningSecretParams struct {
+	ID            pgtype.UUID `json:"id"`
+	SigningSecret []byte      `json:"-"`
+}
+
+func (q *Queries) SetJobSigningSecret(ctx context.Context, arg SetJobSigningSecretParams) (Job, error) {
+	row := q.db.QueryRow(ctx, setJobSigningSecret, arg.ID, arg.SigningSecret)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.Schedule,
+		&i.Endpoint,
+		&i.Method,
+		&i.Headers,
+		&i.Body,
+		&i.Active,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Transport,
+		&i.ClientCertificateID,
+		&i.Auth,
+		&i.AuthSecret,
+		&i.SigningSecret,
+		&i.Redirects,
+		&i.Type,
+		&i.Config,
+		&i.Triggers,
+	)
+	return i, err
+}
+
+const setJobTriggers = `-- name: SetJobTriggers :one
+UPDATE jobs
+SET triggers = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at, transport, client_certificate_id, auth, auth_secret, signing_secret, redirects, type, config, triggers
+`
+
+type SetJobTriggersParams struct {
+	ID       pgtype.UUID `json:"id"`
+	Triggers []byte      `json:"triggers"`
+}
+
+func (q *Queries) SetJobTriggers(ctx context.Context, arg SetJobTriggersParams) (Job, error) {
+	row := q.db.QueryRow(ctx, setJobTriggers, arg.ID, arg.Triggers)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.Schedule,
+		&i.Endpoint,
+		&i.Method,
+		&i.Headers,
+		&i.Body,
+		&i.Active,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Transport,
+		&i.ClientCertificateID,
+		&i.Auth,
+		&i.AuthSecret,
+		&i.SigningSecret,
+		&i.Redirects,
+		&i.Type,
+		&i.Config,
+		&i.Triggers,
+	)
+	return i, err
+}
+
 const updateJob = `-- name: UpdateJob :one
 UPDATE jobs
 SET
@@ -340,21 +567,40 @@ SET
   method = COALESCE(NULLIF($5, ''), method),
   headers = COALESCE($6, headers),
   body = COALESCE($7, body),
-  active = COALESCE($8, active),
+  active = COALESCE($8::bool, active),
+  transport = COALESCE($9::jsonb, transport),
+  client_certificate_id = CASE WHEN $10::bool
+    THEN $11::uuid ELSE client_certificate_id END,
+  auth = COALESCE($12::jsonb, auth),
+  auth_secret = CASE WHEN $12::jsonb IS NULL
+    THEN auth_secret ELSE $13::bytea END,
+  redirects = COALESCE($14::jsonb, redirects),
+  type = COALESCE(NULLIF($15::text, ''), type),
+  config = COALESCE($16::jsonb, config),
+  triggers = COALESCE($17::jsonb, triggers),
   updated_at = NOW()
 WHERE id = $1
-RETURNING id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at
+RETURNING id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at, transport, client_certificate_id, auth, auth_secret, signing_secret, redirects, type, config, triggers
 `
 
 type UpdateJobParams struct {
-	ID      pgtype.UUID `json:"id"`
-	Column2 interface{} `json:"column_2"`
-	Column3 interface{} `json:"column_3"`
-	Column4 interface{} `json:"column_4"`
-	Column5 interface{} `json:"column_5"`
-	Headers []byte      `json:"headers"`
-	Body    pgtype.Text `json:"body"`
-	Active  bool        `json:"active"`
+	ID                   pgtype.UUID `json:"id"`
+	Column2              interface{} `json:"column_2"`
+	Column3              interface{} `json:"column_3"`
+	Column4              interface{} `json:"column_4"`
+	Column5              interface{} `json:"column_5"`
+	Headers              []byte      `json:"headers"`
+	Body                 pgtype.Text `json:"body"`
+	Active               pgtype.Bool `json:"active"`
+	Transport            []byte      `json:"transport"`
+	SetClientCertificate bool        `json:"set_client_certificate"`
+	ClientCertificateID  pgtype.UUID `json:"client_certificate_id"`
+	Auth                 []byte      `json:"auth"`
+	AuthSecret           []byte      `json:"auth_secret"`
+	Redirects            []byte      `json:"redirects"`
+	Type                 string      `json:"type"`
+	Config               []byte      `json:"config"`
+	Triggers             []byte      `json:"triggers"`
 }
 
 func (q *Queries) UpdateJob(ctx context.Context, arg UpdateJobParams) (Job, error) {
@@ -367,6 +613,15 @@ func (q *Queries) UpdateJob(ctx context.Context, arg UpdateJobParams) (Job, erro
 		arg.Headers,
 		arg.Body,
 		arg.Active,
+		arg.Transport,
+		arg.SetClientCertificate,
+		arg.ClientCertificateID,
+		arg.Auth,
+		arg.AuthSecret,
+		arg.Redirects,
+		arg.Type,
+		arg.Config,
+		arg.Triggers,
 	)
 	var i Job
 	err := row.Scan(
@@ -381,6 +636,15 @@ func (q *Queries) UpdateJob(ctx context.Context, arg UpdateJobParams) (Job, erro
 		&i.Active,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Transport,
+		&i.ClientCertificateID,
+		&i.Auth,
+		&i.AuthSecret,
+		&i.SigningSecret,
+		&i.Redirects,
+		&i.Type,
+		&i.Config,
+		&i.Triggers,
 	)
 	return i, err
 }
@@ -8,18 +8,40 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type ClientCertificate struct {
+	ID          pgtype.UUID        `json:"id"`
+	UserID      pgtype.UUID        `json:"user_id"`
+	Name        string             `json:"name"`
+	Certificate string             `json:"certificate"`
+	PrivateKey  []byte             `json:"private_key"`
+	CaBundle    pgtype.Text        `json:"ca_bundle"`
+	Subject     string             `json:"subject"`
+	Fingerprint string             `json:"fingerprint"`
+	NotAfter    pgtype.Timestamptz `json:"not_after"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+}
+
 type Job struct {
-	ID        pgtype.UUID        `json:"id"`
-	UserID    pgtype.UUID        `json:"user_id"`
-	Name      string             `json:"name"`
-	Schedule  string             `json:"schedule"`
-	Endpoint  string             `json:"endpoint"`
-	Method    string             `json:"method"`
-	Headers   []byte             `json:"headers"`
-	Body      pgtype.Text        `json:"body"`
-	Active    bool               `json:"active"`
-	CreatedAt pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+	ID                  pgtype.UUID        `json:"id"`
+	UserID              pgtype.UUID        `json:"user_id"`
+	Name                string             `json:"name"`
+	Schedule            string             `json:"schedule"`
+	Endpoint            string             `json:"endpoint"`
+	Method              string             `json:"method"`
+	Headers             []byte             `json:"headers"`
+	Body                pgtype.Text        `json:"body"`
+	Active              bool               `json:"active"`
+	CreatedAt           pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt           pgtype.Timestamptz `json:"updated_at"`
+	Transport           []byte             `json:"transport"`
+	ClientCertificateID pgtype.UUID        `json:"client_certificate_id"`
+	Auth                []byte             `json:"auth"`
+	AuthSecret          []byte             `json:"-"`
+	SigningSecret       []byte             `json:"-"`
+	Redirects           []byte             `json:"redirects"`
+	Type                string             `json:"type"`
+	Config              []byte             `json:"config"`
+	Triggers            []byte             `json:"triggers"`
 }
 
 type JobLog struct {
@@ -32,6 +54,13 @@ type JobLog struct {
 	ResponseCode pgtype.Int4        `json:"response_code"`
 	Error        pgtype.Text        `json:"error"`
 	ResponseBody pgtype.Text        `json:"response_body"`
+	DnsMs        pgtype.Int4        `json:"dns_ms"`
+	ConnectMs    pgtype.Int4        `json:"connect_ms"`
+	TlsMs        pgtype.Int4        `json:"tls_ms"`
+	TtfbMs       pgtype.Int4        `json:"ttfb_ms"`
+	DownloadMs   pgtype.Int4        `json:"download_ms"`
+	Redirects    []byte             `json:"redirects"`
+	RunGroup     pgtype.Text        `json:"run_group"`
 }
 
 type User struct {
@@ -11,21 +11,28 @@ import (
 )
 
 type Querier interface {
-	CleanupAllOldLogs(ctx context.Context) error
+	CleanupAllOldLogs(ctx context.Context, keep int32) error
+	CreateClientCertificate(ctx context.Context, arg CreateClientCertificateParams) (ClientCertificate, error)
 	CreateJob(ctx context.Context, arg CreateJobParams) (Job, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	DeleteClientCertificate(ctx context.Context, arg DeleteClientCertificateParams) error
 	DeleteJob(ctx context.Context, id pgtype.UUID) error
-	DeleteOldJobLogs(ctx context.Context, jobID pgtype.UUID) error
+	DeleteOldJobLogs(ctx context.Context, arg DeleteOldJobLogsParams) error
 	DeleteUser(ctx context.Context, id pgtype.UUID) error
+	GetClientCertificate(ctx context.Context, arg GetClientCertificateParams) (ClientCertificate, error)
 	GetJob(ctx context.Context, id pgtype.UUID) (Job, error)
 	GetUser(ctx context.Context, id pgtype.UUID) (User, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
 	InsertJobLog(ctx context.Context, arg InsertJobLogParams) (JobLog, error)
 	ListActiveJobs(ctx context.Context) ([]Job, error)
+	ListAllJobsByUser(ctx context.Context, userID pgtype.UUID) ([]Job, error)
+	ListClientCertificatesByUser(ctx context.Context, userID pgtype.UUID) ([]ClientCertificate, error)
 	ListJobLogs(ctx context.Context, arg ListJobLogsParams) ([]JobLog, error)
 	ListJobsByUser(ctx context.Context, arg ListJobsByUserParams) ([]Job, error)
-	ListRecentJobLogs(ctx context.Context, jobID pgtype.UUID) ([]JobLog, error)
+	ListRecentJobLogs(ctx context.Context, arg ListRecentJobLogsParams) ([]JobLog, error)
 	ListUsers(ctx context.Context) ([]User, error)
+	SetJobSigningSecret(ctx context.Context, arg SetJobSigningSecretParams) (Job, error)
+	SetJobTriggers(ctx context.Context, arg SetJobTriggersParams) (Job, error)
 	UpdateJob(ctx context.Context, arg UpdateJobParams) (Job, error)
 	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
 }
@@ -0,0 +1,191 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/netguard"
+)
+
+// dnsCheck resolves the endpoint, a host name, with the server's resolver.
+// It passes when the lookup returns records of the configured type and,
+// if expected values are set, every one of them is among the records.
+// An A or AAAA record the guard blocks fails the check without being
+// reported, so jobs cannot map the server's internal names.
+type dnsCheck struct {
+	guard *netguard.Guard
+}
+
+// Record types a dns job can look up.
+var recordTypes = []string{"A", "AAAA", "CNAME", "MX", "NS", "TXT"}
+
+type dnsConfig struct {
+	RecordType string   `json:"record_type"` // one of recordTypes; A if empty
+	Expected   []string `json:"expected"`    // values that must all be returned
+}
+
+func (c dnsConfig) recordType() string {
+	if c.RecordType == "" {
+		return "A"
+	}
+	return strings.ToUpper(c.RecordType)
+}
+
+func (dnsCheck) Problems(job db.Job) []string {
+	endpoint, config := job.Endpoint, job.Config
+	var p []string
+	if endpoint == "" || strings.ContainsAny(endpoint, ":/ ") {
+		p = append(p, fmt.Sprintf("endpoint must be a host name, got %q", endpoint))
+	}
+	var cfg dnsConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		return append(p, err.Error())
+	}
+	rt := cfg.recordType()
+	if !slices.Contains(recordTypes, rt) {
+		return append(p, fmt.Sprintf("config.record_type %q is not one of %s", cfg.RecordType, strings.Join(recordTypes, ", ")))
+	}
+	for _, v := range cfg.Expected {
+		if rt != "A" && rt != "AAAA" {
+			continue
+		}
+		a, err := netip.ParseAddr(v)
+		if err != nil || (rt == "A") != a.Is4() {
+			p = append(p, fmt.Sprintf("config.expected value %q is not an %s record", v, rt))
+		}
+	}
+	return p
+}
+
+func (c dnsCheck) Execute(ctx context.Context, job db.Job) Result {
+	endpoint, config := job.Endpoint, job.Config
+	var res Result
+	var cfg dnsConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		res.Err = err
+		return res
+	}
+	rt := cfg.recordType()
+	start := time.Now()
+	values, err := lookup(ctx, rt, endpoint)
+	res.DNS = time.Since(start)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if err := c.check(rt, values); err != nil {
+		res.Err = err
+		return res
+	}
+	res.Details = map[string]any{"record_type": rt, "values": values}
+	if len(values) == 0 {
+		res.Err = fmt.Errorf("no %s records found for %s", rt, endpoint)
+		return res
+	}
+	var missing []string
+	for _, want := range cfg.Expected {
+		if !slices.Contains(values, normalize(rt, want)) {
+			missing = append(missing, want)
+		}
+	}
+	if len(missing) > 0 {
+		res.Err = fmt.Errorf("expected %s records missing: %s", rt, strings.Join(missing, ", "))
+	}
+	return res
+}
+
+// check returns a *netguard.BlockedError, without the address, if any of
+// the addresses in values is blocked.
+func (c dnsCheck) check(rt string, values []string) error {
+	if rt != "A" && rt != "AAAA" {
+		return nil
+	}
+	for _, v := range values {
+		a, err := netip.ParseAddr(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s record %q", rt, v)
+		}
+		var blocked *netguard.BlockedError
+		if errors.As(c.guard.Check(a), &blocked) {
+			return &netguard.BlockedError{Reason: blocked.Reason}
+		}
+	}
+	return nil
+}
+
+// lookup returns the records of type rt for host, normalized for
+// comparison.
+func lookup(ctx context.Context, rt, host string) ([]string, error) {
+	r := net.DefaultResolver
+	var values []string
+	switch rt {
+	case "A", "AAAA":
+		network := "ip4"
+		if rt == "AAAA" {
+			network = "ip6"
+		}
+		addrs, err := r.LookupNetIP(ctx, network, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			values = append(values, a.Unmap().String())
+		}
+	case "CNAME":
+		name, err := r.LookupCNAME(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, name)
+	case "MX":
+		mxs, err := r.LookupMX(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			values = append(values, mx.Host)
+		}
+	case "NS":
+		nss, err := r.LookupNS(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nss {
+			values = append(values, ns.Host)
+		}
+	case "TXT":
+		txts, err := r.LookupTXT(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		values = txts
+	default:
+		return nil, fmt.Errorf("unsupported record type %q", rt)
+	}
+	for i, v := range values {
+		values[i] = normalize(rt, v)
+	}
+	slices.Sort(values)
+	return slices.Compact(values), nil
+}
+
+// normalize puts a record value in the form lookup returns it: addresses
+// in canonical form and names in lower case without the trailing dot.
+func normalize(rt, v string) string {
+	switch rt {
+	case "A", "AAAA":
+		if a, err := netip.ParseAddr(v); err == nil {
+			return a.Unmap().String()
+		}
+	case "CNAME", "MX", "NS":
+		return strings.TrimSuffix(strings.ToLower(v), ".")
+	}
+	return v
+}
@@ -0,0 +1,53 @@
+package executor_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/executor"
+	"cronix.ashutosh.net/internals/netguard"
+)
+
+// dns runs a dns job looking up the A records of host.
+func dns(t *testing.T, allow []string, host, config string) executor.Result {
+	t.Helper()
+	guard, err := netguard.New(allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex := executor.Builtin(guard, nil)[executor.DNS]
+	job := db.Job{Type: executor.DNS, Endpoint: host, Config: []byte(config)}
+	if p := ex.Problems(job); len(p) > 0 {
+		t.Fatalf("problems: %v", p)
+	}
+	return ex.Execute(context.Background(), job)
+}
+
+// An internal address is neither accepted nor disclosed.
+func TestDNSBlocksInternalAddresses(t *testing.T) {
+	res := dns(t, nil, "localhost", `{"record_type":"A"}`)
+	var blocked *netguard.BlockedError
+	if !errors.As(res.Err, &blocked) || blocked.Reason != "loopback address" {
+		t.Fatalf("looking up localhost = %v, want a loopback *BlockedError", res.Err)
+	}
+	if blocked.Addr.IsValid() || strings.Contains(res.Err.Error(), "127.0.0.1") {
+		t.Errorf("error %q discloses the address", res.Err)
+	}
+	if res.Details != nil || res.Body != nil {
+		t.Errorf("result records %v %q, want nothing", res.Details, res.Body)
+	}
+}
+
+func TestDNSAllowlist(t *testing.T) {
+	res := dns(t, []string{"127.0.0.0/8"}, "localhost", `{"expected":["127.0.0.1"]}`)
+	if res.Err != nil {
+		t.Fatalf("looking up localhost with loopback allowed = %v", res.Err)
+	}
+	details, _ := res.Details.(map[string]any)
+	if values, _ := details["values"].([]string); len(values) != 1 || values[0] != "127.0.0.1" {
+		t.Errorf("details = %v, want the loopback address", res.Details)
+	}
+}
@@ -0,0 +1,149 @@
+// Package executor defines how jobs run. A job's type selects its
+// Executor from a Registry; its endpoint names what is called or checked
+// and its config, a JSON object stored in jobs.config, holds the settings
+// of the type.
+//
+// The executors of this package check an endpoint instead of sending it
+// an HTTP request: whether a TCP port accepts connections, whether DNS
+// records resolve to the expected values, how long a TLS certificate has
+// left, and whether a gRPC method answers OK. HTTP jobs are run by the
+// services package, which holds their credentials.
+package executor
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/netguard"
+	"cronix.ashutosh.net/internals/outbound"
+)
+
+// Job types run by this package.
+const (
+	TCP     = "tcp"
+	DNS     = "dns"
+	TLSCert = "tls_cert"
+	GRPC    = "grpc"
+)
+
+// Result is the outcome of one run.
+type Result struct {
+	Err  error // nil if the run passed
+	Code *int  // response code, such as an HTTP or gRPC status; nil if none
+
+	// The run's response body is Body if it is set, or else Details, what
+	// was observed, as JSON.
+	Body    []byte
+	Details any
+
+	// Redirects are the requests of a run that followed redirects, ending
+	// with the one answered by the final response.
+	Redirects []outbound.Hop
+
+	// Phase durations; zero when the phase did not happen.
+	DNS, Connect, TLS, TTFB, Download time.Duration
+}
+
+// Executor runs the jobs of one type.
+type Executor interface {
+	// Problems lists what is wrong with job's endpoint and config, for
+	// validation errors. It is called before a job is saved, when only
+	// its type, endpoint and config may be set.
+	Problems(job db.Job) []string
+	// Execute runs job once. It is only called with a job Problems
+	// accepted.
+	Execute(ctx context.Context, job db.Job) Result
+}
+
+// Builtin returns the executors of this package by job type. Connections
+// are dialed through guard, addresses looked up are checked against it,
+// and certificates are verified against roots,
+// or the system roots if it is nil.
+func Builtin(guard *netguard.Guard, roots *x509.CertPool) map[string]Executor {
+	d := &dialer{guard: guard}
+	return map[string]Executor{
+		TCP:     tcpCheck{d},
+		DNS:     dnsCheck{guard},
+		TLSCert: tlsCertCheck{d, roots},
+		GRPC:    grpcCall{d, roots},
+	}
+}
+
+// decodeConfig decodes a job config into v, rejecting unknown fields so
+// that typos are reported instead of ignored.
+func decodeConfig(config []byte, v any) error {
+	if len(config) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(config))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	return nil
+}
+
+// hostPort splits a "host:port" endpoint, using defaultPort when the port
+// is left out; 0 makes the port required.
+func hostPort(endpoint string, defaultPort int) (string, int, error) {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		if defaultPort == 0 || endpoint == "" {
+			return "", 0, fmt.Errorf("endpoint must be host:port, got %q", endpoint)
+		}
+		host, port = endpoint, strconv.Itoa(defaultPort)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return "", 0, fmt.Errorf("endpoint port %q is not between 1 and 65535", port)
+	}
+	if host == "" {
+		return "", 0, fmt.Errorf("endpoint %q has no host", endpoint)
+	}
+	return host, n, nil
+}
+
+// dialer opens TCP connections through the guard, timing name resolution
+// and connecting separately.
+type dialer struct {
+	guard *netguard.Guard
+}
+
+func (d *dialer) dial(ctx context.Context, host string, port int, res *Result) (net.Conn, error) {
+	var addrs []netip.Addr
+	if a, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{a}
+	} else {
+		start := time.Now()
+		addrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		res.DNS = time.Since(start)
+		if err != nil {
+			return nil, err
+		}
+	}
+	nd := d.guard.Dialer()
+	start := time.Now()
+	defer func() { res.Connect = time.Since(start) }()
+	var first error
+	for _, a := range addrs {
+		conn, err := nd.DialContext(ctx, "tcp", netip.AddrPortFrom(a.Unmap(), uint16(port)).String())
+		if err == nil {
+			return conn, nil
+		}
+		if first == nil {
+			first = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, first
+}
@@ -0,0 +1,403 @@
+package executor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"cronix.ashutosh.net/internals/db"
+)
+
+// grpcCall calls a unary gRPC method on the endpoint, "host:port", and
+// passes when it returns OK. The run's response code is the gRPC status
+// code and its response body the response message as JSON.
+//
+// The request and response types come from the descriptor set in the
+// config, or else from the server's reflection service.
+type grpcCall struct {
+	d     *dialer
+	roots *x509.CertPool
+}
+
+// maxDescriptorSet bounds the descriptor set a job may carry.
+const maxDescriptorSet = 1 << 20
+
+type grpcConfig struct {
+	Method   string            `json:"method"`   // package.Service/Method
+	Request  json.RawMessage   `json:"request"`  // the request message as JSON; empty if unset
+	Metadata map[string]string `json:"metadata"` // sent as request metadata
+	// Plaintext connects without TLS.
+	Plaintext bool `json:"plaintext"`
+	// DescriptorSet is a serialized FileDescriptorSet including imports,
+	// as written by protoc --descriptor_set_out --include_imports. It is
+	// base64 in JSON.
+	DescriptorSet []byte `json:"descriptor_set"`
+}
+
+// method splits Method into the service and method names.
+func (c grpcConfig) method() (protoreflect.FullName, protoreflect.Name, error) {
+	svc, m, ok := strings.Cut(strings.TrimPrefix(c.Method, "/"), "/")
+	if !ok || !protoreflect.FullName(svc).IsValid() || !protoreflect.Name(m).IsValid() {
+		return "", "", fmt.Errorf("config.method must be package.Service/Method, got %q", c.Method)
+	}
+	return protoreflect.FullName(svc), protoreflect.Name(m), nil
+}
+
+func (grpcCall) Problems(job db.Job) []string {
+	endpoint, config := job.Endpoint, job.Config
+	var p []string
+	if _, _, err := hostPort(endpoint, 0); err != nil {
+		p = append(p, err.Error())
+	}
+	var cfg grpcConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		return append(p, err.Error())
+	}
+	svc, name, err := cfg.method()
+	if err != nil {
+		p = append(p, err.Error())
+	}
+	if len(cfg.Request) > 0 && !isJSONObject(cfg.Request) {
+		p = append(p, "config.request must be a JSON object")
+	}
+	if len(cfg.DescriptorSet) > maxDescriptorSet {
+		p = append(p, fmt.Sprintf("config.descriptor_set is larger than %d bytes", maxDescriptorSet))
+	}
+	if len(p) > 0 || len(cfg.DescriptorSet) == 0 {
+		return p
+	}
+	// With a descriptor set the request can be checked before any call
+	files, err := descriptorSetFiles(cfg.DescriptorSet)
+	if err != nil {
+		return append(p, "config.descriptor_set: "+err.Error())
+	}
+	md, err := findMethod(files, svc, name)
+	if err != nil {
+		return append(p, err.Error())
+	}
+	if _, err := requestMessage(md, files, cfg.Request); err != nil {
+		p = append(p, "config.request: "+err.Error())
+	}
+	return p
+}
+
+func (c grpcCall) Execute(ctx context.Context, job db.Job) Result {
+	endpoint, config := job.Endpoint, job.Config
+	var res Result
+	var cfg grpcConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		res.Err = err
+		return res
+	}
+	svc, name, err := cfg.method()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	host, port, err := hostPort(endpoint, 0)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	// The connection is dialed in the background; its timings and error
+	// are kept for the result.
+	var mu sync.Mutex
+	var dialed Result
+	dial := func(ctx context.Context, _ string) (net.Conn, error) {
+		var r Result
+		conn, err := c.d.dial(ctx, host, port, &r)
+		r.Err = err
+		mu.Lock()
+		dialed = r
+		mu.Unlock()
+		return conn, err
+	}
+	creds := insecure.NewCredentials()
+	if !cfg.Plaintext {
+		creds = credentials.NewTLS(&tls.Config{ServerName: host, RootCAs: c.roots, MinVersion: tls.VersionTLS12})
+	}
+	conn, err := grpc.NewClient("passthrough:///"+endpoint, grpc.WithTransportCredentials(creds), grpc.WithContextDialer(dial))
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer conn.Close()
+	if len(cfg.Metadata) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(cfg.Metadata))
+	}
+
+	var files *protoregistry.Files
+	if len(cfg.DescriptorSet) > 0 {
+		files, err = descriptorSetFiles(cfg.DescriptorSet)
+	} else {
+		files, err = reflectFiles(ctx, conn, svc)
+	}
+	var md protoreflect.MethodDescriptor
+	if err == nil {
+		md, err = findMethod(files, svc, name)
+	}
+	var req *dynamicpb.Message
+	if err == nil {
+		req, err = requestMessage(md, files, cfg.Request)
+	}
+	var resp *dynamicpb.Message
+	invoked := false
+	if err == nil {
+		resp = dynamicpb.NewMessage(md.Output())
+		err = conn.Invoke(ctx, "/"+string(svc)+"/"+string(name), req, resp)
+		invoked = true
+	}
+
+	mu.Lock()
+	res.DNS, res.Connect = dialed.DNS, dialed.Connect
+	dialErr := dialed.Err
+	mu.Unlock()
+	switch {
+	case status.Code(err) == codes.Unavailable && dialErr != nil:
+		// the server was never reached; report why, without a code
+		res.Err = dialErr
+		return res
+	case invoked:
+		code := int(status.Code(err))
+		res.Code = &code
+	}
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	body, err := protojson.MarshalOptions{Resolver: dynamicpb.NewTypes(files)}.Marshal(resp)
+	if err != nil {
+		res.Err = fmt.Errorf("encode response: %w", err)
+		return res
+	}
+	res.Details = json.RawMessage(body)
+	return res
+}
+
+func isJSONObject(b []byte) bool {
+	var m map[string]json.RawMessage
+	return json.Unmarshal(b, &m) == nil && m != nil
+}
+
+// requestMessage builds the input message of md from its JSON form.
+func requestMessage(md protoreflect.MethodDescriptor, files *protoregistry.Files, raw json.RawMessage) (*dynamicpb.Message, error) {
+	msg := dynamicpb.NewMessage(md.Input())
+	if len(raw) == 0 {
+		return msg, nil
+	}
+	if err := (protojson.UnmarshalOptions{Resolver: dynamicpb.NewTypes(files)}).Unmarshal(raw, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// findMethod looks up a unary method in files.
+func findMethod(files *protoregistry.Files, svc protoreflect.FullName, name protoreflect.Name) (protoreflect.MethodDescriptor, error) {
+	d, err := files.FindDescriptorByName(svc)
+	if err != nil {
+		return nil, fmt.Errorf("service %s not found", svc)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", svc)
+	}
+	md := sd.Methods().ByName(name)
+	if md == nil {
+		return nil, fmt.Errorf("service %s has no method %s", svc, name)
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("method %s/%s is streaming; only unary methods can be called", svc, name)
+	}
+	return md, nil
+}
+
+// descriptorSetFiles decodes a serialized FileDescriptorSet.
+func descriptorSetFiles(b []byte) (*protoregistry.Files, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("not a FileDescriptorSet: %v", err)
+	}
+	protos := make(map[string]*descriptorpb.FileDescriptorProto, len(set.File))
+	for _, f := range set.File {
+		protos[f.GetName()] = f
+	}
+	return buildFiles(protos)
+}
+
+// buildFiles links file descriptors into a registry, dependencies first.
+// Dependencies missing from protos are taken from the files compiled into
+// the server, which covers the well-known types.
+func buildFiles(protos map[string]*descriptorpb.FileDescriptorProto) (*protoregistry.Files, error) {
+	files := new(protoregistry.Files)
+	r := fallbackResolver{files}
+	var add func(name string, seen map[string]bool) error
+	add = func(name string, seen map[string]bool) error {
+		if _, err := files.FindFileByPath(name); err == nil {
+			return nil
+		}
+		fdp, ok := protos[name]
+		if !ok {
+			if _, err := protoregistry.GlobalFiles.FindFileByPath(name); err == nil {
+				return nil
+			}
+			return fmt.Errorf("descriptor of %s is missing", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("import cycle at %s", name)
+		}
+		seen[name] = true
+		for _, dep := range fdp.GetDependency() {
+			if err := add(dep, seen); err != nil {
+				return err
+			}
+		}
+		fd, err := protodesc.NewFile(fdp, r)
+		if err != nil {
+			return err
+		}
+		return files.RegisterFile(fd)
+	}
+	for name := range protos {
+		if err := add(name, map[string]bool{}); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// fallbackResolver resolves from local first and then from the files
+// compiled into the server.
+type fallbackResolver struct {
+	local *protoregistry.Files
+}
+
+func (r fallbackResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := r.local.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r fallbackResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := r.local.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+// reflectionMethods are the server reflection services tried in order. The
+// two versions are wire compatible, so v1 messages are sent to both.
+var reflectionMethods = []string{
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
+// reflectFiles asks the server for the file defining svc and everything it
+// imports.
+func reflectFiles(ctx context.Context, conn *grpc.ClientConn, svc protoreflect.FullName) (*protoregistry.Files, error) {
+	var err error
+	for _, method := range reflectionMethods {
+		var files *protoregistry.Files
+		files, err = reflectWith(ctx, conn, method, svc)
+		if status.Code(err) != codes.Unimplemented {
+			return files, err
+		}
+	}
+	return nil, fmt.Errorf("server reflection is not available; set config.descriptor_set: %w", err)
+}
+
+func reflectWith(ctx context.Context, conn *grpc.ClientConn, method string, svc protoreflect.FullName) (*protoregistry.Files, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}, method)
+	if err != nil {
+		return nil, err
+	}
+	protos := map[string]*descriptorpb.FileDescriptorProto{}
+	ask := func(req *rpb.ServerReflectionRequest) error {
+		if err := stream.SendMsg(req); err != nil {
+			return err
+		}
+		resp := new(rpb.ServerReflectionResponse)
+		if err := stream.RecvMsg(resp); err != nil {
+			return err
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return status.Error(codes.Code(e.GetErrorCode()), e.GetErrorMessage())
+		}
+		for _, b := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fdp := new(descriptorpb.FileDescriptorProto)
+			if err := proto.Unmarshal(b, fdp); err != nil {
+				return fmt.Errorf("decode reflected descriptor: %w", err)
+			}
+			protos[fdp.GetName()] = fdp
+		}
+		return nil
+	}
+	err = ask(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: string(svc)},
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("service %s not found by server reflection", svc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Servers usually send the imports along; ask for any they left out,
+	// and then for what those import in turn
+	for missing := missingDeps(protos); len(missing) > 0; missing = missingDeps(protos) {
+		for _, dep := range missing {
+			if err := ask(&rpb.ServerReflectionRequest{
+				MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+			}); err != nil {
+				return nil, fmt.Errorf("reflect %s: %w", dep, err)
+			}
+			if _, ok := protos[dep]; !ok {
+				return nil, fmt.Errorf("server reflection did not return %s", dep)
+			}
+		}
+	}
+	_ = stream.CloseSend()
+	return buildFiles(protos)
+}
+
+// missingDeps lists the imports of protos that are neither among them nor
+// compiled into the server, in order.
+func missingDeps(protos map[string]*descriptorpb.FileDescriptorProto) []string {
+	var missing []string
+	for _, fdp := range protos {
+		for _, dep := range fdp.GetDependency() {
+			if _, ok := protos[dep]; ok {
+				continue
+			}
+			if _, err := protoregistry.GlobalFiles.FindFileByPath(dep); err == nil {
+				continue
+			}
+			missing = append(missing, dep)
+		}
+	}
+	slices.Sort(missing)
+	return slices.Compact(missing)
+}
@@ -0,0 +1,261 @@
+package executor_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/executor"
+	"cronix.ashutosh.net/internals/netguard"
+)
+
+// serve runs s on a loopback port until the test ends and returns its
+// address.
+func serve(t *testing.T, s *grpc.Server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	t.Cleanup(s.Stop)
+	return ln.Addr().String()
+}
+
+// healthServer serves the health service, with server reflection if
+// reflect is set.
+func healthServer(t *testing.T, reflect bool) string {
+	t.Helper()
+	s := grpc.NewServer()
+	hs := health.NewServer()
+	hs.SetServingStatus("cronix", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+	if reflect {
+		reflection.Register(s)
+	}
+	return serve(t, s)
+}
+
+// call runs a plaintext grpc job against addr with the config fields
+// in cfg.
+func call(t *testing.T, addr string, cfg map[string]any) executor.Result {
+	t.Helper()
+	guard, err := netguard.New([]string{"127.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg["plaintext"] = true
+	config, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex := executor.Builtin(guard, nil)[executor.GRPC]
+	job := db.Job{Type: executor.GRPC, Endpoint: addr, Config: config}
+	if p := ex.Problems(job); len(p) > 0 {
+		t.Fatalf("problems: %v", p)
+	}
+	return ex.Execute(context.Background(), job)
+}
+
+// wantCall checks res is a call that ended with code and, if it is OK,
+// answered body.
+func wantCall(t *testing.T, res executor.Result, code codes.Code, body string) {
+	t.Helper()
+	if res.Code == nil || codes.Code(*res.Code) != code {
+		t.Fatalf("code = %v (error %v), want %s", res.Code, res.Err, code)
+	}
+	if code != codes.OK {
+		if res.Err == nil {
+			t.Error("a failed call passed")
+		}
+		return
+	}
+	if res.Err != nil {
+		t.Fatalf("call failed: %v", res.Err)
+	}
+	if got, _ := res.Details.(json.RawMessage); !sameJSON(got, body) {
+		t.Errorf("response = %s, want %s", got, body)
+	}
+}
+
+func sameJSON(got []byte, want string) bool {
+	var a, b any
+	return json.Unmarshal(got, &a) == nil && json.Unmarshal([]byte(want), &b) == nil &&
+		string(mustMarshal(a)) == string(mustMarshal(b))
+}
+
+func mustMarshal(v any) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+func TestGRPCReflection(t *testing.T) {
+	addr := healthServer(t, true)
+
+	res := call(t, addr, map[string]any{"method": "grpc.health.v1.Health/Check"})
+	wantCall(t, res, codes.OK, `{"status":"SERVING"}`)
+
+	res = call(t, addr, map[string]any{"method": "grpc.health.v1.Health/Check", "request": map[string]any{"service": "cronix"}})
+	wantCall(t, res, codes.OK, `{"status":"NOT_SERVING"}`)
+
+	res = call(t, addr, map[string]any{"method": "grpc.health.v1.Health/Check", "request": map[string]any{"service": "other"}})
+	wantCall(t, res, codes.NotFound, "")
+
+	res = call(t, addr, map[string]any{"method": "grpc.health.v1.Health/Watch"})
+	if res.Err == nil || !strings.Contains(res.Err.Error(), "streaming") {
+		t.Errorf("calling a streaming method = %v, want it refused", res.Err)
+	}
+	res = call(t, addr, map[string]any{"method": "cronix.Missing/Call"})
+	if res.Err == nil || !strings.Contains(res.Err.Error(), "not found by server reflection") {
+		t.Errorf("calling an unknown service = %v", res.Err)
+	}
+}
+
+func TestGRPCDescriptorSet(t *testing.T) {
+	addr := healthServer(t, false)
+
+	res := call(t, addr, map[string]any{"method": "grpc.health.v1.Health/Check"})
+	if res.Err == nil || !strings.Contains(res.Err.Error(), "server reflection is not available") {
+		t.Fatalf("calling without reflection or descriptors = %v", res.Err)
+	}
+
+	set, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res = call(t, addr, map[string]any{
+		"method":         "grpc.health.v1.Health/Check",
+		"request":        map[string]any{"service": "cronix"},
+		"descriptor_set": base64.StdEncoding.EncodeToString(set),
+	})
+	wantCall(t, res, codes.OK, `{"status":"NOT_SERVING"}`)
+}
+
+// A server whose reflection leaves out imports is asked for them, and for
+// their imports in turn.
+func TestGRPCReflectionFetchesImports(t *testing.T) {
+	files := importChain()
+	refl := &partialReflection{files: files}
+	s := grpc.NewServer(grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		if err := stream.RecvMsg(new(emptypb.Empty)); err != nil {
+			return err
+		}
+		return stream.SendMsg(&emptypb.Empty{})
+	}))
+	rpb.RegisterServerReflectionServer(s, refl)
+	addr := serve(t, s)
+
+	res := call(t, addr, map[string]any{"method": "cronixtest.Chain/Call"})
+	wantCall(t, res, codes.OK, `{}`)
+	if got := strings.Join(refl.asked(), " "); got != "cronixtest/a.proto cronixtest/b.proto cronixtest/c.proto" {
+		t.Errorf("reflection was asked for %s", got)
+	}
+}
+
+// importChain returns files a.proto, defining service cronixtest.Chain,
+// b.proto it imports and c.proto b.proto imports, none of which are
+// compiled into the test.
+func importChain() map[string]*descriptorpb.FileDescriptorProto {
+	msg := func(name string, fields ...*descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{Name: proto.String(name), Field: fields}
+	}
+	field := func(name, typ string) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(1),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			TypeName: proto.String(typ),
+		}
+	}
+	file := func(name string, deps []string, msgs ...*descriptorpb.DescriptorProto) *descriptorpb.FileDescriptorProto {
+		return &descriptorpb.FileDescriptorProto{
+			Name:        proto.String(name),
+			Package:     proto.String("cronixtest"),
+			Dependency:  deps,
+			MessageType: msgs,
+			Syntax:      proto.String("proto3"),
+		}
+	}
+	a := file("cronixtest/a.proto", []string{"cronixtest/b.proto"}, msg("Req"))
+	a.Service = []*descriptorpb.ServiceDescriptorProto{{
+		Name: proto.String("Chain"),
+		Method: []*descriptorpb.MethodDescriptorProto{{
+			Name:       proto.String("Call"),
+			InputType:  proto.String(".cronixtest.Req"),
+			OutputType: proto.String(".cronixtest.B"),
+		}},
+	}}
+	return map[string]*descriptorpb.FileDescriptorProto{
+		"cronixtest/a.proto": a,
+		"cronixtest/b.proto": file("cronixtest/b.proto", []string{"cronixtest/c.proto"}, msg("B", field("c", ".cronixtest.C"))),
+		"cronixtest/c.proto": file("cronixtest/c.proto", nil, msg("C")),
+	}
+}
+
+// partialReflection answers each reflection request with the one file
+// asked for, without its imports, and records the files it sent.
+type partialReflection struct {
+	rpb.UnimplementedServerReflectionServer
+	files map[string]*descriptorpb.FileDescriptorProto
+
+	mu   sync.Mutex
+	sent []string
+}
+
+func (r *partialReflection) asked() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sent
+}
+
+func (r *partialReflection) ServerReflectionInfo(stream rpb.ServerReflection_ServerReflectionInfoServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		name := req.GetFileByFilename()
+		if req.GetFileContainingSymbol() == "cronixtest.Chain" {
+			name = "cronixtest/a.proto"
+		}
+		resp := &rpb.ServerReflectionResponse{OriginalRequest: req}
+		if fdp, ok := r.files[name]; ok {
+			b, err := proto.Marshal(fdp)
+			if err != nil {
+				return err
+			}
+			r.mu.Lock()
+			r.sent = append(r.sent, name)
+			r.mu.Unlock()
+			resp.MessageResponse = &rpb.ServerReflectionResponse_FileDescriptorResponse{
+				FileDescriptorResponse: &rpb.FileDescriptorResponse{FileDescriptorProto: [][]byte{b}},
+			}
+		} else {
+			resp.MessageResponse = &rpb.ServerReflectionResponse_ErrorResponse{
+				ErrorResponse: &rpb.ErrorResponse{ErrorCode: int32(codes.NotFound), ErrorMessage: name + " not found"},
+			}
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
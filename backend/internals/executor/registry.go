@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"slices"
+	"sync"
+)
+
+// Registry holds the executor of every job type. Adding a type to it is
+// all it takes for jobs of that type to be validated, tested and run.
+type Registry struct {
+	mu sync.RWMutex
+	m  map[string]Executor
+}
+
+func NewRegistry() *Registry {
+	return &Registry{m: make(map[string]Executor)}
+}
+
+// Register makes ex run the jobs of type typ, replacing the executor typ
+// had, if any.
+func (r *Registry) Register(typ string, ex Executor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.m[typ] = ex
+}
+
+// Get returns the executor of typ.
+func (r *Registry) Get(typ string) (Executor, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ex, ok := r.m[typ]
+	return ex, ok
+}
+
+// Types returns the registered job types in order.
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.m))
+	for t := range r.m {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
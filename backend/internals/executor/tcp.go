@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"context"
+
+	"cronix.ashutosh.net/internals/db"
+)
+
+// tcpCheck passes when a connection to the endpoint, "host:port", is
+// accepted. It takes no config.
+type tcpCheck struct {
+	d *dialer
+}
+
+type tcpConfig struct{}
+
+func (tcpCheck) Problems(job db.Job) []string {
+	endpoint, config := job.Endpoint, job.Config
+	var p []string
+	if _, _, err := hostPort(endpoint, 0); err != nil {
+		p = append(p, err.Error())
+	}
+	if err := decodeConfig(config, &tcpConfig{}); err != nil {
+		p = append(p, err.Error())
+	}
+	return p
+}
+
+func (c tcpCheck) Execute(ctx context.Context, job db.Job) Result {
+	endpoint := job.Endpoint
+	var res Result
+	host, port, err := hostPort(endpoint, 0)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	conn, err := c.d.dial(ctx, host, port, &res)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Details = map[string]string{"address": conn.RemoteAddr().String()}
+	conn.Close()
+	return res
+}
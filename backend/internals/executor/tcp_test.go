@@ -0,0 +1,105 @@
+package executor_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/executor"
+	"cronix.ashutosh.net/internals/netguard"
+)
+
+// check runs a job of typ against endpoint with loopback allowed unless
+// allow is nil.
+func check(t *testing.T, allow []string, typ, endpoint, config string) executor.Result {
+	t.Helper()
+	guard, err := netguard.New(allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex := executor.Builtin(guard, nil)[typ]
+	job := db.Job{Type: typ, Endpoint: endpoint, Config: []byte(config)}
+	if p := ex.Problems(job); len(p) > 0 {
+		t.Fatalf("problems: %v", p)
+	}
+	return ex.Execute(context.Background(), job)
+}
+
+// problems returns the problems of a job of typ.
+func problems(t *testing.T, typ, endpoint, config string) []string {
+	t.Helper()
+	guard, err := netguard.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return executor.Builtin(guard, nil)[typ].Problems(db.Job{Type: typ, Endpoint: endpoint, Config: []byte(config)})
+}
+
+func TestTCPProblems(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		config   string
+		problem  string // "" for none
+	}{
+		{"db.example.com:5432", "", ""},
+		{"[::1]:22", "{}", ""},
+		{"db.example.com", "", "endpoint must be host:port"},
+		{"", "", "endpoint must be host:port"},
+		{"db.example.com:0", "", "is not between 1 and 65535"},
+		{"db.example.com:65536", "", "is not between 1 and 65535"},
+		{"db.example.com:pg", "", "is not between 1 and 65535"},
+		{":5432", "", "has no host"},
+		{"db.example.com:5432", `{"timeout":1}`, "invalid config"},
+	}
+	for _, tt := range tests {
+		p := problems(t, executor.TCP, tt.endpoint, tt.config)
+		if got := strings.Join(p, "; "); (tt.problem == "") != (got == "") || !strings.Contains(got, tt.problem) {
+			t.Errorf("problems of %q %s = %q, want %q", tt.endpoint, tt.config, got, tt.problem)
+		}
+	}
+}
+
+func TestTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	defer ln.Close()
+	loopback := []string{"127.0.0.0/8"}
+
+	res := check(t, loopback, executor.TCP, ln.Addr().String(), "")
+	if res.Err != nil {
+		t.Fatalf("connecting to a listener = %v", res.Err)
+	}
+	details, _ := res.Details.(map[string]string)
+	if details["address"] != ln.Addr().String() {
+		t.Errorf("details = %v", res.Details)
+	}
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+	if res := check(t, loopback, executor.TCP, closed.Addr().String(), ""); res.Err == nil || !strings.Contains(res.Err.Error(), "connection refused") {
+		t.Errorf("connecting to a closed port = %v, want connection refused", res.Err)
+	}
+
+	res = check(t, nil, executor.TCP, ln.Addr().String(), "")
+	var blocked *netguard.BlockedError
+	if !errors.As(res.Err, &blocked) || blocked.Reason != "loopback address" {
+		t.Errorf("connecting to loopback without allowing it = %v, want a *BlockedError", res.Err)
+	}
+}
@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math"
+	"time"
+
+	"cronix.ashutosh.net/internals/db"
+)
+
+// tlsCertCheck connects to the endpoint, "host:port" or "host" for port
+// 443, and verifies its certificate for the host. It passes when the
+// certificate is valid for at least MinDaysRemaining more days.
+type tlsCertCheck struct {
+	d     *dialer
+	roots *x509.CertPool
+}
+
+// defaultMinDays is the warning period when a job sets none.
+const defaultMinDays = 14
+
+type tlsCertConfig struct {
+	MinDaysRemaining *int `json:"min_days_remaining"` // defaultMinDays if unset
+}
+
+func (c tlsCertConfig) minDays() int {
+	if c.MinDaysRemaining == nil {
+		return defaultMinDays
+	}
+	return *c.MinDaysRemaining
+}
+
+func (tlsCertCheck) Problems(job db.Job) []string {
+	endpoint, config := job.Endpoint, job.Config
+	var p []string
+	if _, _, err := hostPort(endpoint, 443); err != nil {
+		p = append(p, err.Error())
+	}
+	var cfg tlsCertConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		return append(p, err.Error())
+	}
+	if d := cfg.minDays(); d < 0 || d > 3650 {
+		p = append(p, "config.min_days_remaining must be between 0 and 3650")
+	}
+	return p
+}
+
+func (c tlsCertCheck) Execute(ctx context.Context, job db.Job) Result {
+	endpoint, config := job.Endpoint, job.Config
+	var res Result
+	var cfg tlsCertConfig
+	if err := decodeConfig(config, &cfg); err != nil {
+		res.Err = err
+		return res
+	}
+	host, port, err := hostPort(endpoint, 443)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	raw, err := c.d.dial(ctx, host, port, &res)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	conn := tls.Client(raw, &tls.Config{ServerName: host, RootCAs: c.roots, MinVersion: tls.VersionTLS12})
+	defer conn.Close()
+	start := time.Now()
+	err = conn.HandshakeContext(ctx)
+	res.TLS = time.Since(start)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	leaf := conn.ConnectionState().PeerCertificates[0]
+	days := int(math.Floor(time.Until(leaf.NotAfter).Hours() / 24))
+	res.Details = map[string]any{
+		"subject":        leaf.Subject.String(),
+		"issuer":         leaf.Issuer.String(),
+		"dns_names":      leaf.DNSNames,
+		"not_after":      leaf.NotAfter.UTC().Format(time.RFC3339),
+		"days_remaining": days,
+	}
+	if min := cfg.minDays(); days < min {
+		res.Err = fmt.Errorf("certificate expires in %d days, fewer than the required %d", days, min)
+	}
+	return res
+}
@@ -0,0 +1,145 @@
+package executor_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/executor"
+	"cronix.ashutosh.net/internals/netguard"
+)
+
+// tlsServer serves TLS with a self-signed certificate for 127.0.0.1 that
+// expires after valid, and returns its address and a pool trusting it.
+func tlsServer(t *testing.T, valid time.Duration) (string, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "expiring"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(valid),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return srv.Listener.Addr().String(), roots
+}
+
+func tlsCert(t *testing.T, allow []string, roots *x509.CertPool, endpoint, config string) executor.Result {
+	t.Helper()
+	guard, err := netguard.New(allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex := executor.Builtin(guard, roots)[executor.TLSCert]
+	job := db.Job{Type: executor.TLSCert, Endpoint: endpoint, Config: []byte(config)}
+	if p := ex.Problems(job); len(p) > 0 {
+		t.Fatalf("problems: %v", p)
+	}
+	return ex.Execute(context.Background(), job)
+}
+
+func TestTLSCertProblems(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		config   string
+		problem  string // "" for none
+	}{
+		{"example.com", "", ""}, // port 443
+		{"example.com:8443", `{"min_days_remaining":0}`, ""},
+		{"example.com", `{"min_days_remaining":3650}`, ""},
+		{"", "", "endpoint must be host:port"},
+		{"example.com:0", "", "is not between 1 and 65535"},
+		{"example.com", `{"min_days_remaining":-1}`, "must be between 0 and 3650"},
+		{"example.com", `{"min_days_remaining":3651}`, "must be between 0 and 3650"},
+		{"example.com", `{"min_days":3}`, "invalid config"},
+	}
+	for _, tt := range tests {
+		p := problems(t, executor.TLSCert, tt.endpoint, tt.config)
+		if got := strings.Join(p, "; "); (tt.problem == "") != (got == "") || !strings.Contains(got, tt.problem) {
+			t.Errorf("problems of %q %s = %q, want %q", tt.endpoint, tt.config, got, tt.problem)
+		}
+	}
+}
+
+func TestTLSCert(t *testing.T) {
+	// Just under five days, so four whole days remain
+	addr, roots := tlsServer(t, 5*24*time.Hour-time.Minute)
+	loopback := []string{"127.0.0.0/8"}
+
+	res := tlsCert(t, loopback, roots, addr, `{"min_days_remaining":4}`)
+	if res.Err != nil {
+		t.Fatalf("certificate with enough days left = %v", res.Err)
+	}
+	details, _ := res.Details.(map[string]any)
+	if details["subject"] != "CN=expiring" || details["days_remaining"] != 4 {
+		t.Errorf("details = %v", res.Details)
+	}
+	if names, _ := details["dns_names"].([]string); len(names) != 1 || names[0] != "localhost" {
+		t.Errorf("dns_names = %v", details["dns_names"])
+	}
+	if res.TLS <= 0 {
+		t.Error("handshake time not recorded")
+	}
+
+	tests := []struct {
+		name   string
+		allow  []string
+		roots  *x509.CertPool
+		config string
+		err    string
+	}{
+		{"expiring within the default period", loopback, roots, "", "certificate expires in 4 days, fewer than the required 14"},
+		{"expiring within min_days_remaining", loopback, roots, `{"min_days_remaining":5}`, "fewer than the required 5"},
+		{"untrusted", loopback, x509.NewCertPool(), "", "certificate signed by unknown authority"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tlsCert(t, tt.allow, tt.roots, addr, tt.config)
+			if res.Err == nil || !strings.Contains(res.Err.Error(), tt.err) {
+				t.Errorf("err = %v, want %q", res.Err, tt.err)
+			}
+		})
+	}
+
+	res = tlsCert(t, nil, roots, addr, "")
+	var blocked *netguard.BlockedError
+	if !errors.As(res.Err, &blocked) || blocked.Reason != "loopback address" {
+		t.Errorf("checking loopback without allowing it = %v, want a *BlockedError", res.Err)
+	}
+}
@@ -3,8 +3,8 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/oauth2"
@@ -12,31 +12,29 @@ import (
 	"google.golang.org/api/option"
 
 	"cronix.ashutosh.net/internals/config"
+	"cronix.ashutosh.net/internals/middleware"
 	"cronix.ashutosh.net/internals/services"
 )
 
 type AuthHandler struct {
 	authService *services.AuthService
 	authConfig  *config.AuthConfig
+	httpClient  *http.Client // for calls to Google
 }
 
-func NewAuthHandler(authService *services.AuthService, authConfig *config.AuthConfig) *AuthHandler {
+func NewAuthHandler(authService *services.AuthService, authConfig *config.AuthConfig, httpClient *http.Client) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
 		authConfig:  authConfig,
+		httpClient:  httpClient,
 	}
 }
 
 // Login initiates Google OAuth flow
 func (h *AuthHandler) Login(c *gin.Context) {
-	// Debug: Check OAuth config
-	fmt.Printf("OAuth Config - ClientID: %s, RedirectURL: %s\n",
-		h.authConfig.GooglelOauthConfig.ClientID,
-		h.authConfig.GooglelOauthConfig.RedirectURL)
-
 	state, err := services.GenerateState()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate state"})
+		_ = c.Error(fmt.Errorf("generate oauth state: %w", err))
 		return
 	}
 
@@ -53,7 +51,6 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	url := h.authConfig.GooglelOauthConfig.AuthCodeURL(state, authOptions...)
-	fmt.Printf("Generated OAuth URL: %s\n", url)
 	c.Redirect(http.StatusTemporaryRedirect, url)
 }
 
@@ -65,45 +62,47 @@ func (h *AuthHandler) Callback(c *gin.Context) {
 	state := c.Query("state")
 	cookieState, err := c.Cookie("oauth_state")
 	if err != nil || state != cookieState {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state parameter"})
+		_ = c.Error(services.ValidationError("Invalid state parameter", nil))
 		return
 	}
 
 	// Exchange code for token
 	code := c.Query("code")
 	if code == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code is missing"})
+		_ = c.Error(services.ValidationError("Authorization code is missing", nil))
 		return
 	}
 
-	token, err := h.authConfig.GooglelOauthConfig.Exchange(ctx, code)
+	// oauth2 picks the HTTP client up from the context
+	oauthCtx := context.WithValue(ctx, oauth2.HTTPClient, h.httpClient)
+	token, err := h.authConfig.GooglelOauthConfig.Exchange(oauthCtx, code)
 	if err != nil {
-		// Log the detailed error for debugging
-		fmt.Printf("Token exchange error: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Failed to exchange token",
-			"details": err.Error(),
-		})
+		slog.WarnContext(c.Request.Context(), "oauth token exchange failed", "error", err)
+		_ = c.Error(services.UpstreamError("Failed to exchange token", err))
 		return
 	}
 
 	// Get user info from Google
-	oauth2Service, err := googleoauth2.NewService(ctx, option.WithTokenSource(h.authConfig.GooglelOauthConfig.TokenSource(ctx, token)))
+	authed := &http.Client{Transport: &oauth2.Transport{
+		Source: h.authConfig.GooglelOauthConfig.TokenSource(oauthCtx, token),
+		Base:   h.httpClient.Transport,
+	}}
+	oauth2Service, err := googleoauth2.NewService(ctx, option.WithHTTPClient(authed))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create OAuth2 service"})
+		_ = c.Error(fmt.Errorf("create oauth2 service: %w", err))
 		return
 	}
 
 	userInfo, err := oauth2Service.Userinfo.Get().Do()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user info"})
+		_ = c.Error(services.UpstreamError("Failed to get user info", err))
 		return
 	}
 
 	// Create or get user from database
 	user, err := h.authService.CreateOrGetUser(ctx, userInfo.Email, userInfo.Name, userInfo.Picture)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create/get user"})
+		_ = c.Error(err)
 		return
 	}
 
@@ -111,17 +110,13 @@ func (h *AuthHandler) Callback(c *gin.Context) {
 	userIDStr := user.ID.String()
 	jwtToken, err := h.authService.GenerateJWT(userIDStr, user.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		_ = c.Error(fmt.Errorf("generate jwt: %w", err))
 		return
 	}
 
 	// Redirect to frontend with JWT token as query parameter
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "http://localhost:5173"
-	}
 	// Pass the JWT token as a query parameter to the frontend
-	redirectURL := fmt.Sprintf("%s/dashboard?token=%s", frontendURL, jwtToken)
+	redirectURL := fmt.Sprintf("%s/dashboard?token=%s", h.authConfig.FrontendURL, jwtToken)
 	c.Redirect(http.StatusTemporaryRedirect, redirectURL)
 }
 
@@ -134,14 +129,14 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 func (h *AuthHandler) GetProfile(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		middleware.RespondError(c, http.StatusUnauthorized, "unauthorized", "User not authenticated", nil)
 		return
 	}
 
 	ctx := context.Background()
 	user, err := h.authService.GetUserByID(ctx, userID.(string))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		_ = c.Error(err)
 		return
 	}
 
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/services"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type CertificatesHandler struct {
+	cs *services.CertificatesService
+}
+
+func NewCertificatesHandler(cs *services.CertificatesService) *CertificatesHandler {
+	return &CertificatesHandler{cs: cs}
+}
+
+type createCertificateReq struct {
+	Name        string  `json:"name" binding:"required"`
+	Certificate string  `json:"certificate" binding:"required"` // PEM, leaf first
+	PrivateKey  string  `json:"private_key" binding:"required"` // PEM
+	CABundle    *string `json:"ca_bundle"`                      // PEM, CAs trusted for the endpoint
+}
+
+func (h *CertificatesHandler) Create(c *gin.Context) {
+	var uid pgtype.UUID
+	_ = uid.Scan(c.GetString("user_id"))
+
+	var req createCertificateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(invalidBody(err))
+		return
+	}
+	cert, err := h.cs.Create(c.Request.Context(), uid, req.Name, req.Certificate, req.PrivateKey, req.CABundle)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, certificateResponse(cert))
+}
+
+func (h *CertificatesHandler) List(c *gin.Context) {
+	var uid pgtype.UUID
+	_ = uid.Scan(c.GetString("user_id"))
+
+	certs, err := h.cs.List(c.Request.Context(), uid)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	out := make([]map[string]interface{}, len(certs))
+	for i, cert := range certs {
+		out[i] = certificateResponse(cert)
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *CertificatesHandler) Delete(c *gin.Context) {
+	var uid pgtype.UUID
+	_ = uid.Scan(c.GetString("user_id"))
+
+	var id pgtype.UUID
+	if err := id.Scan(c.Param("id")); err != nil {
+		_ = c.Error(services.ValidationError("invalid certificate id", c.Param("id")))
+		return
+	}
+	if err := h.cs.Delete(c.Request.Context(), uid, id); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// certificateResponse renders a client certificate for the API. The
+// private key is never included.
+func certificateResponse(cert db.ClientCertificate) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            cert.ID.String(),
+		"name":          cert.Name,
+		"subject":       cert.Subject,
+		"fingerprint":   cert.Fingerprint,
+		"not_after":     cert.NotAfter.Time.Format("2006-01-02T15:04:05Z07:00"),
+		"created_at":    cert.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+		"certificate":   cert.Certificate,
+		"has_ca_bundle": cert.CaBundle.Valid,
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"cronix.ashutosh.net/database"
+	"cronix.ashutosh.net/internals/services"
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	db        *sql.DB // nil with the memory store, which has nothing to check
+	driver    string  // database.Postgres or database.SQLite
+	scheduler *services.Scheduler
+}
+
+func NewHealthHandler(sqlDB *sql.DB, driver string, scheduler *services.Scheduler) *HealthHandler {
+	return &HealthHandler{db: sqlDB, driver: driver, scheduler: scheduler}
+}
+
+// Live reports that the process is up. It checks nothing else so that a
+// database outage does not get the instance restarted.
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+type check struct {
+	Status string `json:"status"`          // ok or fail
+	Error  string `json:"error,omitempty"` // a fixed message; the cause is logged
+}
+
+type databaseCheck struct {
+	check
+	LatencyMs int64 `json:"latency_ms"`
+}
+
+type migrationsCheck struct {
+	check
+	Current  int64 `json:"current"`
+	Expected int64 `json:"expected"`
+}
+
+type schedulerCheck struct {
+	check
+	services.SchedulerState
+}
+
+// Ready reports whether the instance should receive traffic: the database
+// answers, its schema is at least at the version this binary embeds, and
+// the scheduler is running. It answers 503 with the same body otherwise.
+// The probe is public, so database errors are logged rather than returned.
+func (h *HealthHandler) Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	ready := true
+	result := func(err error) check {
+		if err != nil {
+			ready = false
+			return check{Status: "fail", Error: err.Error()}
+		}
+		return check{Status: "ok"}
+	}
+	// hide logs err, whose text may name hosts, drivers or SQL, and
+	// returns msg in its place.
+	hide := func(name string, err error, msg string) error {
+		if err == nil {
+			return nil
+		}
+		slog.ErrorContext(ctx, "readiness check failed", "check", name, "error", err)
+		return errors.New(msg)
+	}
+	checks := gin.H{}
+
+	if h.db != nil {
+		start := time.Now()
+		dbErr := hide("database", h.db.PingContext(ctx), "database unavailable")
+		checks["database"] = databaseCheck{check: result(dbErr), LatencyMs: time.Since(start).Milliseconds()}
+
+		mc := migrationsCheck{Expected: database.LatestVersion(h.driver)}
+		if dbErr != nil {
+			mc.check = result(dbErr)
+		} else {
+			var err error
+			mc.Current, err = h.migrationVersion(ctx)
+			err = hide("migrations", err, "migration version unavailable")
+			if err == nil && mc.Current < mc.Expected {
+				err = fmt.Errorf("database is at version %d, expected %d", mc.Current, mc.Expected)
+			}
+			mc.check = result(err)
+		}
+		checks["migrations"] = mc
+	}
+
+	state := h.scheduler.State()
+	var schedErr error
+	switch {
+	case state.Stopping:
+		schedErr = errors.New("scheduler is stopping")
+	case !state.Started:
+		schedErr = errors.New("scheduler not started")
+	}
+	checks["scheduler"] = schedulerCheck{check: result(schedErr), SchedulerState: state}
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{"status": status, "checks": checks})
+}
+
+func (h *HealthHandler) migrationVersion(ctx context.Context) (int64, error) {
+	var v int64
+	// A version is applied if its latest row says so, as goose decides it.
+	// Plain SQL so that it runs on Postgres and SQLite alike.
+	err := h.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(v.version_id), 0)
+		FROM `+database.VersionTable+` v
+		WHERE v.is_applied AND v.id = (
+			SELECT MAX(w.id) FROM `+database.VersionTable+` w WHERE w.version_id = v.version_id
+		)`).Scan(&v)
+	if err != nil {
+		return 0, fmt.Errorf("read migration version: %w", err)
+	}
+	return v, nil
+}
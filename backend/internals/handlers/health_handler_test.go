@@ -0,0 +1,118 @@
+package handlers_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"cronix.ashutosh.net/database"
+	"cronix.ashutosh.net/internals/handlers"
+	"cronix.ashutosh.net/internals/services"
+	"cronix.ashutosh.net/internals/store/memory"
+	"cronix.ashutosh.net/internals/store/sqlite"
+)
+
+type readiness struct {
+	Status string `json:"status"`
+	Checks map[string]struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	} `json:"checks"`
+}
+
+// ready calls the readiness probe on sqlDB, which may be nil, and returns
+// the status code, the decoded body and the raw body.
+func ready(t *testing.T, sqlDB *sql.DB, started bool) (int, readiness, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	sched := services.NewScheduler(services.NewJobsService(memory.New(), services.JobsSettings{}), time.Second)
+	if started {
+		if err := sched.Start(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = sched.Stop(context.Background()) })
+	}
+	r := gin.New()
+	r.GET("/readyz", handlers.NewHealthHandler(sqlDB, database.SQLite, sched).Ready)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	var body readiness
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, body, w.Body.String()
+}
+
+func openSQLite(t *testing.T, migrate bool) *sql.DB {
+	t.Helper()
+	sqlDB, err := sqlite.Open(filepath.Join(t.TempDir(), "cronix.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if migrate {
+		m, err := database.NewMigrator(sqlDB, database.SQLite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Up(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return sqlDB
+}
+
+func TestReady(t *testing.T) {
+	code, body, _ := ready(t, openSQLite(t, true), true)
+	if code != http.StatusOK || body.Status != "ready" {
+		t.Fatalf("readyz = %d %+v", code, body)
+	}
+	for _, name := range []string{"database", "migrations", "scheduler"} {
+		if body.Checks[name].Status != "ok" {
+			t.Errorf("%s check = %+v", name, body.Checks[name])
+		}
+	}
+
+	code, body, _ = ready(t, openSQLite(t, true), false)
+	if code != http.StatusServiceUnavailable || body.Checks["scheduler"].Error != "scheduler not started" {
+		t.Errorf("readyz before the scheduler starts = %d %+v", code, body)
+	}
+}
+
+// The memory store has no database to check.
+func TestReadyWithoutDatabase(t *testing.T) {
+	code, body, _ := ready(t, nil, true)
+	if code != http.StatusOK || len(body.Checks) != 1 || body.Checks["scheduler"].Status != "ok" {
+		t.Errorf("readyz = %d %+v, want only the scheduler checked", code, body)
+	}
+}
+
+// Database errors are reported with fixed messages, not the driver's.
+func TestReadyHidesDatabaseErrors(t *testing.T) {
+	code, body, raw := ready(t, openSQLite(t, false), true)
+	if code != http.StatusServiceUnavailable || body.Checks["migrations"].Error != "migration version unavailable" {
+		t.Errorf("readyz without migrations = %d %+v", code, body)
+	}
+	if strings.Contains(raw, "goose") || strings.Contains(raw, "no such table") {
+		t.Errorf("readyz discloses the SQL error: %s", raw)
+	}
+
+	closed := openSQLite(t, true)
+	closed.Close()
+	code, body, raw = ready(t, closed, true)
+	if code != http.StatusServiceUnavailable || body.Checks["database"].Error != "database unavailable" ||
+		body.Checks["migrations"].Error != "database unavailable" {
+		t.Errorf("readyz with the database down = %d %+v", code, body)
+	}
+	if strings.Contains(raw, "sql:") || strings.Contains(raw, "closed") {
+		t.Errorf("readyz discloses the driver error: %s", raw)
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/jobauth"
+	"cronix.ashutosh.net/internals/outbound"
 	"cronix.ashutosh.net/internals/services"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -27,10 +31,18 @@ type createJobReq struct {
 	Name     string            `json:"name" binding:"required"`
 	Schedule string            `json:"schedule" binding:"required"`
 	Endpoint string            `json:"endpoint" binding:"required"`
-	Method   string            `json:"method" binding:"required"`
+	Method   string            `json:"method"` // required for http jobs
+	Type     string            `json:"type"`   // http if empty
+	Config   json.RawMessage   `json:"config"`
 	Headers  map[string]string `json:"headers"`
 	Body     *string           `json:"body"`
 	Active   bool              `json:"active"`
+
+	Transport           outbound.Overrides `json:"transport"`
+	ClientCertificateID *string            `json:"client_certificate_id"`
+	Auth                jobauth.Config     `json:"auth"`
+	Redirects           outbound.Redirects `json:"redirects"`
+	Triggers            services.Triggers  `json:"triggers"`
 }
 
 func (h *JobsHandler) Create(c *gin.Context) {
@@ -40,32 +52,53 @@ func (h *JobsHandler) Create(c *gin.Context) {
 
 	var req createJobReq
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(invalidBody(err))
+		return
+	}
+
+	if p := req.Transport.Problems(); len(p) > 0 {
+		_ = c.Error(services.ValidationError("invalid transport settings", p))
+		return
+	}
+	if p := req.Auth.Problems(); len(p) > 0 {
+		_ = c.Error(services.ValidationError("invalid auth settings", p))
+		return
+	}
+	if p := req.Redirects.Problems(); len(p) > 0 {
+		_ = c.Error(services.ValidationError("invalid redirect settings", p))
+		return
+	}
+	cert, err := certificateID(req.ClientCertificateID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := h.js.ValidateJobType(req.Type, req.Endpoint, req.Config); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
 	// Test endpoint before creating the job
-	if err := h.js.TestEndpoint(c.Request.Context(), req.Endpoint, req.Method, req.Headers, req.Body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Endpoint test failed",
-			"details": err.Error(),
-			"message": "Please check your endpoint URL, method, headers, and body. Make sure the endpoint is accessible and returns a successful response (2xx status code).",
-		})
+	if h.js.IsCheck(req.Type) {
+		err = h.js.TestCheck(c.Request.Context(), req.Type, req.Endpoint, req.Config)
+	} else {
+		err = h.js.TestEndpoint(c.Request.Context(), uid, req.Endpoint, req.Method, req.Headers, req.Body, req.Transport, cert, req.Auth, "", req.Redirects)
+	}
+	if err != nil {
+		_ = c.Error(endpointTestFailed(err))
 		return
 	}
 
-	job, err := h.js.Create(c.Request.Context(), uid, req.Name, req.Schedule, req.Endpoint, req.Method, req.Headers, req.Body, req.Active)
+	job, err := h.js.Create(c.Request.Context(), uid, req.Name, req.Schedule, req.Endpoint, req.Method, req.Headers, req.Body, req.Active, req.Transport, cert, req.Auth, req.Redirects, req.Type, req.Config, req.Triggers)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
 	// Add job to scheduler if it's active
 	if job.Active {
-		if err := h.scheduler.AddJob(job); err != nil {
-			// Log error but don't fail the request
-			// The job is created in DB, just not scheduled
-		}
+		h.reschedule(c.Request.Context(), job)
 	}
 
 	c.JSON(http.StatusCreated, job)
@@ -81,29 +114,35 @@ func (h *JobsHandler) List(c *gin.Context) {
 
 	jobs, err := h.js.ListByUser(context.Background(), uid, int32(limit), int32(offset))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, jobs)
 }
 
 func (h *JobsHandler) Get(c *gin.Context) {
-	var id pgtype.UUID
-	_ = id.Scan(c.Param("id"))
+	id, err := jobID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
 	job, err := h.js.Get(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		_ = c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, job)
 }
 
 func (h *JobsHandler) Update(c *gin.Context) {
-	var id pgtype.UUID
-	_ = id.Scan(c.Param("id"))
+	id, err := jobID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
 	var req map[string]interface{}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(invalidBody(err))
 		return
 	}
 
@@ -112,13 +151,44 @@ func (h *JobsHandler) Update(c *gin.Context) {
 	method := getStrPtr(req["method"])
 	headers := getHeadersPtr(req["headers"])
 	body := getStrPtr(req["body"])
+	transport, err := getTransportPtr(req["transport"])
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	cert, err := getCertificatePtr(req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	auth, err := getAuthPtr(req["auth"])
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	redirects, err := getRedirectsPtr(req["redirects"])
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	typ := getStrPtr(req["type"])
+	config, err := getConfig(req["config"])
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	triggers, err := getTriggersPtr(req["triggers"])
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
 
 	// If any of these fields are being updated, we need to test the endpoint
-	if endpoint != nil || method != nil || headers != nil || body != nil {
+	if endpoint != nil || method != nil || headers != nil || body != nil || transport != nil || cert != nil || auth != nil || redirects != nil || typ != nil || config != nil {
 		// Get current job to fill in missing fields
 		currentJob, err := h.js.Get(c.Request.Context(), id)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			_ = c.Error(err)
 			return
 		}
 
@@ -128,166 +198,284 @@ func (h *JobsHandler) Update(c *gin.Context) {
 			testEndpoint = *endpoint
 		}
 
-		testMethod := currentJob.Method
-		if method != nil {
-			testMethod = *method
+		testType := currentJob.Type
+		if typ != nil {
+			testType = *typ
 		}
-
-		testHeaders := make(map[string]string)
-		if len(currentJob.Headers) > 0 {
-			json.Unmarshal(currentJob.Headers, &testHeaders)
-		}
-		if headers != nil {
-			testHeaders = *headers
+		testConfig := currentJob.Config
+		if config != nil {
+			testConfig = config
 		}
-
-		testBody := &currentJob.Body.String
-		if !currentJob.Body.Valid {
-			testBody = nil
-		}
-		if body != nil {
-			testBody = body
-		}
-
-		// Test the endpoint
-		if err := h.js.TestEndpoint(c.Request.Context(), testEndpoint, testMethod, testHeaders, testBody); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":   "Endpoint test failed",
-				"details": err.Error(),
-				"message": "Please check your endpoint URL, method, headers, and body. Make sure the endpoint is accessible and returns a successful response (2xx status code).",
-			})
+		if err := h.js.ValidateJobType(testType, testEndpoint, testConfig); err != nil {
+			_ = c.Error(err)
 			return
 		}
+		if h.js.IsCheck(testType) {
+			// Checks are tested by running them once
+			if err := h.js.TestCheck(c.Request.Context(), testType, testEndpoint, testConfig); err != nil {
+				_ = c.Error(endpointTestFailed(err))
+				return
+			}
+		} else {
+			testMethod := currentJob.Method
+			if method != nil {
+				testMethod = *method
+			}
+
+			testHeaders := make(map[string]string)
+			if len(currentJob.Headers) > 0 {
+				json.Unmarshal(currentJob.Headers, &testHeaders)
+			}
+			if headers != nil {
+				testHeaders = *headers
+			}
+
+			testBody := &currentJob.Body.String
+			if !currentJob.Body.Valid {
+				testBody = nil
+			}
+			if body != nil {
+				testBody = body
+			}
+
+			testTransport := outbound.ParseOverrides(currentJob.Transport)
+			if transport != nil {
+				testTransport = *transport
+			}
+
+			testCert := currentJob.ClientCertificateID
+			if cert != nil {
+				testCert = *cert
+			}
+
+			testRedirects := outbound.ParseRedirects(currentJob.Redirects)
+			if redirects != nil {
+				testRedirects = *redirects
+			}
+
+			testAuth, err := h.js.JobAuth(currentJob, auth)
+			if err != nil {
+				_ = c.Error(err)
+				return
+			}
+			signingSecret, err := h.js.JobSigningSecret(currentJob)
+			if err != nil {
+				_ = c.Error(err)
+				return
+			}
+
+			// Test the endpoint
+			if err := h.js.TestEndpoint(c.Request.Context(), currentJob.UserID, testEndpoint, testMethod, testHeaders, testBody, testTransport, testCert, testAuth, signingSecret, testRedirects); err != nil {
+				_ = c.Error(endpointTestFailed(err))
+				return
+			}
+		}
 	}
 
 	job, err := h.js.Update(c.Request.Context(), id,
 		getStrPtr(req["name"]), getStrPtr(req["schedule"]), endpoint, method,
-		headers, body, getBoolPtr(req["active"]),
+		headers, body, getBoolPtr(req["active"]), transport, cert, auth, redirects, typ, config, triggers,
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
-	// Update scheduler
-	if job.Active {
-		if err := h.scheduler.AddJob(job); err != nil {
-			// Log error but don't fail the request
-		}
-	} else {
+	h.reschedule(c.Request.Context(), job)
+	c.JSON(http.StatusOK, job)
+}
+
+// reschedule replaces the scheduler's copy of job, which scheduled runs
+// use, or unschedules the job if it is inactive.
+// The job is saved either way, so a scheduling failure is logged rather
+// than failing the request.
+func (h *JobsHandler) reschedule(ctx context.Context, job db.Job) {
+	if !job.Active {
 		h.scheduler.RemoveJob(job.ID.String())
+		return
+	}
+	if err := h.scheduler.AddJob(job); err != nil {
+		slog.ErrorContext(ctx, "failed to schedule job", "job_id", job.ID.String(), "error", err)
 	}
-
-	c.JSON(http.StatusOK, job)
 }
 
 func (h *JobsHandler) Delete(c *gin.Context) {
-	var id pgtype.UUID
-	_ = id.Scan(c.Param("id"))
+	id, err := jobID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
 
 	// Remove from scheduler first
 	h.scheduler.RemoveJob(id.String())
 
 	if err := h.js.Delete(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 	c.Status(http.StatusNoContent)
 }
 
+// SigningSecret returns the secret the job signs its requests with.
+func (h *JobsHandler) SigningSecret(c *gin.Context) {
+	id, err := jobID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var uid pgtype.UUID
+	_ = uid.Scan(c.GetString("user_id"))
+	secret, err := h.js.SigningSecret(c.Request.Context(), uid, id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"secret": secret})
+}
+
+// RotateSigningSecret starts signing the job's requests with a new secret.
+func (h *JobsHandler) RotateSigningSecret(c *gin.Context) {
+	id, err := jobID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var uid pgtype.UUID
+	_ = uid.Scan(c.GetString("user_id"))
+	job, secret, err := h.js.RotateSigningSecret(c.Request.Context(), uid, id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.reschedule(c.Request.Context(), job)
+	c.JSON(http.StatusOK, gin.H{"secret": secret})
+}
+
+// DisableSigning stops signing the job's requests.
+func (h *JobsHandler) DisableSigning(c *gin.Context) {
+	id, err := jobID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var uid pgtype.UUID
+	_ = uid.Scan(c.GetString("user_id"))
+	job, err := h.js.DisableSigning(c.Request.Context(), uid, id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	h.reschedule(c.Request.Context(), job)
+	c.Status(http.StatusNoContent)
+}
+
 func (h *JobsHandler) RunNow(c *gin.Context) {
-	var id pgtype.UUID
-	_ = id.Scan(c.Param("id"))
+	id, err := jobID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
 	job, err := h.js.Get(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		_ = c.Error(err)
 		return
 	}
 	log, err := h.js.RunOnce(c.Request.Context(), job)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
+	c.JSON(http.StatusOK, logResponse(log))
+}
+
+func (h *JobsHandler) ListLogs(c *gin.Context) {
+	id, err := jobID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// Only the most recent logs are kept, so this is the whole history
+	logs, err := h.js.ListRecentLogs(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// Convert pgtype structs to simple JSON-compatible structs
+	responseLogs := make([]map[string]interface{}, len(logs))
+	for i, log := range logs {
+		responseLogs[i] = logResponse(log)
+	}
+
+	c.JSON(http.StatusOK, responseLogs)
+}
+
+// logResponse renders a job log for the API. Unset columns are omitted;
+// timings holds only the phases that were measured for the run.
+func logResponse(log db.JobLog) map[string]interface{} {
 	responseLog := map[string]interface{}{
 		"id":     log.ID.String(),
 		"job_id": log.JobID.String(),
 		"status": log.Status,
 	}
+
 	if log.StartedAt.Valid {
 		responseLog["started_at"] = log.StartedAt.Time.Format("2006-01-02T15:04:05Z07:00")
 	}
+
 	if log.FinishedAt.Valid {
 		responseLog["finished_at"] = log.FinishedAt.Time.Format("2006-01-02T15:04:05Z07:00")
 	}
+
 	if log.DurationMs.Valid {
 		responseLog["duration_ms"] = log.DurationMs.Int32
 	}
+
 	if log.ResponseCode.Valid {
 		responseLog["response_code"] = log.ResponseCode.Int32
 	}
+
 	if log.Error.Valid {
 		responseLog["error"] = log.Error.String
 	}
+
 	if log.ResponseBody.Valid {
 		responseLog["response_body"] = log.ResponseBody.String
 	}
-	c.JSON(http.StatusOK, responseLog)
-}
-
-func (h *JobsHandler) ListLogs(c *gin.Context) {
-	var id pgtype.UUID
-	_ = id.Scan(c.Param("id"))
 
-	// Always return only the 5 most recent logs
-	logs, err := h.js.ListRecentLogs(c.Request.Context(), id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if log.RunGroup.Valid {
+		responseLog["run_group"] = log.RunGroup.String
 	}
 
-	// Convert pgtype structs to simple JSON-compatible structs
-	responseLogs := make([]map[string]interface{}, len(logs))
-	for i, log := range logs {
-		responseLog := map[string]interface{}{
-			"id":     log.ID.String(),
-			"job_id": log.JobID.String(),
-			"status": log.Status,
-		}
-
-		if log.StartedAt.Valid {
-			responseLog["started_at"] = log.StartedAt.Time.Format("2006-01-02T15:04:05Z07:00")
-		}
-
-		if log.FinishedAt.Valid {
-			responseLog["finished_at"] = log.FinishedAt.Time.Format("2006-01-02T15:04:05Z07:00")
-		}
-
-		if log.DurationMs.Valid {
-			responseLog["duration_ms"] = log.DurationMs.Int32
-		}
-
-		if log.ResponseCode.Valid {
-			responseLog["response_code"] = log.ResponseCode.Int32
-		}
-
-		if log.Error.Valid {
-			responseLog["error"] = log.Error.String
+	timings := map[string]int32{}
+	for name, v := range map[string]pgtype.Int4{
+		"dns_ms":      log.DnsMs,
+		"connect_ms":  log.ConnectMs,
+		"tls_ms":      log.TlsMs,
+		"ttfb_ms":     log.TtfbMs,
+		"download_ms": log.DownloadMs,
+	} {
+		if v.Valid {
+			timings[name] = v.Int32
 		}
+	}
+	responseLog["timings"] = timings
 
-		if log.ResponseBody.Valid {
-			responseLog["response_body"] = log.ResponseBody.String
+	if len(log.Redirects) > 0 {
+		var chain []outbound.Hop
+		if json.Unmarshal(log.Redirects, &chain) == nil {
+			responseLog["redirects"] = chain
 		}
-
-		responseLogs[i] = responseLog
 	}
 
-	c.JSON(http.StatusOK, responseLogs)
+	return responseLog
 }
 
 func (h *JobsHandler) CleanupAllLogs(c *gin.Context) {
 	err := h.js.CleanupAllOldLogs(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
@@ -300,12 +488,32 @@ type testEndpointReq struct {
 	Method   string            `json:"method" binding:"required"`
 	Headers  map[string]string `json:"headers"`
 	Body     *string           `json:"body"`
+
+	Transport           outbound.Overrides `json:"transport"`
+	ClientCertificateID *string            `json:"client_certificate_id"`
+	Auth                jobauth.Config     `json:"auth"`
+	Redirects           outbound.Redirects `json:"redirects"`
 }
 
 func (h *JobsHandler) TestEndpoint(c *gin.Context) {
 	var req testEndpointReq
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(invalidBody(err))
+		return
+	}
+	var uid pgtype.UUID
+	_ = uid.Scan(c.GetString("user_id"))
+	cert, err := certificateID(req.ClientCertificateID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if p := req.Auth.Problems(); len(p) > 0 {
+		_ = c.Error(services.ValidationError("invalid auth settings", p))
+		return
+	}
+	if p := req.Redirects.Problems(); len(p) > 0 {
+		_ = c.Error(services.ValidationError("invalid redirect settings", p))
 		return
 	}
 
@@ -315,9 +523,9 @@ func (h *JobsHandler) TestEndpoint(c *gin.Context) {
 		bodyReader = bytes.NewBufferString(*req.Body)
 	}
 
-	httpReq, err := http.NewRequest(req.Method, req.Endpoint, bodyReader)
+	httpReq, err := http.NewRequestWithContext(c.Request.Context(), req.Method, req.Endpoint, bodyReader)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(services.ValidationError("invalid endpoint request", err.Error()))
 		return
 	}
 	for k, v := range req.Headers {
@@ -327,17 +535,26 @@ func (h *JobsHandler) TestEndpoint(c *gin.Context) {
 		httpReq.Header.Set("Content-Type", "application/json")
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client, err := h.js.TestClient(c.Request.Context(), uid, req.Transport, cert)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if err := h.js.Authenticate(c.Request.Context(), httpReq, client, req.Auth); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	hops := h.js.FollowRedirects(client, req.Redirects)
 	resp, err := client.Do(httpReq)
+	h.js.Rejected(resp, req.Auth)
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		_ = c.Error(services.TargetError("failed to reach endpoint", req.Auth.RedactError(err)))
 		return
 	}
 	defer resp.Body.Close()
 
 	// Read body with a limit
-	const maxRead = 1 << 20 // 1MB
-	limited := io.LimitReader(resp.Body, maxRead)
+	limited := io.LimitReader(resp.Body, h.js.MaxResponseBytes())
 	respBytes, _ := io.ReadAll(limited)
 
 	// Try to parse JSON; if fails, return as string
@@ -356,12 +573,16 @@ func (h *JobsHandler) TestEndpoint(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	out := gin.H{
 		"status":      resp.StatusCode,
 		"status_text": resp.Status,
 		"headers":     hdrs,
 		"body":        parsed,
-	})
+	}
+	if chain := services.RedirectChain(*hops, resp, req.Auth); chain != nil {
+		out["redirects"] = chain
+	}
+	c.JSON(http.StatusOK, out)
 }
 
 func getStrPtr(v interface{}) *string {
@@ -400,3 +621,139 @@ func getHeadersPtr(v interface{}) *map[string]string {
 	}
 	return &out
 }
+
+// getTransportPtr decodes the transport object of an update request and
+// validates it; nil means the field was absent.
+func getTransportPtr(v interface{}) (*outbound.Overrides, error) {
+	if v == nil {
+		return nil, nil
+	}
+	raw, _ := json.Marshal(v)
+	var o outbound.Overrides
+	if err := json.Unmarshal(raw, &o); err != nil {
+		return nil, services.ValidationError("invalid transport settings", err.Error())
+	}
+	if p := o.Problems(); len(p) > 0 {
+		return nil, services.ValidationError("invalid transport settings", p)
+	}
+	return &o, nil
+}
+
+// getAuthPtr decodes the auth object of an update request; nil means the
+// field was absent. It is validated once merged with the job's current
+// secret.
+func getAuthPtr(v interface{}) (*jobauth.Config, error) {
+	if v == nil {
+		return nil, nil
+	}
+	raw, _ := json.Marshal(v)
+	var a jobauth.Config
+	if err := json.Unmarshal(raw, &a); err != nil {
+		return nil, services.ValidationError("invalid auth settings", err.Error())
+	}
+	return &a, nil
+}
+
+// getRedirectsPtr decodes the redirects object of an update request and
+// validates it; nil means the field was absent.
+func getRedirectsPtr(v interface{}) (*outbound.Redirects, error) {
+	if v == nil {
+		return nil, nil
+	}
+	raw, _ := json.Marshal(v)
+	var r outbound.Redirects
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, services.ValidationError("invalid redirect settings", err.Error())
+	}
+	if p := r.Problems(); len(p) > 0 {
+		return nil, services.ValidationError("invalid redirect settings", p)
+	}
+	return &r, nil
+}
+
+func getTriggersPtr(v interface{}) (*services.Triggers, error) {
+	if v == nil {
+		return nil, nil
+	}
+	raw, _ := json.Marshal(v)
+	var t services.Triggers
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return nil, services.ValidationError("invalid triggers", err.Error())
+	}
+	return &t, nil
+}
+
+// getConfig reads the config object of an update request as JSON; nil
+// means the field was absent.
+func getConfig(v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if _, ok := v.(map[string]interface{}); !ok {
+		return nil, services.ValidationError("invalid job config", "config must be an object")
+	}
+	b, _ := json.Marshal(v)
+	return b, nil
+}
+
+// getCertificatePtr reads client_certificate_id from an update request: nil
+// if the field is absent, an unset UUID if it is null to detach the
+// certificate.
+func getCertificatePtr(req map[string]interface{}) (*pgtype.UUID, error) {
+	v, ok := req["client_certificate_id"]
+	if !ok {
+		return nil, nil
+	}
+	if v == nil {
+		return &pgtype.UUID{}, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, services.ValidationError("invalid client certificate id", "client_certificate_id must be a string or null")
+	}
+	id, err := certificateID(&s)
+	return &id, err
+}
+
+// certificateID parses an optional client certificate id from a request body.
+func certificateID(s *string) (pgtype.UUID, error) {
+	var id pgtype.UUID
+	if s == nil || *s == "" {
+		return id, nil
+	}
+	if err := id.Scan(*s); err != nil {
+		return id, services.ValidationError("invalid client certificate id", *s)
+	}
+	return id, nil
+}
+
+// jobID parses the :id path parameter.
+func jobID(c *gin.Context) (pgtype.UUID, error) {
+	var id pgtype.UUID
+	if err := id.Scan(c.Param("id")); err != nil {
+		return id, services.ValidationError("invalid job id", c.Param("id"))
+	}
+	return id, nil
+}
+
+func invalidBody(err error) error {
+	return services.ValidationError("invalid request body", err.Error())
+}
+
+// endpointTestFailed wraps the reason a job's endpoint could not be
+// verified. The message is matched by the frontend, keep it stable. An
+// endpoint the network guard refused keeps its code, endpoint_not_allowed.
+func endpointTestFailed(err error) error {
+	code := "endpoint_test_failed"
+	var e *services.Error
+	if errors.As(err, &e) && e.Code == "endpoint_not_allowed" {
+		code = e.Code
+	}
+	return &services.Error{
+		Kind:    services.ErrValidation,
+		Code:    code,
+		Message: "Endpoint test failed",
+		Details: err.Error(),
+		Err:     err,
+	}
+}
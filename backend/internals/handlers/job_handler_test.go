@@ -0,0 +1,316 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"cronix.ashutosh.net/internals/config"
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/logging"
+	"cronix.ashutosh.net/internals/middleware"
+	"cronix.ashutosh.net/internals/netguard"
+	"cronix.ashutosh.net/internals/outbound"
+	"cronix.ashutosh.net/internals/router"
+	"cronix.ashutosh.net/internals/secretbox"
+	"cronix.ashutosh.net/internals/services"
+	"cronix.ashutosh.net/internals/store/memory"
+	"cronix.ashutosh.net/signature"
+)
+
+// api is the real router on an in-memory store, with a user to call it
+// as. Jobs may call loopback addresses, so targets can be httptest servers.
+type api struct {
+	t     *testing.T
+	h     http.Handler
+	st    *memory.Store
+	sched *services.Scheduler
+	user  db.User
+	token string
+}
+
+func newAPI(t *testing.T) api {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	st := memory.New()
+	guard, err := netguard.New([]string{"127.0.0.0/8", "::1/128"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob, err := outbound.New(outbound.Options{}, guard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := services.NewAuthService(st, "test-secret")
+	box, err := secretbox.New(make([]byte, secretbox.KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs := services.NewCertificatesService(st, box, ob)
+	jobs := services.NewJobsService(st, services.JobsSettings{
+		TestTimeout:      5 * time.Second,
+		MaxResponseBytes: 1 << 20,
+		LogsPerJob:       10,
+		Outbound:         ob,
+		Certificates:     certs,
+		Secrets:          box,
+	})
+	sched := services.NewScheduler(jobs, 5*time.Second)
+	h := router.New(router.Deps{
+		Auth:           auth,
+		AuthConfig:     &config.AuthConfig{},
+		AuthHTTPClient: http.DefaultClient,
+		Jobs:           jobs,
+		Certificates:   certs,
+		Scheduler:      sched,
+		Driver:         "memory",
+		AllowedOrigins: []string{"http://localhost:3000"},
+	})
+
+	user, err := st.CreateUser(context.Background(), db.CreateUserParams{Email: "dev@example.com", Provider: "google"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.GenerateJWT(user.ID.String(), user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api{t: t, h: h, st: st, sched: sched, user: user, token: token}
+}
+
+// do sends a request with body encoded as JSON, checks the response has
+// status want and decodes it into out unless out is nil.
+func (a api) do(method, path string, body any, want int, out any) {
+	a.t.Helper()
+	var r *http.Request
+	if body == nil {
+		r = httptest.NewRequest(method, path, nil)
+	} else {
+		b, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		r = httptest.NewRequest(method, path, bytes.NewReader(b))
+		r.Header.Set("Content-Type", "application/json")
+	}
+	r.Header.Set("Authorization", "Bearer "+a.token)
+	w := httptest.NewRecorder()
+	a.h.ServeHTTP(w, r)
+	if w.Code != want {
+		a.t.Fatalf("%s %s = %d, want %d: %s", method, path, w.Code, want, w.Body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			a.t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+}
+
+type job struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+type jobLog struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	ResponseCode int    `json:"response_code"`
+	ResponseBody string `json:"response_body"`
+}
+
+func TestJobLifecycle(t *testing.T) {
+	a := newAPI(t)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	}))
+	defer target.Close()
+
+	var created job
+	a.do("POST", "/api/jobs", map[string]any{
+		"name": "ping", "schedule": "@daily", "endpoint": target.URL, "method": "GET", "active": true,
+	}, http.StatusCreated, &created)
+	if !created.Active {
+		t.Fatal("created job is not active")
+	}
+	if n := a.sched.Len(); n != 1 {
+		t.Errorf("scheduled %d jobs after creating an active one", n)
+	}
+
+	var jobs []job
+	a.do("GET", "/api/jobs", nil, http.StatusOK, &jobs)
+	if len(jobs) != 1 || jobs[0].ID != created.ID {
+		t.Fatalf("listed %+v, want the created job", jobs)
+	}
+
+	// Deactivating a job unschedules it
+	var updated job
+	a.do("PUT", "/api/jobs/"+created.ID, map[string]any{"name": "ping2", "active": false}, http.StatusOK, &updated)
+	if updated.Name != "ping2" || updated.Active {
+		t.Errorf("updated job = %+v", updated)
+	}
+	if n := a.sched.Len(); n != 0 {
+		t.Errorf("scheduled %d jobs after deactivating the job", n)
+	}
+	a.do("PUT", "/api/jobs/"+created.ID, map[string]any{"active": true}, http.StatusOK, &updated)
+	if n := a.sched.Len(); n != 1 {
+		t.Errorf("scheduled %d jobs after activating the job again", n)
+	}
+	// Leaving active out keeps it
+	a.do("PUT", "/api/jobs/"+created.ID, map[string]any{"name": "ping3"}, http.StatusOK, &updated)
+	if !updated.Active || a.sched.Len() != 1 {
+		t.Errorf("job after an update without active = %+v, scheduled %d", updated, a.sched.Len())
+	}
+
+	var run jobLog
+	a.do("POST", "/api/jobs/"+created.ID+"/run", nil, http.StatusOK, &run)
+	if run.Status != "success" || run.ResponseCode != 200 || run.ResponseBody != "pong" {
+		t.Errorf("run = %+v", run)
+	}
+	var logs []jobLog
+	a.do("GET", "/api/jobs/"+created.ID+"/logs", nil, http.StatusOK, &logs)
+	if len(logs) != 1 || logs[0].ID != run.ID {
+		t.Errorf("logs = %+v, want the run's log", logs)
+	}
+
+	a.do("DELETE", "/api/jobs/"+created.ID, nil, http.StatusNoContent, nil)
+	if n := a.sched.Len(); n != 0 {
+		t.Errorf("scheduled %d jobs after deleting the job", n)
+	}
+	a.do("GET", "/api/jobs/"+created.ID, nil, http.StatusNotFound, nil)
+}
+
+func TestCreateRejectsFailingEndpoint(t *testing.T) {
+	a := newAPI(t)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	a.do("POST", "/api/jobs", map[string]any{
+		"name": "down", "schedule": "@daily", "endpoint": closed.URL, "method": "GET", "active": true,
+	}, http.StatusBadRequest, &body)
+	if body.Code != "endpoint_test_failed" {
+		t.Errorf("code = %q, want endpoint_test_failed", body.Code)
+	}
+	var jobs []job
+	a.do("GET", "/api/jobs", nil, http.StatusOK, &jobs)
+	if len(jobs) != 0 || a.sched.Len() != 0 {
+		t.Errorf("a job was stored or scheduled: %+v", jobs)
+	}
+}
+
+// Scheduled runs sign with a job's new secret as soon as it is rotated,
+// and stop signing once signing is disabled.
+func TestSigningSecretReachesScheduledRuns(t *testing.T) {
+	a := newAPI(t)
+	var (
+		mu     sync.Mutex
+		header string
+	)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		header = r.Header.Get(signature.Header)
+		mu.Unlock()
+	}))
+	defer target.Close()
+	lastHeader := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return header
+	}
+
+	if err := a.sched.Start(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = a.sched.Stop(ctx)
+	})
+	var created job
+	a.do("POST", "/api/jobs", map[string]any{
+		"name": "signed", "schedule": "* * * * * *", "endpoint": target.URL, "method": "POST", "active": true,
+	}, http.StatusCreated, &created)
+
+	var rotated struct {
+		Secret string `json:"secret"`
+	}
+	a.do("POST", "/api/jobs/"+created.ID+"/signing-secret", nil, http.StatusOK, &rotated)
+	signedWith := func(secret string) bool {
+		return signature.Verify(lastHeader(), secret, "POST", "/", nil, signature.DefaultTolerance, time.Now()) == nil
+	}
+	waitFor(t, 3*time.Second, "a run signed with the secret", func() bool { return signedWith(rotated.Secret) })
+
+	a.do("POST", "/api/jobs/"+created.ID+"/signing-secret", nil, http.StatusOK, &rotated)
+	waitFor(t, 3*time.Second, "a run signed with the rotated secret", func() bool { return signedWith(rotated.Secret) })
+
+	a.do("DELETE", "/api/jobs/"+created.ID+"/signing-secret", nil, http.StatusNoContent, nil)
+	waitFor(t, 3*time.Second, "an unsigned run", func() bool { return lastHeader() == "" })
+}
+
+// waitFor polls cond until it holds or d passes.
+func waitFor(t *testing.T, d time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(d)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// A job that is saved but cannot be scheduled is logged with the request
+// that saved it.
+func TestScheduleFailureIsLogged(t *testing.T) {
+	a := newAPI(t)
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(logging.New(&buf, slog.LevelDebug))
+	defer slog.SetDefault(prev)
+
+	// Only a schedule stored before it was validated can fail
+	broken, err := a.st.CreateJob(context.Background(), db.CreateJobParams{
+		UserID: a.user.ID, Name: "broken", Schedule: "every now and then",
+		Endpoint: "http://127.0.0.1/", Method: "GET", Headers: []byte("{}"), Active: true,
+		Type: services.JobTypeHTTP,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("PUT", "/api/jobs/"+broken.ID.String(), strings.NewReader(`{"name":"still broken"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+a.token)
+	r.Header.Set(middleware.RequestIDHeader, "req-7")
+	w := httptest.NewRecorder()
+	a.h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT = %d: %s", w.Code, w.Body)
+	}
+
+	var logged bool
+	for _, line := range strings.Split(buf.String(), "\n") {
+		var rec map[string]any
+		if json.Unmarshal([]byte(line), &rec) != nil || rec["msg"] != "failed to schedule job" {
+			continue
+		}
+		logged = true
+		if rec["job_id"] != broken.ID.String() || rec["request_id"] != "req-7" || rec["error"] == "" {
+			t.Errorf("record = %v", rec)
+		}
+	}
+	if !logged {
+		t.Errorf("scheduling failure not logged:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cronix.ashutosh.net/internals/services"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"gopkg.in/yaml.v3"
+)
+
+// Export returns all of the caller's jobs as a manifest.
+// The format is chosen with ?format=yaml (default) or ?format=json.
+func (h *JobsHandler) Export(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var uid pgtype.UUID
+	_ = uid.Scan(userID)
+
+	m, err := h.js.ExportManifest(c.Request.Context(), uid)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	switch c.DefaultQuery("format", "yaml") {
+	case "yaml":
+		c.YAML(http.StatusOK, m)
+	case "json":
+		c.JSON(http.StatusOK, m)
+	default:
+		_ = c.Error(services.ValidationError("format must be yaml or json", nil))
+	}
+}
+
+// Apply reconciles the caller's jobs with the manifest in the request body.
+// YAML is accepted when the Content-Type mentions yaml, JSON otherwise.
+// With ?dry_run=true the plan is returned and nothing is changed.
+func (h *JobsHandler) Apply(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var uid pgtype.UUID
+	_ = uid.Scan(userID)
+
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		_ = c.Error(invalidBody(err))
+		return
+	}
+	var m services.Manifest
+	if strings.Contains(c.ContentType(), "yaml") {
+		err = yaml.Unmarshal(raw, &m)
+	} else {
+		err = json.Unmarshal(raw, &m)
+	}
+	if err != nil {
+		_ = c.Error(services.ValidationError("could not parse manifest", err.Error()))
+		return
+	}
+
+	res, err := h.js.ApplyManifest(c.Request.Context(), uid, m, dryRun)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// Keep the scheduler in step with what was written
+	for _, job := range res.Saved {
+		h.reschedule(c.Request.Context(), job)
+	}
+	for _, id := range res.Deleted {
+		h.scheduler.RemoveJob(id.String())
+	}
+
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,49 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestApplyKeepsSchedulerInStep(t *testing.T) {
+	a := newAPI(t)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	var created job
+	a.do("POST", "/api/jobs", map[string]any{
+		"name": "old", "schedule": "@daily", "endpoint": target.URL, "method": "GET", "active": true,
+	}, http.StatusCreated, &created)
+
+	manifest := map[string]any{"jobs": []map[string]any{
+		{"name": "new", "schedule": "@hourly", "endpoint": target.URL, "method": "GET"},
+		{"name": "paused", "schedule": "@hourly", "endpoint": target.URL, "method": "GET", "active": false},
+	}}
+	var plan struct {
+		DryRun  bool           `json:"dry_run"`
+		Summary map[string]int `json:"summary"`
+	}
+	a.do("POST", "/api/jobs/apply?dry_run=true", manifest, http.StatusOK, &plan)
+	if !plan.DryRun || plan.Summary["create"] != 2 || plan.Summary["delete"] != 1 {
+		t.Errorf("plan = %+v", plan)
+	}
+	var jobs []job
+	a.do("GET", "/api/jobs", nil, http.StatusOK, &jobs)
+	if len(jobs) != 1 || jobs[0].ID != created.ID {
+		t.Errorf("jobs after a dry run = %+v", jobs)
+	}
+	if n := a.sched.Len(); n != 1 {
+		t.Errorf("scheduled %d jobs after a dry run, want 1", n)
+	}
+
+	a.do("POST", "/api/jobs/apply", manifest, http.StatusOK, nil)
+	a.do("GET", "/api/jobs", nil, http.StatusOK, &jobs)
+	if len(jobs) != 2 {
+		t.Errorf("jobs after applying = %+v", jobs)
+	}
+	// old is gone and only new is active
+	if n := a.sched.Len(); n != 1 {
+		t.Errorf("scheduled %d jobs after applying, want 1", n)
+	}
+}
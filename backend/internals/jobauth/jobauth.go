@@ -0,0 +1,257 @@
+// Package jobauth authenticates the requests jobs send to their endpoints:
+// with an OAuth2 access token obtained by the client credentials grant,
+// with HTTP Basic auth, or with an API key in the query string.
+//
+// A job's Config is stored in two parts. The secret (client secret,
+// password or API key) is sealed in jobs.auth_secret; everything else is
+// plain JSON in jobs.auth and is returned by the API.
+package jobauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"cronix.ashutosh.net/internals/logging"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// Authentication types.
+const (
+	OAuth2ClientCredentials = "oauth2_client_credentials"
+	Basic                   = "basic"
+	APIKeyQuery             = "api_key_query"
+)
+
+type Config struct {
+	Type string `json:"type,omitempty" yaml:"type,omitempty"` // empty for none
+
+	// OAuth2ClientCredentials
+	TokenURL     string   `json:"token_url,omitempty" yaml:"token_url,omitempty"`
+	ClientID     string   `json:"client_id,omitempty" yaml:"client_id,omitempty"`
+	ClientSecret string   `json:"client_secret,omitempty" yaml:"client_secret,omitempty"`
+	Scopes       []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+
+	// Basic
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+
+	// APIKeyQuery
+	Param  string `json:"param,omitempty" yaml:"param,omitempty"` // query parameter name
+	APIKey string `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+}
+
+// IsZero reports whether c authenticates nothing.
+func (c Config) IsZero() bool { return c.Type == "" }
+
+// Problems lists what is wrong with c, including a missing secret.
+func (c Config) Problems() []string {
+	var p []string
+	switch c.Type {
+	case "":
+	case OAuth2ClientCredentials:
+		if u, err := url.Parse(c.TokenURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			p = append(p, "auth.token_url must be an http or https URL")
+		}
+		if c.ClientID == "" {
+			p = append(p, "auth.client_id is required")
+		}
+		if c.ClientSecret == "" {
+			p = append(p, "auth.client_secret is required")
+		}
+	case Basic:
+		if c.Username == "" {
+			p = append(p, "auth.username is required")
+		}
+	case APIKeyQuery:
+		if c.Param == "" {
+			p = append(p, "auth.param is required")
+		}
+		if c.APIKey == "" {
+			p = append(p, "auth.api_key is required")
+		}
+	default:
+		p = append(p, fmt.Sprintf("auth.type %q is not one of %s, %s or %s", c.Type, OAuth2ClientCredentials, Basic, APIKeyQuery))
+	}
+	return p
+}
+
+// Secret returns the field of c that is stored sealed.
+func (c Config) Secret() string {
+	switch c.Type {
+	case OAuth2ClientCredentials:
+		return c.ClientSecret
+	case Basic:
+		return c.Password
+	case APIKeyQuery:
+		return c.APIKey
+	}
+	return ""
+}
+
+// WithSecret returns c with its secret field set to s.
+func (c Config) WithSecret(s string) Config {
+	switch c.Type {
+	case OAuth2ClientCredentials:
+		c.ClientSecret = s
+	case Basic:
+		c.Password = s
+	case APIKeyQuery:
+		c.APIKey = s
+	}
+	return c
+}
+
+// Public returns c without its secret.
+func (c Config) Public() Config {
+	c.ClientSecret, c.Password, c.APIKey = "", "", ""
+	return c
+}
+
+// Encode returns the public part of c as stored in jobs.auth.
+func (c Config) Encode() []byte {
+	b, _ := json.Marshal(c.Public())
+	return b
+}
+
+// Parse decodes a jobs.auth value; anything unreadable is no auth.
+func Parse(raw []byte) Config {
+	var c Config
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &c)
+	}
+	return c
+}
+
+// Redact hides c's secret in s, such as an error that quotes a request URL
+// carrying an API key.
+func (c Config) Redact(s string) string {
+	secret := c.Secret()
+	if secret == "" {
+		return s
+	}
+	s = strings.ReplaceAll(s, secret, logging.Redacted)
+	return strings.ReplaceAll(s, url.QueryEscape(secret), logging.Redacted)
+}
+
+// RedactError wraps err so that its message hides c's secret while
+// errors.Is and errors.As still see err.
+func (c Config) RedactError(err error) error {
+	if err == nil || c.Secret() == "" {
+		return err
+	}
+	return &redacted{err: err, msg: c.Redact(err.Error())}
+}
+
+type redacted struct {
+	err error
+	msg string
+}
+
+func (e *redacted) Error() string { return e.msg }
+func (e *redacted) Unwrap() error { return e.err }
+
+// idleTTL is how long a cached token source may go unused before it is
+// dropped, so that tokens of deleted or changed jobs do not pile up.
+const idleTTL = time.Hour
+
+// Authenticator applies Configs to requests, caching OAuth2 tokens per set
+// of client credentials across runs until shortly before they expire.
+type Authenticator struct {
+	mu     sync.Mutex
+	tokens map[[sha256.Size]byte]*cachedToken
+}
+
+type cachedToken struct {
+	mu    sync.Mutex // held while fetching, so concurrent runs share one request
+	token *oauth2.Token
+	used  time.Time
+}
+
+func New() *Authenticator {
+	return &Authenticator{tokens: map[[sha256.Size]byte]*cachedToken{}}
+}
+
+// Apply adds c's credentials to req. A token is fetched with client when
+// none is cached; the token URL comes from the user, so client must be
+// one that dials through the network guard.
+func (a *Authenticator) Apply(ctx context.Context, req *http.Request, client *http.Client, c Config) error {
+	switch c.Type {
+	case "":
+	case OAuth2ClientCredentials:
+		tok, err := a.token(ctx, client, c)
+		if err != nil {
+			return fmt.Errorf("obtain OAuth2 token: %w", err)
+		}
+		tok.SetAuthHeader(req)
+	case Basic:
+		req.SetBasicAuth(c.Username, c.Password)
+	case APIKeyQuery:
+		q := req.URL.Query()
+		q.Set(c.Param, c.APIKey)
+		req.URL.RawQuery = q.Encode()
+	default:
+		return fmt.Errorf("unknown auth type %q", c.Type)
+	}
+	return nil
+}
+
+// Invalidate drops the token cached for c, for when an endpoint rejected
+// it before its expiry.
+func (a *Authenticator) Invalidate(c Config) {
+	if c.Type != OAuth2ClientCredentials {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.tokens, tokenKey(c))
+}
+
+func (a *Authenticator) token(ctx context.Context, client *http.Client, c Config) (*oauth2.Token, error) {
+	now := time.Now()
+	key := tokenKey(c)
+	a.mu.Lock()
+	for k, t := range a.tokens {
+		if now.Sub(t.used) > idleTTL {
+			delete(a.tokens, k)
+		}
+	}
+	t, ok := a.tokens[key]
+	if !ok {
+		t = &cachedToken{}
+		a.tokens[key] = t
+	}
+	t.used = now
+	a.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token.Valid() {
+		return t.token, nil
+	}
+	cc := clientcredentials.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		TokenURL:     c.TokenURL,
+		Scopes:       c.Scopes,
+	}
+	tok, err := cc.Token(context.WithValue(ctx, oauth2.HTTPClient, client))
+	if err != nil {
+		return nil, err
+	}
+	t.token = tok
+	return tok, nil
+}
+
+// tokenKey identifies a set of client credentials without keeping the
+// secret in memory as a map key.
+func tokenKey(c Config) [sha256.Size]byte {
+	return sha256.Sum256([]byte(strings.Join([]string{c.TokenURL, c.ClientID, c.ClientSecret, strings.Join(c.Scopes, " ")}, "\x00")))
+}
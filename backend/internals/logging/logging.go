@@ -0,0 +1,187 @@
+// Package logging configures the process-wide slog logger.
+//
+// Records are written as JSON to stdout. Request ids, run ids and run
+// groups stored on the context with WithRequestID, WithRunID and
+// WithRunGroup, and the active trace id, are added to every record logged through the *Context functions
+// (slog.InfoContext and so on). Attributes whose key names a credential are
+// redacted before they are written.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces sensitive values in log output.
+const Redacted = "[REDACTED]"
+
+// Setup installs a JSON logger as slog's default and routes the standard
+// log package through it. LOG_LEVEL selects debug, info (default), warn or
+// error.
+func Setup() *slog.Logger {
+	logger := New(os.Stdout, parseLevel(os.Getenv("LOG_LEVEL")))
+	slog.SetDefault(logger)
+	return logger
+}
+
+// New returns a JSON logger writing to w with correlation ids and redaction.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})
+	return slog.New(&contextHandler{Handler: h})
+}
+
+func parseLevel(s string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// StdLogger returns a *log.Logger that writes at level through slog, for
+// libraries that only accept the standard logger.
+func StdLogger(level slog.Level) *log.Logger {
+	return slog.NewLogLogger(slog.Default().Handler(), level)
+}
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	runIDKey
+	runGroupKey
+)
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func WithRunID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, runIDKey, id)
+}
+
+func RunID(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey).(string)
+	return id
+}
+
+// WithRunGroup marks the runs of ctx as part of the chain of triggered
+// runs with the given id.
+func WithRunGroup(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, runGroupKey, id)
+}
+
+func RunGroup(ctx context.Context) string {
+	id, _ := ctx.Value(runGroupKey).(string)
+	return id
+}
+
+// NewID returns a random 128-bit hex id for requests and runs.
+func NewID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if id := RunID(ctx); id != "" {
+		r.AddAttrs(slog.String("run_id", id))
+	}
+	if id := RunGroup(ctx); id != "" {
+		r.AddAttrs(slog.String("run_group", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if IsSensitive(a.Key) && a.Value.Kind() != slog.KindGroup {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+// IsSensitive reports whether a header, query parameter or attribute name
+// is likely to carry a credential.
+func IsSensitive(name string) bool {
+	n := strings.ToLower(name)
+	switch n {
+	case "authorization", "proxy-authorization", "cookie", "set-cookie", "code", "state":
+		return true
+	}
+	for _, marker := range []string{"token", "secret", "api-key", "apikey", "api_key", "password", "passwd", "session"} {
+		if strings.Contains(n, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactHeaders returns a copy of hdr with sensitive values replaced.
+func RedactHeaders(hdr map[string]string) map[string]string {
+	out := make(map[string]string, len(hdr))
+	for k, v := range hdr {
+		if IsSensitive(k) {
+			v = Redacted
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// RedactURL hides the password in raw and the values of sensitive query
+// parameters. Unparseable input is returned as is.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		changed := false
+		for k := range q {
+			if IsSensitive(k) {
+				q.Set(k, Redacted)
+				changed = true
+			}
+		}
+		if changed {
+			u.RawQuery = q.Encode()
+		}
+	}
+	return u.Redacted()
+}
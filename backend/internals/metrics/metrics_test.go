@@ -0,0 +1,63 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"cronix.ashutosh.net/internals/metrics"
+)
+
+// scrape returns the lines of /metrics that start with prefix.
+func scrape(t *testing.T, prefix string) []string {
+	t.Helper()
+	r := gin.New()
+	r.GET("/metrics", metrics.Handler())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d", w.Code)
+	}
+	var lines []string
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if strings.HasPrefix(line, prefix) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(metrics.Middleware())
+	r.GET("/things/:id", func(c *gin.Context) { c.Status(http.StatusTeapot) })
+
+	for _, path := range []string{"/things/1", "/things/2", "/nowhere"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// Routes are labelled by their pattern, so both things share a series
+	want := map[string]string{
+		`cronix_http_requests_total{code="418",method="GET",route="/things/:id"}`: "2",
+		`cronix_http_requests_total{code="404",method="GET",route="unmatched"}`:   "1",
+	}
+	for _, line := range scrape(t, "cronix_http_requests_total{") {
+		series, value, _ := strings.Cut(line, " ")
+		if w, ok := want[series]; ok {
+			if value != w {
+				t.Errorf("%s = %s, want %s", series, value, w)
+			}
+			delete(want, series)
+		}
+	}
+	for series := range want {
+		t.Errorf("missing %s", series)
+	}
+	if lines := scrape(t, `cronix_http_request_duration_seconds_count{method="GET",route="/things/:id"}`); len(lines) != 1 || !strings.HasSuffix(lines[0], " 2") {
+		t.Errorf("duration count = %q", lines)
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"net/http"
 	"strings"
 
@@ -14,15 +15,13 @@ func AuthMiddleware(authservice *services.AuthService) gin.HandlerFunc {
 		if err != nil {
 			authHeader := c.GetHeader("Authorization")
 			if authHeader == "" {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "No authentication token found"})
-				c.Abort()
+				RespondError(c, http.StatusUnauthorized, "unauthorized", "No authentication token found", nil)
 				return
 			}
 
 			parts := strings.Split(authHeader, " ")
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header"})
-				c.Abort()
+				RespondError(c, http.StatusUnauthorized, "unauthorized", "Invalid authorization header", nil)
 				return
 			}
 			token = parts[1]
@@ -30,8 +29,7 @@ func AuthMiddleware(authservice *services.AuthService) gin.HandlerFunc {
 
 		claims, err := authservice.ValidateJWT(token)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			c.Abort()
+			RespondError(c, http.StatusUnauthorized, "unauthorized", "invalid token", nil)
 			return
 		}
 
@@ -40,3 +38,17 @@ func AuthMiddleware(authservice *services.AuthService) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// StaticToken lets through requests that present token as a bearer token,
+// for endpoints such as /metrics that are scraped rather than used by
+// signed-in users.
+func StaticToken(token string) gin.HandlerFunc {
+	want := []byte("Bearer " + token)
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), want) != 1 {
+			RespondError(c, http.StatusUnauthorized, "unauthorized", "invalid token", nil)
+			return
+		}
+		c.Next()
+	}
+}
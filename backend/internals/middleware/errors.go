@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"cronix.ashutosh.net/internals/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorBody is the envelope every error response uses.
+// Error repeats Message for clients written against the older format.
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Error     string `json:"error"`
+}
+
+// RespondError aborts the request with an error envelope.
+func RespondError(c *gin.Context, status int, code, message string, details any) {
+	c.AbortWithStatusJSON(status, ErrorBody{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: c.GetString("request_id"),
+		Error:     message,
+	})
+}
+
+// Errors renders the last error a handler attached with c.Error.
+// Domain errors from services are mapped to their status code; anything
+// else is logged and reported as a generic internal error so database
+// messages never reach clients.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+
+		var e *services.Error
+		if !errors.As(err, &e) {
+			slog.ErrorContext(c.Request.Context(), "request failed", "method", c.Request.Method, "route", c.FullPath(), "error", err)
+			RespondError(c, http.StatusInternalServerError, "internal_error", "Internal server error", nil)
+			return
+		}
+
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(e, services.ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(e, services.ErrValidation):
+			status = http.StatusBadRequest
+		case errors.Is(e, services.ErrConflict):
+			status = http.StatusConflict
+		case errors.Is(e, services.ErrUpstream):
+			status = http.StatusBadGateway
+		}
+		RespondError(c, status, e.Code, e.Message, e.Details)
+	}
+}
+
+// NotFound answers unknown routes with the standard envelope.
+func NotFound(c *gin.Context) {
+	RespondError(c, http.StatusNotFound, "route_not_found", "route not found", c.Request.Method+" "+c.Request.URL.Path)
+}
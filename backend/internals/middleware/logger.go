@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"cronix.ashutosh.net/internals/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Logger writes one access log record per request. Query strings are
+// redacted because the OAuth callback carries the authorization code.
+// It must run after RequestID so the record carries the request id.
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.Log(c.Request.Context(), level, "request",
+			"method", c.Request.Method,
+			"path", logging.RedactURL(c.Request.URL.RequestURI()),
+			"route", c.FullPath(),
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+			"user_id", c.GetString("user_id"),
+		)
+	}
+}
+
+// Recovery turns a panic in a handler into a logged 500 with the standard
+// error envelope.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		slog.ErrorContext(c.Request.Context(), "panic serving request",
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"panic", err,
+		)
+		RespondError(c, http.StatusInternalServerError, "internal_error", "Internal server error", nil)
+	})
+}
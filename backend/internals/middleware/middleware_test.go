@@ -0,0 +1,143 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"cronix.ashutosh.net/internals/logging"
+	"cronix.ashutosh.net/internals/middleware"
+)
+
+// captureLogs sends slog's default logger to a buffer for the rest of the
+// test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(logging.New(&buf, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+// records decodes the JSON log records in buf.
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("record %q: %v", line, err)
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var seen string
+	r := gin.New()
+	r.Use(middleware.RequestID())
+	r.GET("/", func(c *gin.Context) {
+		seen = logging.RequestID(c.Request.Context())
+		if c.GetString("request_id") != seen {
+			t.Errorf("gin context has %q, request context %q", c.GetString("request_id"), seen)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		reused bool
+	}{
+		{"none", "", false},
+		{"sane", "req-1.abc_DEF", true},
+		{"too long", strings.Repeat("a", 129), false},
+		{"spaces", "req 1", false},
+		{"log injection", "req\n{\"level\":\"ERROR\"}", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set(middleware.RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			got := w.Header().Get(middleware.RequestIDHeader)
+			if got == "" || got != seen {
+				t.Fatalf("response id %q, handler saw %q", got, seen)
+			}
+			if (got == tt.header) != tt.reused {
+				t.Errorf("id = %q for header %q, want reused %v", got, tt.header, tt.reused)
+			}
+		})
+	}
+}
+
+func TestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	buf := captureLogs(t)
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.Logger())
+	r.GET("/auth/google/callback", func(c *gin.Context) {
+		slog.InfoContext(c.Request.Context(), "handling")
+		c.Status(http.StatusBadRequest)
+	})
+
+	req := httptest.NewRequest("GET", "/auth/google/callback?code=c0de&state=st4te&next=home", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-42")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	recs := records(t, buf)
+	if len(recs) != 2 {
+		t.Fatalf("logged %d records, want the handler's and the access log", len(recs))
+	}
+	for _, rec := range recs {
+		if rec["request_id"] != "req-42" {
+			t.Errorf("%v has request_id %v, want req-42", rec["msg"], rec["request_id"])
+		}
+	}
+	access := recs[1]
+	if access["msg"] != "request" || access["level"] != "WARN" || access["status"] != float64(400) || access["route"] != "/auth/google/callback" {
+		t.Errorf("access log = %v", access)
+	}
+	path, _ := access["path"].(string)
+	if strings.Contains(path, "c0de") || strings.Contains(path, "st4te") || !strings.Contains(path, "next=home") {
+		t.Errorf("path = %q, want code and state redacted", path)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	buf := captureLogs(t)
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.Recovery())
+	r.GET("/boom", func(*gin.Context) { panic("boom") })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/boom", nil))
+
+	var body middleware.ErrorBody
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusInternalServerError || body.Code != "internal_error" || body.RequestID == "" {
+		t.Errorf("response = %d %+v", w.Code, body)
+	}
+	recs := records(t, buf)
+	if len(recs) != 1 || recs[0]["panic"] != "boom" || recs[0]["request_id"] != body.RequestID {
+		t.Errorf("logged %v", recs)
+	}
+}
@@ -0,0 +1,176 @@
+// Package netguard keeps outbound requests made on behalf of users away
+// from the server's own network.
+//
+// Job endpoints are typed in by users, so without a guard CroniX would
+// fetch http://169.254.169.254/ or localhost:5432 from inside production.
+// The check runs in the dialer, after DNS resolution, against every
+// address actually connected to; a hostname that resolves to a public
+// address once and a private one later (DNS rebinding) is still caught,
+// and so is every hop of a redirect chain.
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// blockedRanges lists the destinations refused unless allowlisted, with
+// the reason given to the user.
+var blockedRanges = []struct {
+	prefix netip.Prefix
+	reason string
+}{
+	{netip.MustParsePrefix("0.0.0.0/8"), "unspecified address"},
+	{netip.MustParsePrefix("10.0.0.0/8"), "private network"},
+	{netip.MustParsePrefix("100.64.0.0/10"), "shared address space"},
+	{netip.MustParsePrefix("127.0.0.0/8"), "loopback address"},
+	{netip.MustParsePrefix("169.254.0.0/16"), "link-local or cloud metadata address"},
+	{netip.MustParsePrefix("172.16.0.0/12"), "private network"},
+	{netip.MustParsePrefix("192.0.0.0/24"), "reserved address"},
+	{netip.MustParsePrefix("192.168.0.0/16"), "private network"},
+	{netip.MustParsePrefix("198.18.0.0/15"), "reserved address"},
+	{netip.MustParsePrefix("224.0.0.0/4"), "multicast address"},
+	{netip.MustParsePrefix("240.0.0.0/4"), "reserved address"},
+	{netip.MustParsePrefix("::/128"), "unspecified address"},
+	{netip.MustParsePrefix("::1/128"), "loopback address"},
+	{netip.MustParsePrefix("::/96"), "IPv4-compatible address"}, // deprecated, may reach the embedded IPv4 address
+	{netip.MustParsePrefix("64:ff9b::/96"), "NAT64 address"},
+	{netip.MustParsePrefix("2001::/32"), "Teredo address"}, // embeds a server and an obfuscated client IPv4 address
+	{netip.MustParsePrefix("2002::/16"), "6to4 address"},   // embeds an IPv4 address, possibly an internal one
+	{netip.MustParsePrefix("fc00::/7"), "private network"}, // includes fd00:ec2::254, the IPv6 metadata endpoint
+	{netip.MustParsePrefix("fe80::/10"), "link-local address"},
+	{netip.MustParsePrefix("ff00::/8"), "multicast address"},
+}
+
+// BlockedError reports a destination the guard refused to connect to.
+// Addr is the zero Addr when the address must not be disclosed.
+type BlockedError struct {
+	Addr   netip.Addr
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	if !e.Addr.IsValid() {
+		return fmt.Sprintf("destination is not allowed (%s)", e.Reason)
+	}
+	return fmt.Sprintf("destination %s is not allowed (%s)", e.Addr, e.Reason)
+}
+
+// ErrRedirectScheme is returned when a redirect leaves http and https.
+var ErrRedirectScheme = errors.New("redirect to a non-HTTP URL is not allowed")
+
+// maxRedirects matches the limit of http.Client's default policy.
+const maxRedirects = 10
+
+// Guard decides which addresses outbound requests may reach.
+type Guard struct {
+	allow []netip.Prefix
+}
+
+// New returns a guard that blocks internal ranges except those in allow,
+// given as CIDRs or single addresses.
+func New(allow []string) (*Guard, error) {
+	g := &Guard{}
+	for _, s := range allow {
+		p, err := ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		g.allow = append(g.allow, p)
+	}
+	return g, nil
+}
+
+// ParsePrefix parses a CIDR, or a single address as a one-address prefix.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked(), nil
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%q is not an IP address or CIDR", s)
+	}
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
+
+// Check returns a *BlockedError if addr may not be connected to.
+func (g *Guard) Check(addr netip.Addr) error {
+	addr = addr.Unmap().WithZone("")
+	for _, p := range g.allow {
+		if p.Contains(addr) {
+			return nil
+		}
+	}
+	for _, r := range blockedRanges {
+		if r.prefix.Contains(addr) {
+			return &BlockedError{Addr: addr, Reason: r.reason}
+		}
+	}
+	return nil
+}
+
+// CheckHost resolves host and checks every address it has. It is for
+// requests sent through a proxy, where the proxy rather than this process
+// connects to the target; it cannot catch DNS rebinding like the dialer.
+func (g *Guard) CheckHost(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return g.Check(addr)
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if err := g.Check(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// control is a net.Dialer Control hook; address is the resolved ip:port.
+func (g *Guard) control(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("netguard: unexpected dial address %q: %w", address, err)
+	}
+	return g.Check(ap.Addr())
+}
+
+// Dialer returns a dialer that refuses blocked addresses.
+func (g *Guard) Dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   g.control,
+	}
+}
+
+// CheckRedirect is an http.Client CheckRedirect policy. Each hop is dialed
+// through the guard anyway; this refuses non-HTTP schemes and literal
+// blocked addresses before the request is even built.
+func (g *Guard) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	return g.CheckRedirectTarget(req.URL)
+}
+
+// CheckRedirectTarget applies the checks of CheckRedirect other than the
+// hop limit to u, for policies that count hops themselves.
+func (g *Guard) CheckRedirectTarget(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrRedirectScheme
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil {
+		return g.Check(addr)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package netguard_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"cronix.ashutosh.net/internals/netguard"
+)
+
+func TestCheck(t *testing.T) {
+	guard, err := netguard.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loopback, err := netguard.New([]string{"127.0.0.0/8", "10.0.0.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr   string
+		guard  *netguard.Guard
+		reason string // empty if allowed
+	}{
+		{"127.0.0.1", guard, "loopback address"},
+		{"127.255.255.254", guard, "loopback address"},
+		{"::1", guard, "loopback address"},
+		{"0.0.0.0", guard, "unspecified address"},
+		{"::", guard, "unspecified address"},
+		{"10.1.2.3", guard, "private network"},
+		{"172.16.0.1", guard, "private network"},
+		{"192.168.1.1", guard, "private network"},
+		{"fd00::1", guard, "private network"},
+		{"fd00:ec2::254", guard, "private network"},
+		{"169.254.169.254", guard, "link-local or cloud metadata address"},
+		{"fe80::1", guard, "link-local address"},
+		{"fe80::1%eth0", guard, "link-local address"},
+		{"100.64.0.1", guard, "shared address space"},
+		{"224.0.0.1", guard, "multicast address"},
+		{"ff02::1", guard, "multicast address"},
+
+		// IPv6 forms of IPv4 addresses
+		{"::ffff:127.0.0.1", guard, "loopback address"},
+		{"::ffff:169.254.169.254", guard, "link-local or cloud metadata address"},
+		{"::ffff:10.1.2.3", guard, "private network"},
+		{"::127.0.0.1", guard, "IPv4-compatible address"},
+		{"::8.8.8.8", guard, "IPv4-compatible address"},
+		{"64:ff9b::7f00:1", guard, "NAT64 address"},
+		{"2002:7f00:1::1", guard, "6to4 address"},
+		{"2002:a9fe:a9fe::1", guard, "6to4 address"},
+		{"2001:0:a9fe:a9fe::80ff:fffe", guard, "Teredo address"}, // client 127.0.0.1
+		{"2001:0:4136:e378:8000:63bf:3fff:fdd2", guard, "Teredo address"},
+
+		{"8.8.8.8", guard, ""},
+		{"::ffff:8.8.8.8", guard, ""},
+		{"2606:4700:4700::1111", guard, ""},
+		{"2001:4860:4860::8888", guard, ""},
+
+		// The allowlist wins over the blocked ranges, in either IP version
+		{"127.0.0.1", loopback, ""},
+		{"::ffff:127.0.0.1", loopback, ""},
+		{"10.0.0.5", loopback, ""},
+		{"10.0.0.6", loopback, "private network"},
+		{"::1", loopback, "loopback address"},
+	}
+	for _, tt := range tests {
+		addr := netip.MustParseAddr(tt.addr)
+		err := tt.guard.Check(addr)
+		var blocked *netguard.BlockedError
+		switch {
+		case tt.reason == "" && err != nil:
+			t.Errorf("Check(%s) = %v, want it allowed", tt.addr, err)
+		case tt.reason == "":
+		case !errors.As(err, &blocked):
+			t.Errorf("Check(%s) = %v, want a *BlockedError", tt.addr, err)
+		case blocked.Reason != tt.reason:
+			t.Errorf("Check(%s) reason = %q, want %q", tt.addr, blocked.Reason, tt.reason)
+		case blocked.Addr.Is4In6() || blocked.Addr.Zone() != "":
+			t.Errorf("Check(%s) reports %s, want it unmapped and without zone", tt.addr, blocked.Addr)
+		}
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	for in, want := range map[string]string{
+		"10.0.0.0/8":   "10.0.0.0/8",
+		"10.1.2.3/8":   "10.0.0.0/8",
+		"10.1.2.3":     "10.1.2.3/32",
+		"fd00::/8":     "fd00::/8",
+		"::1":          "::1/128",
+		"not-an-ip":    "",
+		"10.0.0.0/33":  "",
+		"10.0.0.0/8/8": "",
+	} {
+		p, err := netguard.ParsePrefix(in)
+		if want == "" {
+			if err == nil {
+				t.Errorf("ParsePrefix(%q) = %s, want an error", in, p)
+			}
+			continue
+		}
+		if err != nil || p.String() != want {
+			t.Errorf("ParsePrefix(%q) = %s, %v; want %s", in, p, err, want)
+		}
+	}
+}
+
+// The dialer's Control hook sees the resolved address of every
+// connection.
+func TestDialerControl(t *testing.T) {
+	guard, err := netguard.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	control := guard.Dialer().Control
+	for address, blocked := range map[string]bool{
+		"127.0.0.1:80":              true,
+		"[::1]:443":                 true,
+		"[::ffff:127.0.0.1]:80":     true,
+		"169.254.169.254:80":        true,
+		"[fe80::1%eth0]:80":         true,
+		"[2002:7f00:1::1]:80":       true,
+		"[2001:0:a9fe:a9fe::1]:80":  true,
+		"93.184.216.34:443":         false,
+		"[2606:4700:4700::1111]:53": false,
+	} {
+		err := control("tcp", address, nil)
+		var be *netguard.BlockedError
+		if blocked != errors.As(err, &be) {
+			t.Errorf("Control(%s) = %v, want blocked %v", address, err, blocked)
+		}
+	}
+	if err := control("tcp", "example.com:80", nil); err == nil {
+		t.Error("Control accepted an unresolved address")
+	}
+}
+
+func TestDialerRefusesBlockedAddresses(t *testing.T) {
+	addr, port := listen(t)
+
+	guard, err := netguard.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blocked *netguard.BlockedError
+	if _, err := guard.Dialer().Dial("tcp", addr); !errors.As(err, &blocked) {
+		t.Errorf("dialing a loopback listener = %v, want a *BlockedError", err)
+	}
+	// A name is checked once resolved
+	if _, err := guard.Dialer().Dial("tcp", net.JoinHostPort("localhost", port)); !errors.As(err, &blocked) {
+		t.Errorf("dialing localhost = %v, want a *BlockedError", err)
+	}
+
+	allowed, err := netguard.New([]string{"127.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := allowed.Dialer().Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dialing an allowlisted listener: %v", err)
+	}
+	c.Close()
+}
+
+// A name that resolves to an allowed address first and to an internal
+// one on the next lookup (DNS rebinding) is refused on the second dial:
+// the check runs on each connection, not once per name.
+func TestDialerCatchesRebinding(t *testing.T) {
+	_, port := listen(t)
+
+	dns := newDNSServer(t, "rebind.test.", "127.0.0.1", "169.254.169.254")
+	guard, err := netguard.New([]string{"127.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := guard.Dialer()
+	d.Resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "udp", dns)
+		},
+	}
+	target := net.JoinHostPort("rebind.test", port)
+
+	c, err := d.Dial("tcp4", target)
+	if err != nil {
+		t.Fatalf("first dial: %v", err)
+	}
+	c.Close()
+	var blocked *netguard.BlockedError
+	if _, err := d.Dial("tcp4", target); !errors.As(err, &blocked) {
+		t.Fatalf("second dial = %v, want a *BlockedError", err)
+	}
+	if blocked.Addr != netip.MustParseAddr("169.254.169.254") {
+		t.Errorf("blocked %s, want the rebound address", blocked.Addr)
+	}
+}
+
+// listen accepts and closes connections on a loopback port.
+func listen(t *testing.T) (addr, port string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, port, _ = net.SplitHostPort(ln.Addr().String())
+	return ln.Addr().String(), port
+}
+
+// newDNSServer answers A queries for name with answers in turn, the last
+// one repeated, and returns its address.
+func newDNSServer(t *testing.T, name string, answers ...string) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	var mu sync.Mutex
+	next := func() [4]byte {
+		mu.Lock()
+		defer mu.Unlock()
+		a := netip.MustParseAddr(answers[0])
+		if len(answers) > 1 {
+			answers = answers[1:]
+		}
+		return a.As4()
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var p dnsmessage.Parser
+			h, err := p.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			q, err := p.Question()
+			if err != nil {
+				continue
+			}
+			b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: h.ID, Response: true, Authoritative: true})
+			b.EnableCompression()
+			_ = b.StartQuestions()
+			_ = b.Question(q)
+			_ = b.StartAnswers()
+			if q.Type == dnsmessage.TypeA && q.Name.String() == name {
+				rh := dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}
+				_ = b.AResource(rh, dnsmessage.AResource{A: next()})
+			}
+			msg, err := b.Finish()
+			if err != nil {
+				continue
+			}
+			_, _ = pc.WriteTo(msg, from)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
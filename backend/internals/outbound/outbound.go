@@ -0,0 +1,397 @@
+// Package outbound builds the HTTP transports every request CroniX sends
+// goes through, so connection pooling, HTTP/2, proxies and TLS settings are
+// configured in one place.
+//
+// Requests to user endpoints use Factory.Client, which dials through the
+// network guard and honours per-job Overrides and client certificates. Requests to fixed services
+// the server itself depends on, such as Google sign-in, use Factory.System.
+package outbound
+
+import (
+	"container/list"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cronix.ashutosh.net/internals/netguard"
+	"cronix.ashutosh.net/internals/tracing"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Options are the server-wide transport settings.
+type Options struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	HTTP2               bool
+	Proxy               string // URL of the proxy for http and https targets; empty for none
+	NoProxy             string // hosts reached directly, in NO_PROXY syntax
+	CAFile              string // PEM bundle trusted in addition to the system roots
+	TLSMinVersion       string // 1.0, 1.1, 1.2 or 1.3; empty means 1.2
+	MaxTransports       int    // transports kept for distinct job settings; 0 means DefaultMaxTransports
+}
+
+// DefaultMaxTransports bounds the transport cache when Options leave it
+// unset.
+const DefaultMaxTransports = 64
+
+// Overrides are the per-job transport settings, stored as JSON in
+// jobs.transport. Unset fields fall back to Options.
+type Overrides struct {
+	// Proxy replaces the server proxy for this job; an empty string sends
+	// the job's requests directly.
+	Proxy         *string `json:"proxy,omitempty" yaml:"proxy,omitempty"`
+	HTTP2         *bool   `json:"http2,omitempty" yaml:"http2,omitempty"`
+	TLSMinVersion string  `json:"tls_min_version,omitempty" yaml:"tls_min_version,omitempty"`
+}
+
+// IsZero reports whether o changes nothing.
+func (o Overrides) IsZero() bool {
+	return o.Proxy == nil && o.HTTP2 == nil && o.TLSMinVersion == ""
+}
+
+// Problems lists what is wrong with o, for validation errors.
+func (o Overrides) Problems() []string {
+	var p []string
+	if o.Proxy != nil && *o.Proxy != "" {
+		if err := checkProxyURL(*o.Proxy); err != nil {
+			p = append(p, "transport.proxy: "+err.Error())
+		}
+	}
+	if _, err := tlsVersion(o.TLSMinVersion); err != nil {
+		p = append(p, "transport.tls_min_version: "+err.Error())
+	}
+	return p
+}
+
+// ParseOverrides decodes a jobs.transport value; anything unreadable is
+// treated as no overrides.
+func ParseOverrides(raw []byte) Overrides {
+	var o Overrides
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &o)
+	}
+	return o
+}
+
+// Encode returns o as stored in jobs.transport.
+func (o Overrides) Encode() []byte {
+	b, _ := json.Marshal(o)
+	return b
+}
+
+// Identity is a client certificate presented to endpoints that ask for
+// one, for mutual TLS, with an optional bundle of CAs trusted for the
+// endpoint's own certificate besides the usual roots.
+type Identity struct {
+	ID          string // names the certificate in the transport cache; see Factory.Forget
+	Certificate tls.Certificate
+	CABundle    []byte
+}
+
+// Factory hands out transports built from Options and a guard. Transports
+// are cached per distinct Overrides and Identity, so connections are
+// pooled across runs of jobs that share settings. Overrides come from
+// users, so the cache keeps only the most recently used transports and
+// closes the idle connections of those it drops.
+type Factory struct {
+	opts   Options
+	guard  *netguard.Guard
+	roots  *x509.CertPool
+	proxy  func(*url.URL) (*url.URL, error)
+	system http.RoundTripper
+
+	mu    sync.Mutex
+	cache map[string]*list.Element // of *cached
+	lru   *list.List               // most recently used first
+}
+
+type cached struct {
+	key string
+	rt  http.RoundTripper
+	t   *http.Transport
+}
+
+// New checks opts and returns a factory. A nil guard blocks every
+// internal range.
+func New(opts Options, guard *netguard.Guard) (*Factory, error) {
+	if guard == nil {
+		guard, _ = netguard.New(nil)
+	}
+	if _, err := tlsVersion(opts.TLSMinVersion); err != nil {
+		return nil, fmt.Errorf("outbound: tls min version: %w", err)
+	}
+	if opts.MaxTransports < 0 {
+		return nil, fmt.Errorf("outbound: max transports must not be negative")
+	}
+	if opts.MaxTransports == 0 {
+		opts.MaxTransports = DefaultMaxTransports
+	}
+	f := &Factory{opts: opts, guard: guard, cache: map[string]*list.Element{}, lru: list.New()}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("outbound: read CA bundle: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("outbound: no certificates found in %s", opts.CAFile)
+		}
+		f.roots = roots
+	}
+	if opts.Proxy != "" {
+		if err := checkProxyURL(opts.Proxy); err != nil {
+			return nil, fmt.Errorf("outbound: proxy: %w", err)
+		}
+		f.proxy = (&httpproxy.Config{HTTPProxy: opts.Proxy, HTTPSProxy: opts.Proxy, NoProxy: opts.NoProxy}).ProxyFunc()
+	}
+
+	system := f.base(Overrides{})
+	system.Proxy = f.serverProxy
+	f.system = tracing.Transport(system)
+	return f, nil
+}
+
+// System returns the transport for services the server itself depends on.
+// It is not guarded: those destinations come from configuration, not users.
+func (f *Factory) System() http.RoundTripper { return f.system }
+
+// Guard returns the guard user requests are dialed through.
+func (f *Factory) Guard() *netguard.Guard { return f.guard }
+
+// RootCAs returns the pool endpoint certificates are verified against, or
+// nil for the system roots.
+func (f *Factory) RootCAs() *x509.CertPool { return f.roots }
+
+// Client returns a client for user endpoints with o applied, presenting id
+// if it is not nil. A zero timeout leaves requests bounded only by their
+// context.
+func (f *Factory) Client(o Overrides, id *Identity, timeout time.Duration) (*http.Client, error) {
+	rt, err := f.Transport(o, id)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: rt, Timeout: timeout, CheckRedirect: f.guard.CheckRedirect}, nil
+}
+
+// Transport returns the guarded transport for o and id, building it on
+// first use. Once MaxTransports are cached, the least recently used one is
+// dropped; requests still using it finish normally.
+func (f *Factory) Transport(o Overrides, id *Identity) (http.RoundTripper, error) {
+	if p := o.Problems(); len(p) > 0 {
+		return nil, fmt.Errorf("outbound: invalid overrides: %v", p)
+	}
+	key := cacheKey(o, id)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if e, ok := f.cache[key]; ok {
+		f.lru.MoveToFront(e)
+		return e.Value.(*cached).rt, nil
+	}
+	t, err := f.guarded(o, id)
+	if err != nil {
+		return nil, err
+	}
+	rt := tracing.Transport(t)
+	f.cache[key] = f.lru.PushFront(&cached{key: key, rt: rt, t: t})
+	for f.lru.Len() > f.opts.MaxTransports {
+		f.drop(f.lru.Back())
+	}
+	return rt, nil
+}
+
+// Forget drops the transports built for the identity with the given ID,
+// closing their idle connections. Call it when a certificate is deleted.
+func (f *Factory) Forget(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for key, e := range f.cache {
+		if strings.HasPrefix(key, id+"\x00") {
+			f.drop(e)
+		}
+	}
+}
+
+// drop removes a cached transport and closes its idle connections. f.mu
+// must be held.
+func (f *Factory) drop(e *list.Element) {
+	c := f.lru.Remove(e).(*cached)
+	c.t.CloseIdleConnections()
+	delete(f.cache, c.key)
+}
+
+func cacheKey(o Overrides, id *Identity) string {
+	var prefix string
+	if id != nil {
+		prefix = id.ID
+	}
+	return prefix + "\x00" + string(o.Encode())
+}
+
+// base applies Options and o to a clone of http.DefaultTransport. It sets
+// no proxy; callers choose one.
+func (f *Factory) base(o Overrides) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	if f.opts.MaxIdleConns > 0 {
+		t.MaxIdleConns = f.opts.MaxIdleConns
+	}
+	if f.opts.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = f.opts.MaxIdleConnsPerHost
+	}
+	if f.opts.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = f.opts.IdleConnTimeout
+	}
+
+	http2 := f.opts.HTTP2
+	if o.HTTP2 != nil {
+		http2 = *o.HTTP2
+	}
+	t.Protocols = new(http.Protocols)
+	t.Protocols.SetHTTP1(true)
+	t.Protocols.SetHTTP2(http2)
+
+	minVersion, _ := tlsVersion(f.opts.TLSMinVersion)
+	if o.TLSMinVersion != "" {
+		minVersion, _ = tlsVersion(o.TLSMinVersion)
+	}
+	t.TLSClientConfig = &tls.Config{MinVersion: minVersion, RootCAs: f.roots}
+	return t
+}
+
+// guarded builds a transport for user endpoints. Direct connections are
+// dialed through the guard. Through the server proxy, the proxy connects
+// to the target, so the target is checked by name before the request is
+// handed over and the dial to the proxy itself is trusted. A job's own
+// proxy is just another user-supplied address and is dialed through the
+// guard.
+func (f *Factory) guarded(o Overrides, id *Identity) (*http.Transport, error) {
+	t := f.base(o)
+	if err := f.present(t.TLSClientConfig, id); err != nil {
+		return nil, err
+	}
+	guarded := f.guard.Dialer()
+
+	switch {
+	case o.Proxy != nil && *o.Proxy == "":
+		t.DialContext = guarded.DialContext
+	case o.Proxy != nil:
+		u, _ := url.Parse(*o.Proxy)
+		t.Proxy = http.ProxyURL(u)
+		t.DialContext = guarded.DialContext
+	case f.proxy != nil:
+		trusted := map[string]bool{}
+		if u, err := url.Parse(f.opts.Proxy); err == nil {
+			trusted[hostPort(u)] = true
+		}
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			p, err := f.serverProxy(req)
+			if err != nil || p == nil {
+				return p, err
+			}
+			if err := f.guard.CheckHost(req.Context(), req.URL.Hostname()); err != nil {
+				return nil, err
+			}
+			return p, nil
+		}
+		direct := &net.Dialer{Timeout: guarded.Timeout, KeepAlive: guarded.KeepAlive}
+		t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if trusted[addr] {
+				return direct.DialContext(ctx, network, addr)
+			}
+			return guarded.DialContext(ctx, network, addr)
+		}
+	default:
+		t.DialContext = guarded.DialContext
+	}
+	return t, nil
+}
+
+// present adds id's certificate and CA bundle to cfg.
+func (f *Factory) present(cfg *tls.Config, id *Identity) error {
+	if id == nil {
+		return nil
+	}
+	cfg.Certificates = []tls.Certificate{id.Certificate}
+	if len(id.CABundle) == 0 {
+		return nil
+	}
+	var roots *x509.CertPool
+	if f.roots != nil {
+		roots = f.roots.Clone()
+	} else if sys, err := x509.SystemCertPool(); err == nil {
+		roots = sys
+	} else {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(id.CABundle) {
+		return fmt.Errorf("outbound: no certificates found in the CA bundle of %s", id.ID)
+	}
+	cfg.RootCAs = roots
+	return nil
+}
+
+func (f *Factory) serverProxy(req *http.Request) (*url.URL, error) {
+	if f.proxy == nil {
+		return nil, nil
+	}
+	return f.proxy(req.URL)
+}
+
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+func checkProxyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("%q must be an http, https or socks5 URL", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", raw)
+	}
+	return nil
+}
+
+func tlsVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("%q is not one of 1.0, 1.1, 1.2 or 1.3", v)
+}
@@ -0,0 +1,225 @@
+package outbound_test
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"io"
+	stdlog "log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cronix.ashutosh.net/internals/netguard"
+	"cronix.ashutosh.net/internals/outbound"
+)
+
+func newFactory(t *testing.T, opts outbound.Options, allow ...string) *outbound.Factory {
+	t.Helper()
+	guard, err := netguard.New(allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := outbound.New(opts, guard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func get(t *testing.T, f *outbound.Factory, o outbound.Overrides, url string) (*http.Response, error) {
+	t.Helper()
+	c, err := f.Client(o, nil, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Get(url)
+	if err == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+// proxy is a forward proxy that records the targets it is asked for and
+// answers them itself.
+type proxy struct {
+	*httptest.Server
+	mu      sync.Mutex
+	targets []string
+}
+
+func newProxy(t *testing.T) *proxy {
+	p := &proxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.targets = append(p.targets, r.URL.String())
+		p.mu.Unlock()
+		w.Write([]byte("proxied"))
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *proxy) asked() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.targets
+}
+
+func TestJobProxyIsDialedThroughGuard(t *testing.T) {
+	p := newProxy(t)
+	via := p.URL
+
+	// The job's proxy is a user-supplied address like any other
+	f := newFactory(t, outbound.Options{})
+	var blocked *netguard.BlockedError
+	if _, err := get(t, f, outbound.Overrides{Proxy: &via}, "http://93.184.216.34/"); !errors.As(err, &blocked) {
+		t.Errorf("request through a loopback proxy = %v, want a *BlockedError", err)
+	}
+	if len(p.asked()) != 0 {
+		t.Errorf("the proxy was reached: %v", p.asked())
+	}
+
+	f = newFactory(t, outbound.Options{}, "127.0.0.0/8")
+	if _, err := get(t, f, outbound.Overrides{Proxy: &via}, "http://93.184.216.34/x"); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.asked(); len(got) != 1 || got[0] != "http://93.184.216.34/x" {
+		t.Errorf("proxy was asked for %v", got)
+	}
+}
+
+// The server proxy is trusted, but the targets sent through it are still
+// checked.
+func TestServerProxyChecksTargets(t *testing.T) {
+	p := newProxy(t)
+	f := newFactory(t, outbound.Options{Proxy: p.URL})
+
+	if _, err := get(t, f, outbound.Overrides{}, "http://93.184.216.34/ok"); err != nil {
+		t.Fatalf("request through the server proxy: %v", err)
+	}
+	var blocked *netguard.BlockedError
+	if _, err := get(t, f, outbound.Overrides{}, "http://169.254.169.254/latest/meta-data"); !errors.As(err, &blocked) {
+		t.Errorf("internal target through the server proxy = %v, want a *BlockedError", err)
+	}
+	if got := p.asked(); len(got) != 1 || got[0] != "http://93.184.216.34/ok" {
+		t.Errorf("proxy was asked for %v", got)
+	}
+
+	// An empty job proxy goes direct, through the guard
+	direct := ""
+	if _, err := get(t, f, outbound.Overrides{Proxy: &direct}, p.URL); !errors.As(err, &blocked) {
+		t.Errorf("direct request to a loopback address = %v, want a *BlockedError", err)
+	}
+}
+
+func TestTLSMinVersion(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	srv.Config.ErrorLog = stdlog.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newFactory(t, outbound.Options{CAFile: caFile}, "127.0.0.0/8")
+	resp, err := get(t, f, outbound.Overrides{}, srv.URL)
+	if err != nil {
+		t.Fatalf("TLS 1.2 with the default minimum: %v", err)
+	}
+	if resp.TLS.Version != tls.VersionTLS12 {
+		t.Errorf("negotiated %x, want TLS 1.2", resp.TLS.Version)
+	}
+	if _, err := get(t, f, outbound.Overrides{TLSMinVersion: "1.3"}, srv.URL); err == nil || !strings.Contains(err.Error(), "protocol version") {
+		t.Errorf("TLS 1.2 server with a 1.3 minimum = %v, want a version error", err)
+	}
+
+	f = newFactory(t, outbound.Options{CAFile: caFile, TLSMinVersion: "1.3"}, "127.0.0.0/8")
+	if _, err := get(t, f, outbound.Overrides{}, srv.URL); err == nil {
+		t.Error("TLS 1.2 server accepted with a server-wide 1.3 minimum")
+	}
+	if _, err := get(t, f, outbound.Overrides{TLSMinVersion: "1.2"}, srv.URL); err != nil {
+		t.Errorf("a job lowering the minimum to 1.2: %v", err)
+	}
+}
+
+func TestTransportCache(t *testing.T) {
+	var mu sync.Mutex
+	closed := 0
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateClosed {
+			mu.Lock()
+			closed++
+			mu.Unlock()
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	f := newFactory(t, outbound.Options{MaxTransports: 2}, "127.0.0.0/8")
+	h1, h2 := true, false
+	transport := func(o outbound.Overrides) http.RoundTripper {
+		t.Helper()
+		rt, err := f.Transport(o, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rt
+	}
+
+	first := transport(outbound.Overrides{})
+	if transport(outbound.Overrides{}) != first {
+		t.Error("the same settings got a new transport")
+	}
+	// Keep an idle connection on the first transport
+	if _, err := (&http.Client{Transport: first}).Get(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	second := transport(outbound.Overrides{HTTP2: &h1})
+	if second == first {
+		t.Error("different settings share a transport")
+	}
+	if transport(outbound.Overrides{}) != first {
+		t.Error("a transport was dropped before the cache was full")
+	}
+	// The first was used last, so the second makes way for the third
+	transport(outbound.Overrides{HTTP2: &h2})
+	if transport(outbound.Overrides{HTTP2: &h1}) == second {
+		t.Error("the least recently used transport was kept")
+	}
+	// which in turn dropped the first and closed its idle connection
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := closed
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the dropped transport's idle connection stayed open")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if transport(outbound.Overrides{}) == first {
+		t.Error("a dropped transport was handed out again")
+	}
+
+	if _, err := f.Transport(outbound.Overrides{TLSMinVersion: "2.0"}, nil); err == nil {
+		t.Error("invalid overrides were accepted")
+	}
+	if _, err := outbound.New(outbound.Options{MaxTransports: -1}, nil); err == nil {
+		t.Error("a negative cache size was accepted")
+	}
+}
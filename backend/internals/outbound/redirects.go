@@ -0,0 +1,113 @@
+package outbound
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cronix.ashutosh.net/internals/netguard"
+)
+
+// MaxRedirects is the most redirects a job may follow, and the limit when
+// its policy sets none.
+const MaxRedirects = 10
+
+// ErrCrossHostRedirect is returned for a redirect to another host when the
+// job's policy keeps it on the host it started on.
+var ErrCrossHostRedirect = errors.New("redirect to another host is not allowed")
+
+// Redirects is the per-job redirect policy, stored as JSON in
+// jobs.redirects. The zero value follows up to MaxRedirects redirects to
+// any host.
+type Redirects struct {
+	// Follow set to false makes the first redirect response the result of
+	// the run instead of following it.
+	Follow *bool `json:"follow,omitempty" yaml:"follow,omitempty"`
+	// MaxHops limits the redirects followed in a row; 0 means MaxRedirects.
+	MaxHops int `json:"max_hops,omitempty" yaml:"max_hops,omitempty"`
+	// AllowCrossHost set to false fails the run when a redirect leads to a
+	// host other than the endpoint's.
+	AllowCrossHost *bool `json:"allow_cross_host,omitempty" yaml:"allow_cross_host,omitempty"`
+}
+
+// IsZero reports whether r is the default policy.
+func (r Redirects) IsZero() bool {
+	return r.Follow == nil && r.MaxHops == 0 && r.AllowCrossHost == nil
+}
+
+// Following reports whether r follows redirects at all.
+func (r Redirects) Following() bool { return r.Follow == nil || *r.Follow }
+
+// Problems lists what is wrong with r, for validation errors.
+func (r Redirects) Problems() []string {
+	var p []string
+	if r.MaxHops < 0 || r.MaxHops > MaxRedirects {
+		p = append(p, fmt.Sprintf("redirects.max_hops must be between 0 and %d", MaxRedirects))
+	}
+	return p
+}
+
+// ParseRedirects decodes a jobs.redirects value; anything unreadable is
+// treated as the default policy.
+func ParseRedirects(raw []byte) Redirects {
+	var r Redirects
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &r)
+	}
+	return r
+}
+
+// Encode returns r as stored in jobs.redirects.
+func (r Redirects) Encode() []byte {
+	b, _ := json.Marshal(r)
+	return b
+}
+
+// Hop is one response of a redirected request.
+type Hop struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+}
+
+// CheckRedirect returns an http.Client CheckRedirect policy that applies r
+// in place of guard's hop limit and on top of its other checks. Every
+// redirect it follows is appended to chain if chain is not nil.
+func (r Redirects) CheckRedirect(guard *netguard.Guard, chain *[]Hop) func(*http.Request, []*http.Request) error {
+	max := r.MaxHops
+	if max == 0 {
+		max = MaxRedirects
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if !r.Following() {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > max {
+			return fmt.Errorf("stopped after %d redirects", max)
+		}
+		if r.AllowCrossHost != nil && !*r.AllowCrossHost && !strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) {
+			return fmt.Errorf("%w: %s", ErrCrossHostRedirect, req.URL.Host)
+		}
+		if err := guard.CheckRedirectTarget(req.URL); err != nil {
+			return err
+		}
+		if chain != nil {
+			*chain = append(*chain, Hop{URL: via[len(via)-1].URL.String(), Status: req.Response.StatusCode})
+		}
+		return nil
+	}
+}
+
+// Chain returns the responses of a request that ended with resp: the
+// redirects followed and resp itself. It is nil if the request was neither
+// redirected nor answered with a redirect.
+func Chain(followed []Hop, resp *http.Response) []Hop {
+	if resp == nil {
+		return followed
+	}
+	if len(followed) == 0 && (resp.StatusCode < 300 || resp.StatusCode > 399) {
+		return nil
+	}
+	return append(followed, Hop{URL: resp.Request.URL.String(), Status: resp.StatusCode})
+}
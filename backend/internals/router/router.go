@@ -0,0 +1,143 @@
+// Package router wires the handlers and middleware of the API into a gin
+// engine, so the server and tests serve exactly the same routes.
+package router
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+
+	"cronix.ashutosh.net/internals/config"
+	"cronix.ashutosh.net/internals/handlers"
+	"cronix.ashutosh.net/internals/metrics"
+	"cronix.ashutosh.net/internals/middleware"
+	"cronix.ashutosh.net/internals/openapi"
+	"cronix.ashutosh.net/internals/services"
+	"cronix.ashutosh.net/internals/tracing"
+)
+
+// Deps are what the routes are served with.
+type Deps struct {
+	Auth           *services.AuthService
+	AuthConfig     *config.AuthConfig
+	AuthHTTPClient *http.Client // talks to the OAuth provider
+	Jobs           *services.JobsService
+	Certificates   *services.CertificatesService
+	Scheduler      *services.Scheduler
+	DB             *sql.DB // checked by the readiness probe
+	Driver         string
+	AllowedOrigins []string // for CORS
+	MetricsToken   string   // required by /metrics, which is not served without one
+
+	// Extra registers routes of its own before CORS applies, as the
+	// server does for its endpoint test routes.
+	Extra func(*gin.Engine)
+}
+
+// New returns the engine serving every route of the API.
+func New(d Deps) *gin.Engine {
+	authHandler := handlers.NewAuthHandler(d.Auth, d.AuthConfig, d.AuthHTTPClient)
+	jobsHandler := handlers.NewJobsHandler(d.Jobs, d.Scheduler)
+	certsHandler := handlers.NewCertificatesHandler(d.Certificates)
+
+	r := gin.New()
+	r.Use(otelgin.Middleware(tracing.ServiceName), middleware.RequestID(), middleware.Logger(), metrics.Middleware(), middleware.Recovery(), middleware.Errors())
+	r.NoRoute(middleware.NotFound)
+
+	if d.Extra != nil {
+		d.Extra(r)
+	}
+
+	// Configure CORS from cors.allowed_origins plus the frontend URL
+	corsCfg := cors.DefaultConfig()
+	corsCfg.AllowOrigins = d.AllowedOrigins
+	corsCfg.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	corsCfg.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", middleware.RequestIDHeader}
+	corsCfg.ExposeHeaders = []string{middleware.RequestIDHeader}
+	corsCfg.AllowCredentials = true
+	r.Use(cors.New(corsCfg))
+
+	r.GET("/auth/google", authHandler.Login)
+	r.GET("/auth/google/callback", authHandler.Callback)
+	r.POST("/auth/logout", authHandler.Logout)
+
+	// Liveness and readiness probes
+	healthHandler := handlers.NewHealthHandler(d.DB, d.Driver, d.Scheduler)
+	r.GET("/healthz", healthHandler.Live)
+	r.GET("/readyz", healthHandler.Ready)
+
+	// API reference
+	openapi.Register(r)
+
+	// Prometheus metrics; they name jobs by ID, so scrapers need a token
+	if d.MetricsToken != "" {
+		r.GET("/metrics", middleware.StaticToken(d.MetricsToken), metrics.Handler())
+	}
+
+	// Lightweight ping endpoint for uptime checks
+	r.GET("/ping", func(c *gin.Context) {
+		c.String(200, "pong")
+	})
+
+	// Test routes (no auth required for testing)
+	r.GET("/test", func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"message":   "Backend is working!",
+			"timestamp": "2024-01-01T00:00:00Z",
+			"status":    "success",
+		})
+	})
+
+	// Webhook test endpoint that jobs can hit
+	r.POST("/webhook/test", func(c *gin.Context) {
+		var body map[string]interface{}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			_ = c.Error(services.ValidationError("Invalid JSON", err.Error()))
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"message":       "Webhook received successfully!",
+			"received_data": body,
+			"timestamp":     "2024-01-01T00:00:00Z",
+			"status":        "success",
+		})
+	})
+
+	// Simple GET webhook test
+	r.GET("/webhook/test", func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"message":   "GET webhook test successful!",
+			"timestamp": "2024-01-01T00:00:00Z",
+			"status":    "success",
+		})
+	})
+
+	api := r.Group("/api")
+	api.Use(middleware.AuthMiddleware(d.Auth))
+	{
+		api.GET("/profile", authHandler.GetProfile)
+		api.POST("/jobs", jobsHandler.Create)
+		api.GET("/jobs", jobsHandler.List)
+		api.GET("/jobs/export", jobsHandler.Export)
+		api.POST("/jobs/apply", jobsHandler.Apply)
+		api.GET("/jobs/:id", jobsHandler.Get)
+		api.PUT("/jobs/:id", jobsHandler.Update)
+		api.DELETE("/jobs/:id", jobsHandler.Delete)
+		api.POST("/jobs/:id/run", jobsHandler.RunNow)
+		api.GET("/jobs/:id/signing-secret", jobsHandler.SigningSecret)
+		api.POST("/jobs/:id/signing-secret", jobsHandler.RotateSigningSecret)
+		api.DELETE("/jobs/:id/signing-secret", jobsHandler.DisableSigning)
+		api.GET("/jobs/:id/logs", jobsHandler.ListLogs)
+		api.POST("/jobs/test", jobsHandler.TestEndpoint)
+		api.POST("/jobs/cleanup-logs", jobsHandler.CleanupAllLogs)
+		api.GET("/certificates", certsHandler.List)
+		api.POST("/certificates", certsHandler.Create)
+		api.DELETE("/certificates/:id", certsHandler.Delete)
+	}
+
+	return r
+}
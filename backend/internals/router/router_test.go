@@ -0,0 +1,93 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"cronix.ashutosh.net/internals/config"
+	"cronix.ashutosh.net/internals/netguard"
+	"cronix.ashutosh.net/internals/outbound"
+	"cronix.ashutosh.net/internals/router"
+	"cronix.ashutosh.net/internals/services"
+	"cronix.ashutosh.net/internals/store/memory"
+)
+
+// newRouter serves the API on an in-memory store with /boom, a route that
+// panics.
+func newRouter(t *testing.T, metricsToken string) http.Handler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	st := memory.New()
+	guard, err := netguard.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob, err := outbound.New(outbound.Options{}, guard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs := services.NewCertificatesService(st, nil, ob)
+	jobs := services.NewJobsService(st, services.JobsSettings{Outbound: ob, Certificates: certs})
+	return router.New(router.Deps{
+		Auth:           services.NewAuthService(st, "test-secret"),
+		AuthConfig:     &config.AuthConfig{},
+		AuthHTTPClient: http.DefaultClient,
+		Jobs:           jobs,
+		Certificates:   certs,
+		Scheduler:      services.NewScheduler(jobs, 5*time.Second),
+		Driver:         "memory",
+		AllowedOrigins: []string{"http://localhost:3000"},
+		MetricsToken:   metricsToken,
+		Extra: func(r *gin.Engine) {
+			r.GET("/boom", func(*gin.Context) { panic("boom") })
+		},
+	})
+}
+
+func get(h http.Handler, path, authorization string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", path, nil)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMetricsNeedToken(t *testing.T) {
+	if w := get(newRouter(t, ""), "/metrics", ""); w.Code != http.StatusNotFound {
+		t.Errorf("/metrics without a configured token = %d, want 404", w.Code)
+	}
+
+	h := newRouter(t, "scrape-me")
+	tests := []struct {
+		authorization string
+		want          int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"scrape-me", http.StatusUnauthorized},
+		{"Bearer scrape-me", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := get(h, "/metrics", tt.authorization); w.Code != tt.want {
+			t.Errorf("/metrics with %q = %d, want %d", tt.authorization, w.Code, tt.want)
+		}
+	}
+}
+
+func TestMetricsCountPanics(t *testing.T) {
+	h := newRouter(t, "scrape-me")
+	if w := get(h, "/boom", ""); w.Code != http.StatusInternalServerError {
+		t.Fatalf("/boom = %d, want 500", w.Code)
+	}
+	body := get(h, "/metrics", "Bearer scrape-me").Body.String()
+	if !strings.Contains(body, `cronix_http_requests_total{code="500",method="GET",route="/boom"} 1`) {
+		t.Errorf("the panicking request is not counted:\n%s", body)
+	}
+}
@@ -0,0 +1,82 @@
+// Package secretbox encrypts small secrets, such as private keys, before
+// they are written to the database.
+//
+// Values are sealed with AES-256-GCM under the server's secrets key. The
+// additional data passed to Seal must be passed to Open unchanged. CroniX
+// passes the owning user's ID, which binds a ciphertext to that user: a
+// value copied into another user's row does not decrypt. It is not bound to
+// a row or column, so one of the user's secrets copied over another of
+// theirs still does.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the length of a decoded secrets key.
+const KeySize = 32
+
+// version prefixes every ciphertext so the format can change later.
+const version = 1
+
+// ErrDecrypt is returned when a value was sealed under another key, with
+// other additional data, or has been tampered with.
+var ErrDecrypt = errors.New("secretbox: value cannot be decrypted with the configured key")
+
+type Box struct {
+	aead cipher.AEAD
+}
+
+// ParseKey decodes a base64 key of KeySize bytes, as produced by
+// "openssl rand -base64 32".
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("secrets key is not valid base64: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("secrets key must decode to %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+func New(key []byte) (*Box, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("secretbox: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("secretbox: %w", err)
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts plaintext; the result is the version byte, a random nonce
+// and the ciphertext.
+func (b *Box) Seal(plaintext, additional []byte) []byte {
+	out := make([]byte, 1+b.aead.NonceSize(), 1+b.aead.NonceSize()+len(plaintext)+b.aead.Overhead())
+	out[0] = version
+	if _, err := rand.Read(out[1:]); err != nil {
+		panic("secretbox: reading random nonce: " + err.Error())
+	}
+	return b.aead.Seal(out, out[1:], plaintext, additional)
+}
+
+// Open decrypts a value produced by Seal with the same additional data.
+func (b *Box) Open(sealed, additional []byte) ([]byte, error) {
+	n := b.aead.NonceSize()
+	if len(sealed) < 1+n || sealed[0] != version {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := b.aead.Open(nil, sealed[1:1+n], sealed[1+n:], additional)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
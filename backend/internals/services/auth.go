@@ -14,7 +14,7 @@ import (
 )
 
 type AuthService struct {
-	queries   *db.Queries
+	queries   Repository
 	jwtSecret string // Fixed: was jwtsecret
 }
 
@@ -24,7 +24,7 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-func NewAuthService(queries *db.Queries, jwtSecret string) *AuthService { // Fixed: was jwtsecret
+func NewAuthService(queries Repository, jwtSecret string) *AuthService { // Fixed: was jwtsecret
 	return &AuthService{
 		queries:   queries,
 		jwtSecret: jwtSecret, // Fixed: was jwtsecret
@@ -89,12 +89,12 @@ func (s *AuthService) GetUserByID(ctx context.Context, userID string) (*db.User,
 	// Convert string to UUID
 	var uuid pgtype.UUID
 	if err := uuid.Scan(userID); err != nil {
-		return nil, err
+		return nil, ValidationError("invalid user id", nil)
 	}
 
 	user, err := s.queries.GetUser(ctx, uuid)
 	if err != nil {
-		return nil, err
+		return nil, dbError(err, "user")
 	}
 	return &user, nil
 }
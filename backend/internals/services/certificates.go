@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/outbound"
+	"cronix.ashutosh.net/internals/secretbox"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// CertificatesService stores the client certificates jobs present to
+// endpoints that require mutual TLS. Private keys are sealed with the
+// server's secrets key and never leave the service.
+type CertificatesService struct {
+	q        Repository
+	box      *secretbox.Box // nil when no secrets key is configured
+	outbound *outbound.Factory
+}
+
+// NewCertificatesService returns the service. With a nil box certificates
+// cannot be uploaded or used, and every attempt is a validation error.
+func NewCertificatesService(q Repository, box *secretbox.Box, f *outbound.Factory) *CertificatesService {
+	return &CertificatesService{q: q, box: box, outbound: f}
+}
+
+// errCertificatesDisabled is returned by every operation that needs the
+// secrets key when none is configured.
+var errCertificatesDisabled = &Error{
+	Kind:    ErrValidation,
+	Code:    "certificates_disabled",
+	Message: "client certificates are not enabled on this server",
+	Details: "the administrator must set secrets.key (SECRETS_KEY)",
+}
+
+// Create checks that certPEM and keyPEM form a pair and that caPEM, if
+// given, holds at least one certificate, then stores them.
+func (s *CertificatesService) Create(ctx context.Context, userID pgtype.UUID, name, certPEM, keyPEM string, caPEM *string) (db.ClientCertificate, error) {
+	if s.box == nil {
+		return db.ClientCertificate{}, errCertificatesDisabled
+	}
+	var problems []string
+	if strings.TrimSpace(name) == "" {
+		problems = append(problems, "name is required")
+	}
+	pair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		problems = append(problems, "certificate and private_key: "+err.Error())
+	}
+	var ca pgtype.Text
+	if caPEM != nil && strings.TrimSpace(*caPEM) != "" {
+		if !x509.NewCertPool().AppendCertsFromPEM([]byte(*caPEM)) {
+			problems = append(problems, "ca_bundle: no PEM certificates found")
+		}
+		ca = pgtype.Text{String: *caPEM, Valid: true}
+	}
+	if len(problems) > 0 {
+		return db.ClientCertificate{}, ValidationError("invalid client certificate", problems)
+	}
+	leaf := pair.Leaf
+	if leaf.NotAfter.Before(time.Now()) {
+		return db.ClientCertificate{}, ValidationError("invalid client certificate", fmt.Sprintf("certificate expired on %s", leaf.NotAfter.Format(time.RFC3339)))
+	}
+	sum := sha256.Sum256(leaf.Raw)
+
+	cert, err := s.q.CreateClientCertificate(ctx, db.CreateClientCertificateParams{
+		UserID:      userID,
+		Name:        name,
+		Certificate: certPEM,
+		PrivateKey:  s.box.Seal([]byte(keyPEM), userID.Bytes[:]),
+		CaBundle:    ca,
+		Subject:     leaf.Subject.String(),
+		Fingerprint: hex.EncodeToString(sum[:]),
+		NotAfter:    pgtype.Timestamptz{Time: leaf.NotAfter, Valid: true},
+	})
+	return cert, dbError(err, "certificate")
+}
+
+func (s *CertificatesService) List(ctx context.Context, userID pgtype.UUID) ([]db.ClientCertificate, error) {
+	return s.q.ListClientCertificatesByUser(ctx, userID)
+}
+
+func (s *CertificatesService) Get(ctx context.Context, userID, id pgtype.UUID) (db.ClientCertificate, error) {
+	cert, err := s.q.GetClientCertificate(ctx, db.GetClientCertificateParams{ID: id, UserID: userID})
+	return cert, dbError(err, "certificate")
+}
+
+// Delete removes a certificate no job uses any more.
+func (s *CertificatesService) Delete(ctx context.Context, userID, id pgtype.UUID) error {
+	if _, err := s.Get(ctx, userID, id); err != nil {
+		return err
+	}
+	err := s.q.DeleteClientCertificate(ctx, db.DeleteClientCertificateParams{ID: id, UserID: userID})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign_key_violation
+		e := ConflictError("certificate is used by one or more jobs; detach it from them first")
+		e.Err = err
+		return e
+	}
+	if err != nil {
+		return err
+	}
+	s.outbound.Forget(id.String())
+	return nil
+}
+
+// Identity loads a certificate of userID and decrypts its key for use by
+// the outbound transports.
+func (s *CertificatesService) Identity(ctx context.Context, userID, id pgtype.UUID) (*outbound.Identity, error) {
+	if s.box == nil {
+		return nil, errCertificatesDisabled
+	}
+	cert, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.box.Open(cert.PrivateKey, userID.Bytes[:])
+	if err != nil {
+		return nil, fmt.Errorf("certificate %q: %w", cert.Name, err)
+	}
+	pair, err := tls.X509KeyPair([]byte(cert.Certificate), key)
+	if err != nil {
+		return nil, fmt.Errorf("certificate %q: %w", cert.Name, err)
+	}
+	ident := &outbound.Identity{ID: cert.ID.String(), Certificate: pair}
+	if cert.CaBundle.Valid {
+		ident.CABundle = []byte(cert.CaBundle.String)
+	}
+	return ident, nil
+}
@@ -0,0 +1,243 @@
+package services_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	stdlog "log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/jobauth"
+	"cronix.ashutosh.net/internals/netguard"
+	"cronix.ashutosh.net/internals/outbound"
+	"cronix.ashutosh.net/internals/services"
+)
+
+// pki is a throwaway CA with the certificates it issued, PEM encoded.
+type pki struct {
+	caPEM         string
+	server        tls.Certificate
+	clientCertPEM string
+	clientKeyPEM  string
+}
+
+func newPKI(t *testing.T) pki {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issue := func(serial int64, tmpl *x509.Certificate) (certPEM, keyPEM []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl.SerialNumber = big.NewInt(serial)
+		tmpl.NotBefore = time.Now().Add(-time.Hour)
+		tmpl.NotAfter = time.Now().Add(time.Hour)
+		tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	}
+
+	serverCert, serverKey := issue(2, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	server, err := tls.X509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, clientKey := issue(3, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "cronix job"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return pki{
+		caPEM:         string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})),
+		server:        server,
+		clientCertPEM: string(clientCert),
+		clientKeyPEM:  string(clientKey),
+	}
+}
+
+// mtlsServer requires a client certificate issued by p's CA and answers
+// with its common name.
+func mtlsServer(t *testing.T, p pki) *httptest.Server {
+	t.Helper()
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM([]byte(p.caPEM))
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{p.server},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	// The handshake failures below are expected
+	srv.Config.ErrorLog = stdlog.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// trusting returns outbound settings that trust p's CA on top of the
+// system roots and allow loopback targets.
+func trusting(t *testing.T, p pki) services.JobsSettings {
+	t.Helper()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte(p.caPEM), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	guard, err := netguard.New([]string{"127.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob, err := outbound.New(outbound.Options{CAFile: caFile}, guard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return services.JobsSettings{Outbound: ob}
+}
+
+func TestMutualTLS(t *testing.T) {
+	p := newPKI(t)
+	srv := mtlsServer(t, p)
+	e := newEnv(t, trusting(t, p))
+	ctx := context.Background()
+
+	cert, err := e.certs.Create(ctx, e.user.ID, "job identity", p.clientCertPEM, p.clientKeyPEM, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject != "CN=cronix job" || string(cert.PrivateKey) == p.clientKeyPEM {
+		t.Errorf("stored certificate = %+v, want the subject and a sealed key", cert)
+	}
+	withCert := func(p *db.CreateJobParams) { p.ClientCertificateID = cert.ID }
+
+	t.Run("run", func(t *testing.T) {
+		log, err := e.js.RunOnce(ctx, e.job(t, "mtls", srv.URL, withCert))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if log.Status != "success" || log.ResponseBody.String != "cronix job" {
+			t.Errorf("run = %s %q (error %q), want the certificate presented", log.Status, log.ResponseBody.String, log.Error.String)
+		}
+		if !log.TlsMs.Valid {
+			t.Error("no TLS timing recorded")
+		}
+	})
+
+	t.Run("run without a certificate", func(t *testing.T) {
+		log, err := e.js.RunOnce(ctx, e.job(t, "plain", srv.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if log.Status != "failure" || !strings.Contains(log.Error.String, "certificate required") {
+			t.Errorf("run = %s %q, want the server to ask for a certificate", log.Status, log.Error.String)
+		}
+	})
+
+	t.Run("test endpoint", func(t *testing.T) {
+		err := e.js.TestEndpoint(ctx, e.user.ID, srv.URL, "GET", nil, nil, outbound.Overrides{}, cert.ID, jobauth.Config{}, "", outbound.Redirects{})
+		if err != nil {
+			t.Errorf("TestEndpoint with the certificate = %v", err)
+		}
+	})
+
+	t.Run("test endpoint without a certificate", func(t *testing.T) {
+		err := e.js.TestEndpoint(ctx, e.user.ID, srv.URL, "GET", nil, nil, outbound.Overrides{}, pgtype.UUID{}, jobauth.Config{}, "", outbound.Redirects{})
+		if err == nil || !strings.Contains(err.Error(), "did not accept the client certificate") {
+			t.Errorf("TestEndpoint without the certificate = %v, want the server to ask for one", err)
+		}
+	})
+
+	t.Run("another user's certificate", func(t *testing.T) {
+		other, err := e.st.CreateUser(ctx, db.CreateUserParams{Email: "other@example.com", Provider: "google"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = e.js.TestEndpoint(ctx, other.ID, srv.URL, "GET", nil, nil, outbound.Overrides{}, cert.ID, jobauth.Config{}, "", outbound.Redirects{})
+		if !errors.Is(err, services.ErrNotFound) {
+			t.Errorf("TestEndpoint with another user's certificate = %v, want not found", err)
+		}
+	})
+}
+
+// A certificate's CA bundle is trusted for the endpoint's own certificate
+// when the server does not trust its CA.
+func TestMutualTLSCABundle(t *testing.T) {
+	p := newPKI(t)
+	srv := mtlsServer(t, p)
+	e := newEnv(t, services.JobsSettings{})
+	ctx := context.Background()
+
+	bundled, err := e.certs.Create(ctx, e.user.ID, "bundled", p.clientCertPEM, p.clientKeyPEM, &p.caPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bare, err := e.certs.Create(ctx, e.user.ID, "bare", p.clientCertPEM, p.clientKeyPEM, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := e.js.RunOnce(ctx, e.job(t, "bundled", srv.URL, func(p *db.CreateJobParams) { p.ClientCertificateID = bundled.ID }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.Status != "success" {
+		t.Errorf("run with the CA bundle = %s %q", log.Status, log.Error.String)
+	}
+	log, err = e.js.RunOnce(ctx, e.job(t, "bare", srv.URL, func(p *db.CreateJobParams) { p.ClientCertificateID = bare.ID }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.Status != "failure" || !strings.Contains(log.Error.String, "unknown authority") {
+		t.Errorf("run without the CA bundle = %s %q, want the server certificate rejected", log.Status, log.Error.String)
+	}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cronix.ashutosh.net/internals/db"
+)
+
+// JobTypeHTTP is the type of jobs that send an HTTP request, and the type
+// of jobs saved without one.
+const JobTypeHTTP = "http"
+
+// jobType is the type of job.
+func jobType(job db.Job) string {
+	if job.Type == "" {
+		return JobTypeHTTP
+	}
+	return job.Type
+}
+
+// IsCheck reports whether jobs of type typ are tested with TestCheck
+// rather than TestEndpoint, which explains HTTP failures in more detail.
+func (s *JobsService) IsCheck(typ string) bool {
+	if typ == "" || typ == JobTypeHTTP {
+		return false
+	}
+	_, ok := s.executors.Get(typ)
+	return ok
+}
+
+// ValidateJobType checks a job's type, and has its executor check the
+// job's endpoint and config.
+func (s *JobsService) ValidateJobType(typ, endpoint string, config []byte) error {
+	job := db.Job{Type: typ, Endpoint: endpoint, Config: config}
+	ex, ok := s.executors.Get(jobType(job))
+	if !ok {
+		return ValidationError("invalid job type", fmt.Sprintf("type %q is not one of %s", typ, strings.Join(s.executors.Types(), ", ")))
+	}
+	if p := ex.Problems(job); len(p) > 0 {
+		return ValidationError("invalid job config", p)
+	}
+	return nil
+}
+
+// TestCheck runs a check once before a job of type typ is saved.
+func (s *JobsService) TestCheck(ctx context.Context, typ, endpoint string, config []byte) error {
+	if err := s.ValidateJobType(typ, endpoint, config); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.settings.TestTimeout)
+	defer cancel()
+	job := db.Job{Type: typ, Endpoint: endpoint, Config: config}
+	ex, _ := s.executors.Get(jobType(job))
+	if res := ex.Execute(ctx, job); res.Err != nil {
+		return TargetError("check failed", res.Err)
+	}
+	return nil
+}
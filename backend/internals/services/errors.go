@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"cronix.ashutosh.net/internals/netguard"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Kinds of domain error. Use errors.Is(err, ErrNotFound) and so on to
+// classify an error returned by a service; anything that matches none of
+// them is an internal error whose text must not reach clients.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrValidation = errors.New("validation failed")
+	ErrConflict   = errors.New("conflict")
+	ErrUpstream   = errors.New("upstream request failed")
+)
+
+// Error is a domain error that is safe to show to API clients.
+type Error struct {
+	Kind    error  // one of ErrNotFound, ErrValidation, ErrConflict, ErrUpstream
+	Code    string // machine-readable, e.g. "job_not_found"
+	Message string
+	Details any   // string or []string with more specific causes
+	Err     error // underlying cause, never shown to clients
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	switch d := e.Details.(type) {
+	case string:
+		if d != "" {
+			msg += ": " + d
+		}
+	case []string:
+		if len(d) > 0 {
+			msg += fmt.Sprintf(": %v", d)
+		}
+	}
+	return msg
+}
+
+func (e *Error) Is(target error) bool { return target == e.Kind }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func NotFoundError(resource string) *Error {
+	return &Error{Kind: ErrNotFound, Code: resource + "_not_found", Message: resource + " not found"}
+}
+
+func ValidationError(message string, details any) *Error {
+	return &Error{Kind: ErrValidation, Code: "validation_failed", Message: message, Details: details}
+}
+
+func ConflictError(message string) *Error {
+	return &Error{Kind: ErrConflict, Code: "conflict", Message: message}
+}
+
+func UpstreamError(message string, err error) *Error {
+	e := &Error{Kind: ErrUpstream, Code: "upstream_error", Message: message, Err: err}
+	if err != nil {
+		e.Details = err.Error()
+	}
+	return e
+}
+
+// TargetError classifies a failed request to a user's endpoint: one the
+// network guard refused is a validation error, anything else is upstream.
+func TargetError(message string, err error) *Error {
+	if e := notAllowed(err); e != nil {
+		return e
+	}
+	return UpstreamError(message, err)
+}
+
+func notAllowed(err error) *Error {
+	var blocked *netguard.BlockedError
+	var details string
+	switch {
+	case errors.As(err, &blocked):
+		details = blocked.Error()
+	case errors.Is(err, netguard.ErrRedirectScheme):
+		details = netguard.ErrRedirectScheme.Error()
+	default:
+		return nil
+	}
+	return &Error{Kind: ErrValidation, Code: "endpoint_not_allowed", Message: "endpoint not allowed", Details: details, Err: err}
+}
+
+// dbError translates errors from the db package into domain errors where
+// they have a meaning for the caller and returns the rest unchanged.
+func dbError(err error, resource string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		e := NotFoundError(resource)
+		e.Err = err
+		return e
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505": // unique_violation
+			e := ConflictError(resource + " already exists")
+			e.Err = err
+			return e
+		case "22P02": // invalid_text_representation, e.g. a malformed uuid
+			e := ValidationError("invalid "+resource+" id", nil)
+			e.Err = err
+			return e
+		}
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/netguard"
+	"cronix.ashutosh.net/internals/outbound"
+	"cronix.ashutosh.net/internals/secretbox"
+	"cronix.ashutosh.net/internals/services"
+	"cronix.ashutosh.net/internals/store/memory"
+)
+
+// env is a jobs service on an in-memory store with one user. Jobs may
+// call loopback addresses, so targets can be httptest servers; the rest of
+// the internal ranges stay blocked. Client certificates are enabled.
+type env struct {
+	st    *memory.Store
+	js    *services.JobsService
+	certs *services.CertificatesService
+	user  db.User
+}
+
+func newEnv(t *testing.T, settings services.JobsSettings) env {
+	t.Helper()
+	st := memory.New()
+	user, err := st.CreateUser(context.Background(), db.CreateUserParams{Email: "dev@example.com", Provider: "google"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Outbound == nil {
+		guard, err := netguard.New([]string{"127.0.0.0/8", "::1/128"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if settings.Outbound, err = outbound.New(outbound.Options{}, guard); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if settings.Certificates == nil {
+		box, err := secretbox.New(make([]byte, secretbox.KeySize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		settings.Certificates = services.NewCertificatesService(st, box, settings.Outbound)
+	}
+	if settings.TestTimeout == 0 {
+		settings.TestTimeout = 5 * time.Second
+	}
+	if settings.MaxResponseBytes == 0 {
+		settings.MaxResponseBytes = 1 << 20
+	}
+	if settings.LogsPerJob == 0 {
+		settings.LogsPerJob = 50
+	}
+	return env{st: st, js: services.NewJobsService(st, settings), certs: settings.Certificates, user: user}
+}
+
+// job stores an http job of the user straight in the store, without the
+// endpoint test Create makes. edit may change any of its parameters.
+func (e env) job(t *testing.T, name, endpoint string, edit ...func(*db.CreateJobParams)) db.Job {
+	t.Helper()
+	p := db.CreateJobParams{
+		UserID:   e.user.ID,
+		Name:     name,
+		Schedule: "@daily",
+		Endpoint: endpoint,
+		Method:   "POST",
+		Headers:  []byte("{}"),
+		Type:     services.JobTypeHTTP,
+	}
+	for _, f := range edit {
+		f(&p)
+	}
+	job, err := e.st.CreateJob(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+// logs returns the logs of job, newest first.
+func (e env) logs(t *testing.T, job db.Job) []db.JobLog {
+	t.Helper()
+	logs, err := e.js.ListLogs(context.Background(), job.ID, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logs
+}
+
+// waitFor polls cond until it holds or d passes.
+func waitFor(t *testing.T, d time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(d)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/executor"
+	"cronix.ashutosh.net/internals/logging"
+	"cronix.ashutosh.net/internals/outbound"
+)
+
+// httpExecutor runs http jobs. It sends the job's request to its endpoint
+// with the job's transport, client certificate, auth, signature and
+// redirect policy. Any response passes, whatever its status.
+type httpExecutor struct {
+	s *JobsService
+}
+
+func (httpExecutor) Problems(job db.Job) []string {
+	if len(job.Config) > 0 && string(job.Config) != "{}" {
+		return []string{"http jobs take no config"}
+	}
+	return nil
+}
+
+func (e httpExecutor) Execute(ctx context.Context, job db.Job) (res executor.Result) {
+	s := e.s
+	timer := &runTimer{}
+	defer timer.record(&res)
+
+	reqBody := []byte(nil)
+	if job.Body.Valid {
+		reqBody = []byte(job.Body.String)
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, timer.trace()), job.Method, job.Endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	var hdr map[string]string
+	if len(job.Headers) > 0 {
+		_ = json.Unmarshal(job.Headers, &hdr)
+		for k, v := range hdr {
+			req.Header.Set(k, v)
+		}
+	}
+	slog.DebugContext(ctx, "job request",
+		"job_id", job.ID.String(),
+		"method", job.Method,
+		"endpoint", logging.RedactURL(job.Endpoint),
+		"headers", logging.RedactHeaders(hdr),
+	)
+
+	// Runs have no client timeout; they are bounded by ctx
+	var client *http.Client
+	auth, err := s.openAuth(job)
+	var id *outbound.Identity
+	if err == nil {
+		id, err = s.identity(ctx, job.UserID, job.ClientCertificateID)
+	}
+	if err == nil {
+		client, err = s.outbound.Client(outbound.ParseOverrides(job.Transport), id, 0)
+	}
+	if err == nil {
+		if err = s.auth.Apply(ctx, req, client, auth); err != nil {
+			err = fmt.Errorf("authenticate: %w", err)
+		}
+	}
+	if err == nil {
+		var secret string
+		if secret, err = s.JobSigningSecret(job); err == nil {
+			sign(req, secret, reqBody)
+		}
+	}
+	var resp *http.Response
+	if err == nil {
+		hops := s.FollowRedirects(client, outbound.ParseRedirects(job.Redirects))
+		resp, err = client.Do(req)
+		s.Rejected(resp, auth)
+		res.Redirects = RedirectChain(*hops, resp, auth)
+	}
+	res.Err = auth.RedactError(err)
+	if resp != nil {
+		code := resp.StatusCode
+		res.Code = &code
+		if resp.Body != nil {
+			limited := io.LimitReader(resp.Body, s.settings.MaxResponseBytes)
+			res.Body, _ = io.ReadAll(limited)
+			resp.Body.Close()
+			timer.finishBody()
+		}
+	}
+	return res
+}
@@ -0,0 +1,236 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/jobauth"
+	"cronix.ashutosh.net/internals/secretbox"
+	"cronix.ashutosh.net/internals/services"
+)
+
+// newAuthEnv is newEnv with job credentials sealed by a zero key.
+func newAuthEnv(t *testing.T) env {
+	t.Helper()
+	box, err := secretbox.New(make([]byte, secretbox.KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newEnv(t, services.JobsSettings{Secrets: box})
+}
+
+// withAuth stores auth on job through Update, so its secret is sealed as
+// in the API.
+func (e env) withAuth(t *testing.T, job db.Job, auth jobauth.Config) db.Job {
+	t.Helper()
+	job, err := e.js.Update(context.Background(), job.ID, nil, nil, nil, nil, nil, nil, nil, nil, nil, &auth, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+// tokenServer issues tok-1, tok-2, ... valid for expiresIn seconds, and
+// counts how many it issued.
+type tokenServer struct {
+	url string
+
+	mu        sync.Mutex
+	issued    int
+	expiresIn int
+}
+
+func newTokenServer(t *testing.T, expiresIn int) *tokenServer {
+	t.Helper()
+	ts := &tokenServer{expiresIn: expiresIn}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if r.FormValue("grant_type") != "client_credentials" || id != "cronix" || secret != "s3cret" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		ts.mu.Lock()
+		ts.issued++
+		n := ts.issued
+		ts.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"tok-%d","token_type":"Bearer","expires_in":%d}`, n, ts.expiresIn)
+	}))
+	t.Cleanup(srv.Close)
+	ts.url = srv.URL
+	return ts
+}
+
+func (ts *tokenServer) count() int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.issued
+}
+
+// bearerTarget records the Authorization header of each request and
+// answers 401 to the tokens in reject.
+type bearerTarget struct {
+	url string
+
+	mu     sync.Mutex
+	seen   []string
+	reject map[string]bool
+}
+
+func newBearerTarget(t *testing.T) *bearerTarget {
+	t.Helper()
+	bt := &bearerTarget{reject: map[string]bool{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := r.Header.Get("Authorization")
+		bt.mu.Lock()
+		bt.seen = append(bt.seen, h)
+		rejected := bt.reject[h]
+		bt.mu.Unlock()
+		if rejected {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	bt.url = srv.URL
+	return bt
+}
+
+func (bt *bearerTarget) last() string {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	if len(bt.seen) == 0 {
+		return ""
+	}
+	return bt.seen[len(bt.seen)-1]
+}
+
+func oauth2Job(t *testing.T, e env, ts *tokenServer, endpoint string) db.Job {
+	t.Helper()
+	return e.withAuth(t, e.job(t, "oauth", endpoint), jobauth.Config{
+		Type:         jobauth.OAuth2ClientCredentials,
+		TokenURL:     ts.url,
+		ClientID:     "cronix",
+		ClientSecret: "s3cret",
+	})
+}
+
+// run runs job and returns the response code it got.
+func run(t *testing.T, e env, job db.Job) int32 {
+	t.Helper()
+	log, err := e.js.RunOnce(context.Background(), job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.Status != "success" {
+		t.Fatalf("run failed: %s", log.Error.String)
+	}
+	return log.ResponseCode.Int32
+}
+
+func TestOAuth2TokenIsCachedAcrossRuns(t *testing.T) {
+	e := newAuthEnv(t)
+	ts := newTokenServer(t, 3600)
+	target := newBearerTarget(t)
+	job := oauth2Job(t, e, ts, target.url)
+
+	for i := 0; i < 3; i++ {
+		run(t, e, job)
+		if got := target.last(); got != "Bearer tok-1" {
+			t.Errorf("run %d sent %q, want the first token", i+1, got)
+		}
+	}
+	if n := ts.count(); n != 1 {
+		t.Errorf("fetched %d tokens, want 1", n)
+	}
+}
+
+func TestOAuth2ExpiredTokenIsRefetched(t *testing.T) {
+	e := newAuthEnv(t)
+	// Tokens that expire within oauth2's expiry delta are already stale
+	ts := newTokenServer(t, 1)
+	target := newBearerTarget(t)
+	job := oauth2Job(t, e, ts, target.url)
+
+	run(t, e, job)
+	run(t, e, job)
+	if got := target.last(); got != "Bearer tok-2" {
+		t.Errorf("second run sent %q, want a new token", got)
+	}
+	if n := ts.count(); n != 2 {
+		t.Errorf("fetched %d tokens, want 2", n)
+	}
+}
+
+func TestOAuth2RejectedTokenIsDropped(t *testing.T) {
+	e := newAuthEnv(t)
+	ts := newTokenServer(t, 3600)
+	target := newBearerTarget(t)
+	job := oauth2Job(t, e, ts, target.url)
+
+	if code := run(t, e, job); code != http.StatusOK {
+		t.Fatalf("first run got %d", code)
+	}
+	// The endpoint revokes the token before it expires
+	target.mu.Lock()
+	target.reject["Bearer tok-1"] = true
+	target.mu.Unlock()
+	if code := run(t, e, job); code != http.StatusUnauthorized {
+		t.Fatalf("run with the revoked token got %d, want 401", code)
+	}
+	if code := run(t, e, job); code != http.StatusOK {
+		t.Errorf("run after the 401 got %d, want 200", code)
+	}
+	if got := target.last(); got != "Bearer tok-2" {
+		t.Errorf("run after the 401 sent %q, want a new token", got)
+	}
+	if n := ts.count(); n != 2 {
+		t.Errorf("fetched %d tokens, want 2", n)
+	}
+}
+
+func TestBasicAndAPIKeyAuth(t *testing.T) {
+	e := newAuthEnv(t)
+	reqs := make(chan *http.Request, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs <- r
+	}))
+	defer target.Close()
+
+	t.Run("basic", func(t *testing.T) {
+		job := e.withAuth(t, e.job(t, "basic", target.URL), jobauth.Config{
+			Type: jobauth.Basic, Username: "ops", Password: "hunter2",
+		})
+		run(t, e, job)
+		got := <-reqs
+		user, pass, ok := got.BasicAuth()
+		if !ok || user != "ops" || pass != "hunter2" {
+			t.Errorf("basic auth = %q, %q, %v", user, pass, ok)
+		}
+	})
+
+	t.Run("api key in query", func(t *testing.T) {
+		job := e.withAuth(t, e.job(t, "key", target.URL+"/hook?team=infra"), jobauth.Config{
+			Type: jobauth.APIKeyQuery, Param: "key", APIKey: "k&y=1",
+		})
+		run(t, e, job)
+		got := <-reqs
+		q := got.URL.Query()
+		if q.Get("key") != "k&y=1" || q.Get("team") != "infra" || got.URL.Path != "/hook" {
+			t.Errorf("request URL = %s", got.URL)
+		}
+		// The stored job keeps the endpoint without the key
+		stored, err := e.js.Get(context.Background(), job.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(stored.Endpoint, "key=") {
+			t.Errorf("stored endpoint = %s", stored.Endpoint)
+		}
+	})
+}
@@ -4,109 +4,441 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
 	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/executor"
+	"cronix.ashutosh.net/internals/jobauth"
+	"cronix.ashutosh.net/internals/logging"
+	"cronix.ashutosh.net/internals/metrics"
+	"cronix.ashutosh.net/internals/outbound"
+	"cronix.ashutosh.net/internals/secretbox"
+	"cronix.ashutosh.net/internals/tracing"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrAborted is the cancellation cause used when the server shuts down
+// while runs are in flight; RunOnce records such runs as "aborted".
+var ErrAborted = errors.New("run aborted by server shutdown")
+
+// JobsSettings tunes how JobsService calls endpoints and keeps logs.
+type JobsSettings struct {
+	TestTimeout      time.Duration // bounds endpoint tests; runs are bounded by their context
+	MaxResponseBytes int64         // response body kept per run or test
+	LogsPerJob       int32         // most recent logs kept per job
+
+	// Outbound builds the clients runs and tests use; nil means default
+	// transports that block all internal ranges.
+	Outbound *outbound.Factory
+
+	// Certificates supplies the client certificates attached to jobs; nil
+	// means jobs cannot use client certificates.
+	Certificates *CertificatesService
+
+	// Secrets seals the credentials jobs authenticate with; nil means
+	// only auth without a secret can be used.
+	Secrets *secretbox.Box
+
+	// Executors adds job types to http and the types of the executor
+	// package, or replaces them, by type.
+	Executors map[string]executor.Executor
+}
+
 type JobsService struct {
-	q *db.Queries
+	q        Repository
+	settings JobsSettings
+	outbound *outbound.Factory
+	certs    *CertificatesService
+	auth     *jobauth.Authenticator
+
+	executors *executor.Registry
 }
 
-func NewJobsService(q *db.Queries) *JobsService { return &JobsService{q: q} }
+func NewJobsService(q Repository, settings JobsSettings) *JobsService {
+	f := settings.Outbound
+	if f == nil {
+		f, _ = outbound.New(outbound.Options{HTTP2: true}, nil)
+	}
+	certs := settings.Certificates
+	if certs == nil {
+		certs = NewCertificatesService(q, nil, f)
+	}
+	s := &JobsService{
+		q:         q,
+		settings:  settings,
+		outbound:  f,
+		certs:     certs,
+		auth:      jobauth.New(),
+		executors: executor.NewRegistry(),
+	}
+	s.executors.Register(JobTypeHTTP, httpExecutor{s})
+	for typ, ex := range executor.Builtin(f.Guard(), f.RootCAs()) {
+		s.executors.Register(typ, ex)
+	}
+	for typ, ex := range settings.Executors {
+		s.executors.Register(typ, ex)
+	}
+	return s
+}
+
+// errAuthDisabled is returned when a job's auth has a secret but no
+// secrets key is configured to seal or open it.
+var errAuthDisabled = &Error{
+	Kind:    ErrValidation,
+	Code:    "auth_disabled",
+	Message: "job authentication with a secret is not enabled on this server",
+	Details: "the administrator must set secrets.key (SECRETS_KEY)",
+}
+
+// JobAuth returns the auth job uses, with its secret, after applying
+// update if it is not nil. An update that leaves the secret empty keeps
+// the current one as long as the auth type is unchanged.
+func (s *JobsService) JobAuth(job db.Job, update *jobauth.Config) (jobauth.Config, error) {
+	current, err := s.openAuth(job)
+	if err != nil || update == nil {
+		return current, err
+	}
+	auth := *update
+	if auth.Secret() == "" && auth.Type == current.Type {
+		auth = auth.WithSecret(current.Secret())
+	}
+	if p := auth.Problems(); len(p) > 0 {
+		return auth, ValidationError("invalid auth settings", p)
+	}
+	return auth, nil
+}
+
+// openAuth decodes the auth stored with job and decrypts its secret.
+func (s *JobsService) openAuth(job db.Job) (jobauth.Config, error) {
+	auth := jobauth.Parse(job.Auth)
+	if len(job.AuthSecret) == 0 {
+		return auth, nil
+	}
+	if s.settings.Secrets == nil {
+		return auth, errAuthDisabled
+	}
+	secret, err := s.settings.Secrets.Open(job.AuthSecret, job.UserID.Bytes[:])
+	if err != nil {
+		return auth, fmt.Errorf("job auth: %w", err)
+	}
+	return auth.WithSecret(string(secret)), nil
+}
+
+// sealAuth splits auth into the jobs.auth and jobs.auth_secret values.
+func (s *JobsService) sealAuth(userID pgtype.UUID, auth jobauth.Config) ([]byte, []byte, error) {
+	secret := auth.Secret()
+	if secret == "" {
+		return auth.Encode(), nil, nil
+	}
+	if s.settings.Secrets == nil {
+		return nil, nil, errAuthDisabled
+	}
+	return auth.Encode(), s.settings.Secrets.Seal([]byte(secret), userID.Bytes[:]), nil
+}
 
-func (s *JobsService) Create(ctx context.Context, userID pgtype.UUID, name, sched, endpoint, method string, headers map[string]string, body *string, active bool) (db.Job, error) {
-	var h []byte
+// Authenticate adds auth to req, fetching an OAuth2 token with client if
+// none is cached.
+func (s *JobsService) Authenticate(ctx context.Context, req *http.Request, client *http.Client, auth jobauth.Config) error {
+	if err := s.auth.Apply(ctx, req, client, auth); err != nil {
+		return TargetError("failed to authenticate", auth.RedactError(err))
+	}
+	return nil
+}
+
+// Rejected drops a cached token the endpoint answered with resp refused.
+func (s *JobsService) Rejected(resp *http.Response, auth jobauth.Config) {
+	if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+		s.auth.Invalidate(auth)
+	}
+}
+
+// TestClient is the client used for endpoint tests with the given
+// transport overrides, presenting the client certificate cert of userID
+// if it is set. Invalid overrides are a validation error.
+func (s *JobsService) TestClient(ctx context.Context, userID pgtype.UUID, transport outbound.Overrides, cert pgtype.UUID) (*http.Client, error) {
+	if p := transport.Problems(); len(p) > 0 {
+		return nil, ValidationError("invalid transport settings", p)
+	}
+	id, err := s.identity(ctx, userID, cert)
+	if err != nil {
+		return nil, err
+	}
+	return s.outbound.Client(transport, id, s.settings.TestTimeout)
+}
+
+// identity loads the client certificate cert of userID, or returns nil if
+// cert is not set.
+func (s *JobsService) identity(ctx context.Context, userID, cert pgtype.UUID) (*outbound.Identity, error) {
+	if !cert.Valid {
+		return nil, nil
+	}
+	return s.certs.Identity(ctx, userID, cert)
+}
+
+// MaxResponseBytes is how much of a response body is read and kept.
+func (s *JobsService) MaxResponseBytes() int64 { return s.settings.MaxResponseBytes }
+
+func (s *JobsService) Create(ctx context.Context, userID pgtype.UUID, name, sched, endpoint, method string, headers map[string]string, body *string, active bool, transport outbound.Overrides, cert pgtype.UUID, auth jobauth.Config, redirects outbound.Redirects, typ string, config []byte, triggers Triggers) (db.Job, error) {
+	if err := s.ValidateJobType(typ, endpoint, config); err != nil {
+		return db.Job{}, err
+	}
+	triggers, err := s.CheckTriggers(ctx, userID, pgtype.UUID{}, triggers)
+	if err != nil {
+		return db.Job{}, err
+	}
+	if p := transport.Problems(); len(p) > 0 {
+		return db.Job{}, ValidationError("invalid transport settings", p)
+	}
+	if p := redirects.Problems(); len(p) > 0 {
+		return db.Job{}, ValidationError("invalid redirect settings", p)
+	}
+	if p := auth.Problems(); len(p) > 0 {
+		return db.Job{}, ValidationError("invalid auth settings", p)
+	}
+	authJSON, authSecret, err := s.sealAuth(userID, auth)
+	if err != nil {
+		return db.Job{}, err
+	}
+	if cert.Valid {
+		if _, err := s.certs.Get(ctx, userID, cert); err != nil {
+			return db.Job{}, err
+		}
+	}
+	// headers is NOT NULL; a nil slice would be sent as NULL
+	h := []byte("{}")
 	if len(headers) > 0 {
 		h, _ = json.Marshal(headers)
 	}
-	return s.q.CreateJob(ctx, db.CreateJobParams{
-		UserID:   userID,
-		Name:     name,
-		Schedule: sched,
-		Endpoint: endpoint,
-		Method:   method,
-		Headers:  h,
-		Body:     pgtype.Text{String: getStr(body), Valid: body != nil},
-		Active:   active,
+	job, err := s.q.CreateJob(ctx, db.CreateJobParams{
+		UserID:    userID,
+		Name:      name,
+		Schedule:  sched,
+		Endpoint:  endpoint,
+		Method:    method,
+		Headers:   h,
+		Body:      pgtype.Text{String: getStr(body), Valid: body != nil},
+		Active:    active,
+		Transport: transport.Encode(),
+
+		ClientCertificateID: cert,
+		Auth:                authJSON,
+		AuthSecret:          authSecret,
+		Redirects:           redirects.Encode(),
+		Type:                typ,
+		Config:              config,
+		Triggers:            triggers.Encode(),
 	})
+	return job, dbError(err, "job")
 }
 
-func (s *JobsService) Update(ctx context.Context, id pgtype.UUID, name, schedule, endpoint, method *string, headers *map[string]string, body *string, active *bool) (db.Job, error) {
+// Update changes the fields that are not nil. A cert pointing at an unset
+// UUID detaches the job's client certificate, and an auth with no type
+// removes its authentication; see JobAuth for how secrets are kept. A new
+// type, config or endpoint is validated together with the others as they
+// will be saved, and new triggers against the user's other jobs.
+func (s *JobsService) Update(ctx context.Context, id pgtype.UUID, name, schedule, endpoint, method *string, headers *map[string]string, body *string, active *bool, transport *outbound.Overrides, cert *pgtype.UUID, auth *jobauth.Config, redirects *outbound.Redirects, typ *string, config []byte, triggers *Triggers) (db.Job, error) {
+	current, err := s.Get(ctx, id)
+	if err != nil {
+		return db.Job{}, err
+	}
+	if typ != nil || config != nil || endpoint != nil {
+		t, e, c := current.Type, current.Endpoint, current.Config
+		if typ != nil {
+			t = *typ
+		}
+		if endpoint != nil {
+			e = *endpoint
+		}
+		if config != nil {
+			c = config
+		}
+		if err := s.ValidateJobType(t, e, c); err != nil {
+			return db.Job{}, err
+		}
+	}
+	// An empty map clears the headers; only a nil pointer keeps them
 	var hdr []byte
-	if headers != nil && len(*headers) > 0 {
-		hdr, _ = json.Marshal(*headers)
-	}
-
-	return s.q.UpdateJob(ctx, db.UpdateJobParams{
-		ID:      id,
-		Column2: getStr(name),     // name
-		Column3: getStr(schedule), // schedule
-		Column4: getStr(endpoint), // endpoint
-		Column5: getStr(method),   // method
-		Headers: hdr,
-		Body:    toTextPtr(body),
-		Active:  getBool(active),
+	if headers != nil {
+		hdr = []byte("{}")
+		if len(*headers) > 0 {
+			hdr, _ = json.Marshal(*headers)
+		}
+	}
+	var tr []byte
+	if transport != nil {
+		if p := transport.Problems(); len(p) > 0 {
+			return db.Job{}, ValidationError("invalid transport settings", p)
+		}
+		tr = transport.Encode()
+	}
+	var redir []byte
+	if redirects != nil {
+		if p := redirects.Problems(); len(p) > 0 {
+			return db.Job{}, ValidationError("invalid redirect settings", p)
+		}
+		redir = redirects.Encode()
+	}
+	var trig []byte
+	if triggers != nil {
+		t, err := s.CheckTriggers(ctx, current.UserID, id, *triggers)
+		if err != nil {
+			return db.Job{}, err
+		}
+		trig = t.Encode()
+	}
+	var certID pgtype.UUID
+	if cert != nil && cert.Valid {
+		if _, err := s.certs.Get(ctx, current.UserID, *cert); err != nil {
+			return db.Job{}, err
+		}
+		certID = *cert
+	}
+	var authJSON, authSecret []byte
+	if auth != nil {
+		merged, err := s.JobAuth(current, auth)
+		if err != nil {
+			return db.Job{}, err
+		}
+		if authJSON, authSecret, err = s.sealAuth(current.UserID, merged); err != nil {
+			return db.Job{}, err
+		}
+	}
+
+	job, err := s.q.UpdateJob(ctx, db.UpdateJobParams{
+		ID:        id,
+		Column2:   getStr(name),     // name
+		Column3:   getStr(schedule), // schedule
+		Column4:   getStr(endpoint), // endpoint
+		Column5:   getStr(method),   // method
+		Headers:   hdr,
+		Body:      toTextPtr(body),
+		Active:    toBoolPtr(active),
+		Transport: tr,
+
+		SetClientCertificate: cert != nil,
+		ClientCertificateID:  certID,
+		Auth:                 authJSON,
+		AuthSecret:           authSecret,
+		Redirects:            redir,
+		Type:                 getStr(typ),
+		Config:               config,
+		Triggers:             trig,
 	})
+	return job, dbError(err, "job")
+}
+
+// forgetRuns drops the run counters of a deleted job, so that deleted
+// jobs do not keep their series in /metrics.
+func forgetRuns(id pgtype.UUID) {
+	metrics.JobRuns.DeletePartialMatch(prometheus.Labels{"job_id": id.String()})
 }
 
 func (s *JobsService) Get(ctx context.Context, id pgtype.UUID) (db.Job, error) {
-	return s.q.GetJob(ctx, id)
+	job, err := s.q.GetJob(ctx, id)
+	return job, dbError(err, "job")
 }
 
+// Delete deletes a job, its run counters, and removes it from the triggers
+// of other jobs.
 func (s *JobsService) Delete(ctx context.Context, id pgtype.UUID) error {
-	return s.q.DeleteJob(ctx, id)
+	job, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.q.DeleteJob(ctx, id); err != nil {
+		return dbError(err, "job")
+	}
+	forgetRuns(id)
+	return s.dropTriggersOf(ctx, job.UserID, id)
 }
 
 func (s *JobsService) RunOnce(ctx context.Context, job db.Job) (db.JobLog, error) {
-	start := time.Now()
-	code := 0
-	status := "success"
+	metrics.RunsInFlight.Inc()
+	defer metrics.RunsInFlight.Dec()
+
+	// Scheduled runs get their id from the scheduler; manual runs get one here
+	runID := logging.RunID(ctx)
+	if runID == "" {
+		runID = logging.NewID()
+		ctx = logging.WithRunID(ctx, runID)
+	}
+	// A run that was not triggered starts a group of its own
+	group := logging.RunGroup(ctx)
+	if group == "" {
+		group = runID
+		ctx = logging.WithRunGroup(ctx, group)
+	}
 
-	var errStr string
-	var respBodyStr string
+	ctx, span := tracing.Tracer().Start(ctx, "job.run", trace.WithAttributes(
+		attribute.String("cronix.run.id", runID),
+		attribute.String("cronix.run.group", group),
+		attribute.String("cronix.job.id", job.ID.String()),
+		attribute.String("cronix.job.name", job.Name),
+		attribute.String("cronix.job.type", job.Type),
+		attribute.String("http.request.method", job.Method),
+		attribute.String("url.full", job.Endpoint),
+	))
+	defer span.End()
 
-	hasResp := false
-	reqBody := []byte(nil)
-	if job.Body.Valid {
-		reqBody = []byte(job.Body.String)
-	}
-	req, newReqErr := http.NewRequest(job.Method, job.Endpoint, bytes.NewReader(reqBody))
-	if newReqErr != nil {
-		status, errStr = "failure", newReqErr.Error()
+	start := time.Now()
+	status := "success"
+	typ := jobType(job)
+	var res executor.Result
+	if ex, ok := s.executors.Get(typ); ok {
+		slog.DebugContext(ctx, "job run started", "job_id", job.ID.String(), "type", typ, "endpoint", logging.RedactURL(job.Endpoint))
+		res = ex.Execute(ctx, job)
 	} else {
-		if len(job.Headers) > 0 {
-			var hdr map[string]string
-			_ = json.Unmarshal(job.Headers, &hdr)
-			for k, v := range hdr {
-				req.Header.Set(k, v)
-			}
-		}
+		res.Err = fmt.Errorf("job type %q is not supported by this server", typ)
+	}
 
-		resp, err := http.DefaultClient.Do(req)
-		if resp != nil && resp.Body != nil {
-			const max = 1 << 20 // 1MB
-			limited := io.LimitReader(resp.Body, max)
-			b, _ := io.ReadAll(limited)
-			respBodyStr = string(b)
-			resp.Body.Close()
-		}
-		if err != nil {
-			status, errStr = "failure", err.Error()
+	var errStr string
+	if res.Err != nil {
+		status, errStr = "failure", res.Err.Error()
+		if e := notAllowed(res.Err); e != nil {
+			errStr = e.Error()
 		}
-		if resp != nil {
-			code = resp.StatusCode
-			hasResp = true
+		if errors.Is(context.Cause(ctx), ErrAborted) {
+			status, errStr = "aborted", ErrAborted.Error()
 		}
 	}
+	respBodyStr := string(res.Body)
+	if res.Body == nil && res.Details != nil {
+		b, _ := json.Marshal(res.Details)
+		respBodyStr = string(b)
+	}
+	code, hasResp := 0, res.Code != nil
+	if hasResp {
+		code = *res.Code
+	}
 
 	dur := int32(time.Since(start).Milliseconds())
-	newLog, err := s.q.InsertJobLog(ctx, db.InsertJobLogParams{
+	metrics.JobRuns.WithLabelValues(job.ID.String(), status).Inc()
+	metrics.JobRunDuration.WithLabelValues(status).Observe(float64(dur))
+	span.SetAttributes(attribute.String("cronix.run.status", status))
+	if hasResp && typ == JobTypeHTTP {
+		span.SetAttributes(attribute.Int("http.response.status_code", code))
+	} else if hasResp {
+		span.SetAttributes(attribute.Int("cronix.run.response_code", code))
+	}
+	if errStr != "" {
+		span.SetStatus(codes.Error, errStr)
+	}
+
+	// ctx may already be cancelled by the run timeout or a shutdown; the log
+	// row must be written regardless
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	newLog, err := s.q.InsertJobLog(storeCtx, db.InsertJobLogParams{
 		JobID:        job.ID,
 		StartedAt:    pgtype.Timestamptz{Time: start, Valid: true},
 		FinishedAt:   pgtype.Timestamptz{Time: time.Now(), Valid: true},
@@ -115,15 +447,35 @@ func (s *JobsService) RunOnce(ctx context.Context, job db.Job) (db.JobLog, error
 		ResponseCode: pgtype.Int4{Int32: int32(code), Valid: hasResp},
 		Error:        pgtype.Text{String: errStr, Valid: errStr != ""},
 		ResponseBody: pgtype.Text{String: respBodyStr, Valid: respBodyStr != ""},
+		DnsMs:        durationMs(res.DNS),
+		ConnectMs:    durationMs(res.Connect),
+		TlsMs:        durationMs(res.TLS),
+		TtfbMs:       durationMs(res.TTFB),
+		DownloadMs:   durationMs(res.Download),
+		Redirects:    encodeChain(res.Redirects),
+		RunGroup:     pgtype.Text{String: group, Valid: true},
 	})
 
+	level := slog.LevelInfo
+	if status != "success" {
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "job run finished",
+		"job_id", job.ID.String(),
+		"status", status,
+		"response_code", code,
+		"duration_ms", dur,
+		"error", errStr,
+	)
+
 	if err != nil {
+		span.RecordError(err)
 		return newLog, err
 	}
 
-	// Clean up old logs, keeping only the 5 most recent
+	// Clean up old logs, keeping only the most recent ones
 	// We ignore errors here as cleanup is not critical
-	_ = s.CleanupOldLogs(ctx, job.ID)
+	_ = s.CleanupOldLogs(storeCtx, job.ID)
 
 	return newLog, nil
 }
@@ -137,15 +489,15 @@ func (s *JobsService) ListLogs(ctx context.Context, jobID pgtype.UUID, limit, of
 }
 
 func (s *JobsService) ListRecentLogs(ctx context.Context, jobID pgtype.UUID) ([]db.JobLog, error) {
-	return s.q.ListRecentJobLogs(ctx, jobID)
+	return s.q.ListRecentJobLogs(ctx, db.ListRecentJobLogsParams{JobID: jobID, Limit: s.settings.LogsPerJob})
 }
 
 func (s *JobsService) CleanupOldLogs(ctx context.Context, jobID pgtype.UUID) error {
-	return s.q.DeleteOldJobLogs(ctx, jobID)
+	return s.q.DeleteOldJobLogs(ctx, db.DeleteOldJobLogsParams{JobID: jobID, Keep: s.settings.LogsPerJob})
 }
 
 func (s *JobsService) CleanupAllOldLogs(ctx context.Context) error {
-	return s.q.CleanupAllOldLogs(ctx)
+	return s.q.CleanupAllOldLogs(ctx, s.settings.LogsPerJob)
 }
 
 func toTextPtr(s *string) pgtype.Text {
@@ -173,13 +525,6 @@ func getStr(s *string) string {
 	return *s
 }
 
-func getBool(b *bool) bool {
-	if b == nil {
-		return false
-	}
-	return *b
-}
-
 func (s *JobsService) ListByUser(ctx context.Context, userID pgtype.UUID, limit, offset int32) ([]db.Job, error) {
 	return s.q.ListJobsByUser(ctx, db.ListJobsByUserParams{
 		UserID: userID,
@@ -188,8 +533,23 @@ func (s *JobsService) ListByUser(ctx context.Context, userID pgtype.UUID, limit,
 	})
 }
 
-// TestEndpoint tests an endpoint before creating a job
-func (s *JobsService) TestEndpoint(ctx context.Context, endpoint, method string, headers map[string]string, body *string) error {
+// TestEndpoint tests an endpoint before creating a job. cert is the client
+// certificate of userID the job will present, if any, auth how it will
+// authenticate, signingSecret what it signs requests with, if anything,
+// and redirects how it follows redirects. A redirect the job would not
+// follow counts as success.
+func (s *JobsService) TestEndpoint(ctx context.Context, userID pgtype.UUID, endpoint, method string, headers map[string]string, body *string, transport outbound.Overrides, cert pgtype.UUID, auth jobauth.Config, signingSecret string, redirects outbound.Redirects) error {
+	if p := auth.Problems(); len(p) > 0 {
+		return ValidationError("invalid auth settings", p)
+	}
+	if p := redirects.Problems(); len(p) > 0 {
+		return ValidationError("invalid redirect settings", p)
+	}
+	client, err := s.TestClient(ctx, userID, transport, cert)
+	if err != nil {
+		return err
+	}
+
 	// Validate method first
 	validMethods := []string{"GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS"}
 	isValidMethod := false
@@ -200,12 +560,12 @@ func (s *JobsService) TestEndpoint(ctx context.Context, endpoint, method string,
 		}
 	}
 	if !isValidMethod {
-		return fmt.Errorf("invalid HTTP method '%s'. Supported methods: GET, POST, PUT, DELETE, PATCH, HEAD, OPTIONS", method)
+		return ValidationError(fmt.Sprintf("invalid HTTP method '%s'. Supported methods: GET, POST, PUT, DELETE, PATCH, HEAD, OPTIONS", method), nil)
 	}
 
 	// Validate endpoint URL
 	if endpoint == "" {
-		return fmt.Errorf("endpoint URL is required")
+		return ValidationError("endpoint URL is required", nil)
 	}
 
 	// Build request
@@ -218,9 +578,9 @@ func (s *JobsService) TestEndpoint(ctx context.Context, endpoint, method string,
 	if err != nil {
 		// Check for specific URL parsing errors
 		if strings.Contains(err.Error(), "invalid URL") {
-			return fmt.Errorf("invalid endpoint URL format: %s. Please ensure the URL starts with http:// or https://", endpoint)
+			return ValidationError(fmt.Sprintf("invalid endpoint URL format: %s. Please ensure the URL starts with http:// or https://", endpoint), nil)
 		}
-		return fmt.Errorf("invalid endpoint URL: %v", err)
+		return ValidationError(fmt.Sprintf("invalid endpoint URL: %v", err), nil)
 	}
 
 	// Set headers
@@ -231,52 +591,74 @@ func (s *JobsService) TestEndpoint(ctx context.Context, endpoint, method string,
 		httpReq.Header.Set("Content-Type", "application/json")
 	}
 
+	if err := s.Authenticate(ctx, httpReq, client, auth); err != nil {
+		return err
+	}
+	sign(httpReq, signingSecret, []byte(getStr(body)))
+	s.FollowRedirects(client, redirects)
+
 	// Make request with timeout
-	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(httpReq)
+	s.Rejected(resp, auth)
+	err = auth.RedactError(err)
 	if err != nil {
+		if e := notAllowed(err); e != nil {
+			return e
+		}
 		// Check for specific connection errors
 		if strings.Contains(err.Error(), "no such host") {
-			return fmt.Errorf("endpoint host not found: %s. Please check if the domain name is correct", endpoint)
+			return upstreamf("endpoint host not found: %s. Please check if the domain name is correct", endpoint)
 		}
 		if strings.Contains(err.Error(), "connection refused") {
-			return fmt.Errorf("connection refused to endpoint: %s. The server may be down or not accepting connections", endpoint)
+			return upstreamf("connection refused to endpoint: %s. The server may be down or not accepting connections", endpoint)
 		}
 		if strings.Contains(err.Error(), "timeout") {
-			return fmt.Errorf("request timeout to endpoint: %s. The server took too long to respond", endpoint)
+			return upstreamf("request timeout to endpoint: %s. The server took too long to respond", endpoint)
+		}
+		// The server refused the client certificate, or the lack of one
+		if strings.Contains(err.Error(), "remote error: tls: certificate required") || strings.Contains(err.Error(), "remote error: tls: bad certificate") {
+			return upstreamf("endpoint %s did not accept the client certificate. Please attach a certificate the server trusts", endpoint)
 		}
 		if strings.Contains(err.Error(), "certificate") {
-			return fmt.Errorf("SSL certificate error for endpoint: %s. Please check if the HTTPS certificate is valid", endpoint)
+			return upstreamf("SSL certificate error for endpoint: %s. Please check if the HTTPS certificate is valid", endpoint)
 		}
-		return fmt.Errorf("failed to reach endpoint: %v", err)
+		return upstreamf("failed to reach endpoint: %v", err)
 	}
 	defer resp.Body.Close()
 
 	// Check if response is successful (2xx status codes)
+	if !redirects.Following() && resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		return nil
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		switch resp.StatusCode {
 		case 400:
-			return fmt.Errorf("endpoint returned Bad Request (400): %s. Please check your request parameters and body", endpoint)
+			return upstreamf("endpoint returned Bad Request (400): %s. Please check your request parameters and body", endpoint)
 		case 401:
-			return fmt.Errorf("endpoint returned Unauthorized (401): %s. Please check your authentication credentials", endpoint)
+			return upstreamf("endpoint returned Unauthorized (401): %s. Please check your authentication credentials", endpoint)
 		case 403:
-			return fmt.Errorf("endpoint returned Forbidden (403): %s. You don't have permission to access this endpoint", endpoint)
+			return upstreamf("endpoint returned Forbidden (403): %s. You don't have permission to access this endpoint", endpoint)
 		case 404:
-			return fmt.Errorf("endpoint returned Not Found (404): %s. The endpoint path does not exist", endpoint)
+			return upstreamf("endpoint returned Not Found (404): %s. The endpoint path does not exist", endpoint)
 		case 405:
-			return fmt.Errorf("endpoint returned Method Not Allowed (405): %s. The HTTP method '%s' is not supported by this endpoint", endpoint, method)
+			return upstreamf("endpoint returned Method Not Allowed (405): %s. The HTTP method '%s' is not supported by this endpoint", endpoint, method)
 		case 500:
-			return fmt.Errorf("endpoint returned Internal Server Error (500): %s. The server encountered an error", endpoint)
+			return upstreamf("endpoint returned Internal Server Error (500): %s. The server encountered an error", endpoint)
 		case 502:
-			return fmt.Errorf("endpoint returned Bad Gateway (502): %s. The server is acting as a gateway and received an invalid response", endpoint)
+			return upstreamf("endpoint returned Bad Gateway (502): %s. The server is acting as a gateway and received an invalid response", endpoint)
 		case 503:
-			return fmt.Errorf("endpoint returned Service Unavailable (503): %s. The server is temporarily unavailable", endpoint)
+			return upstreamf("endpoint returned Service Unavailable (503): %s. The server is temporarily unavailable", endpoint)
 		case 504:
-			return fmt.Errorf("endpoint returned Gateway Timeout (504): %s. The server took too long to respond", endpoint)
+			return upstreamf("endpoint returned Gateway Timeout (504): %s. The server took too long to respond", endpoint)
 		default:
-			return fmt.Errorf("endpoint returned error status %d (%s): %s", resp.StatusCode, resp.Status, endpoint)
+			return upstreamf("endpoint returned error status %d (%s): %s", resp.StatusCode, resp.Status, endpoint)
 		}
 	}
 
 	return nil
 }
+
+// upstreamf reports a failure of the target endpoint itself.
+func upstreamf(format string, args ...any) *Error {
+	return &Error{Kind: ErrUpstream, Code: "upstream_error", Message: fmt.Sprintf(format, args...)}
+}
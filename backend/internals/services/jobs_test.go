@@ -0,0 +1,273 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/executor"
+	"cronix.ashutosh.net/internals/jobauth"
+	"cronix.ashutosh.net/internals/netguard"
+	"cronix.ashutosh.net/internals/outbound"
+	"cronix.ashutosh.net/internals/services"
+)
+
+func TestRunOnce(t *testing.T) {
+	e := newEnv(t, services.JobsSettings{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte("hello from " + r.URL.Path))
+	}))
+	defer target.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		status   string
+		code     int32 // 0 for no response
+		body     string
+		err      string
+	}{
+		{"ok", target.URL + "/ok", "success", 200, "hello from /ok", ""},
+		{"any status passes", target.URL + "/broken", "success", 500, "hello from /broken", ""},
+		{"unreachable", closed.URL, "failure", 0, "", "connection refused"},
+		{"internal address", "http://10.1.2.3/", "failure", 0, "", "endpoint not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := e.job(t, tt.name, tt.endpoint)
+			log, err := e.js.RunOnce(context.Background(), job)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if log.Status != tt.status {
+				t.Errorf("status = %q, want %q (error %q)", log.Status, tt.status, log.Error.String)
+			}
+			if log.ResponseCode.Valid != (tt.code != 0) || log.ResponseCode.Int32 != tt.code {
+				t.Errorf("response code = %+v, want %d", log.ResponseCode, tt.code)
+			}
+			if log.ResponseBody.String != tt.body {
+				t.Errorf("body = %q, want %q", log.ResponseBody.String, tt.body)
+			}
+			if !strings.Contains(log.Error.String, tt.err) || log.Error.Valid != (tt.err != "") {
+				t.Errorf("error = %q, want it to contain %q", log.Error.String, tt.err)
+			}
+			if !log.RunGroup.Valid || !log.DurationMs.Valid || !log.FinishedAt.Valid {
+				t.Errorf("log is missing fields: %+v", log)
+			}
+			if got := e.logs(t, job); len(got) != 1 || got[0].ID != log.ID {
+				t.Errorf("stored logs = %d, want the run's log", len(got))
+			}
+		})
+	}
+}
+
+func TestRunOnceKeepsLatestLogs(t *testing.T) {
+	e := newEnv(t, services.JobsSettings{LogsPerJob: 3})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	job := e.job(t, "pruned", target.URL)
+
+	var last db.JobLog
+	for range 5 {
+		var err error
+		if last, err = e.js.RunOnce(context.Background(), job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logs := e.logs(t, job)
+	if len(logs) != 3 {
+		t.Fatalf("kept %d logs, want 3", len(logs))
+	}
+	if logs[0].ID != last.ID {
+		t.Error("the newest log was pruned")
+	}
+}
+
+func TestRunOnceAborted(t *testing.T) {
+	e := newEnv(t, services.JobsSettings{})
+	started := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer target.Close()
+	job := e.job(t, "slow", target.URL)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	go func() {
+		<-started
+		cancel(services.ErrAborted)
+	}()
+	log, err := e.js.RunOnce(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.Status != "aborted" {
+		t.Errorf("status = %q, want aborted", log.Status)
+	}
+}
+
+func TestRunOnceTimeout(t *testing.T) {
+	e := newEnv(t, services.JobsSettings{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer target.Close()
+	job := e.job(t, "slow", target.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	log, err := e.js.RunOnce(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.Status != "failure" || !strings.Contains(log.Error.String, "deadline exceeded") {
+		t.Errorf("status = %q, error = %q; want a failure past the deadline", log.Status, log.Error.String)
+	}
+}
+
+// A dns job that resolves to an internal address neither passes nor
+// records the address, in a run or in the check made before saving it.
+func TestDNSJobHidesInternalAddresses(t *testing.T) {
+	guard, err := netguard.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob, err := outbound.New(outbound.Options{}, guard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newEnv(t, services.JobsSettings{Outbound: ob})
+	ctx := context.Background()
+
+	log, err := e.js.RunOnce(ctx, e.job(t, "internal", "localhost", func(p *db.CreateJobParams) {
+		p.Type = executor.DNS
+		p.Config = []byte(`{"record_type":"A"}`)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.Status != "failure" || !strings.Contains(log.Error.String, "endpoint not allowed") {
+		t.Errorf("run = %s %q, want the lookup refused", log.Status, log.Error.String)
+	}
+	if strings.Contains(log.Error.String+log.ResponseBody.String, "127.0.0.1") {
+		t.Errorf("run records the address: error %q, body %q", log.Error.String, log.ResponseBody.String)
+	}
+
+	err = e.js.TestCheck(ctx, executor.DNS, "localhost", nil)
+	var se *services.Error
+	if !errors.As(err, &se) || se.Code != "endpoint_not_allowed" || strings.Contains(err.Error(), "127.0.0.1") {
+		t.Errorf("TestCheck = %v, want endpoint_not_allowed without the address", err)
+	}
+}
+
+// countingRepo counts the loads of one job.
+type countingRepo struct {
+	services.Repository
+	id    pgtype.UUID
+	loads atomic.Int32
+}
+
+func (r *countingRepo) GetJob(ctx context.Context, id pgtype.UUID) (db.Job, error) {
+	if id == r.id {
+		r.loads.Add(1)
+	}
+	return r.Repository.GetJob(ctx, id)
+}
+
+func TestUpdateLoadsJobOnce(t *testing.T) {
+	e := newEnv(t, services.JobsSettings{})
+	job := e.job(t, "edited", "http://127.0.0.1/")
+	other := e.job(t, "other", "http://127.0.0.1/other")
+	p := newPKI(t)
+	cert, err := e.certs.Create(context.Background(), e.user.ID, "identity", p.clientCertPEM, p.clientKeyPEM, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &countingRepo{Repository: e.st, id: job.ID}
+	js := services.NewJobsService(repo, services.JobsSettings{Certificates: e.certs})
+
+	endpoint := "http://127.0.0.1/new"
+	triggers := &services.Triggers{OnFailure: []string{other.ID.String()}}
+	updated, err := js.Update(context.Background(), job.ID, nil, nil, &endpoint, nil, nil, nil, nil, nil, &cert.ID, &jobauth.Config{}, nil, nil, nil, triggers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Endpoint != endpoint || updated.ClientCertificateID != cert.ID || services.ParseTriggers(updated.Triggers).OnFailure[0] != other.ID.String() {
+		t.Errorf("updated job = %+v", updated)
+	}
+	if n := repo.loads.Load(); n != 1 {
+		t.Errorf("Update loaded the job %d times, want once", n)
+	}
+}
+
+// runSeries counts the job_runs_total series of job.
+func runSeries(t *testing.T, job db.Job) int {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, f := range families {
+		if f.GetName() != "cronix_job_runs_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "job_id" && l.GetValue() == job.ID.String() {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+func TestDeleteDropsRunMetrics(t *testing.T) {
+	e := newEnv(t, services.JobsSettings{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	ctx := context.Background()
+
+	job := e.job(t, "deleted", target.URL)
+	kept := e.job(t, "kept", target.URL)
+	for _, j := range []db.Job{job, kept} {
+		if _, err := e.js.RunOnce(ctx, j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	job.Endpoint = closed.URL
+	if _, err := e.js.RunOnce(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if n := runSeries(t, job); n != 2 {
+		t.Fatalf("job has %d run series before deleting, want success and failure", n)
+	}
+
+	if err := e.js.Delete(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := runSeries(t, job); n != 0 {
+		t.Errorf("deleted job has %d run series", n)
+	}
+	if n := runSeries(t, kept); n != 1 {
+		t.Errorf("other job has %d run series, want 1", n)
+	}
+}
@@ -0,0 +1,744 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/jobauth"
+	"cronix.ashutosh.net/internals/outbound"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	ManifestAPIVersion = "cronix/v1"
+	ManifestKind       = "JobList"
+)
+
+// Manifest is the declarative description of all of a user's jobs.
+// Jobs are keyed by name, so names must be unique within a manifest.
+type Manifest struct {
+	APIVersion string    `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string    `json:"kind" yaml:"kind"`
+	Jobs       []JobSpec `json:"jobs" yaml:"jobs"`
+	// Secrets supplies values for the references in JobSpec.SecretHeaders
+	// and the auth secrets of jobs, referenced as "<job>/auth". It is
+	// accepted on apply but never produced by export.
+	Secrets map[string]string `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+}
+
+type JobSpec struct {
+	Name     string `json:"name" yaml:"name"`
+	Schedule string `json:"schedule" yaml:"schedule"`
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	Method   string `json:"method,omitempty" yaml:"method,omitempty"`
+	// Type is the executor that runs the job; http if empty. Config holds
+	// the settings of the other types.
+	Type   string         `json:"type,omitempty" yaml:"type,omitempty"`
+	Config map[string]any `json:"config,omitempty" yaml:"config,omitempty"`
+
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// SecretHeaders maps a header name to a secret reference instead of
+	// carrying its value. See resolveHeaders for how references are resolved.
+	SecretHeaders map[string]string   `json:"secret_headers,omitempty" yaml:"secret_headers,omitempty"`
+	Body          *string             `json:"body,omitempty" yaml:"body,omitempty"`
+	Active        *bool               `json:"active,omitempty" yaml:"active,omitempty"`
+	Transport     *outbound.Overrides `json:"transport,omitempty" yaml:"transport,omitempty"`
+	// ClientCertificate names one of the user's client certificates, which
+	// must already be uploaded; certificates are not part of manifests.
+	ClientCertificate string `json:"client_certificate,omitempty" yaml:"client_certificate,omitempty"`
+	// Auth is exported without its secret. See specAuth for how the secret
+	// is resolved on apply.
+	Auth      *jobauth.Config     `json:"auth,omitempty" yaml:"auth,omitempty"`
+	Redirects *outbound.Redirects `json:"redirects,omitempty" yaml:"redirects,omitempty"`
+	// Triggers names other jobs of the manifest rather than giving ids.
+	Triggers *Triggers `json:"triggers,omitempty" yaml:"triggers,omitempty"`
+}
+
+type PlanAction string
+
+const (
+	PlanCreate    PlanAction = "create"
+	PlanUpdate    PlanAction = "update"
+	PlanDelete    PlanAction = "delete"
+	PlanUnchanged PlanAction = "unchanged"
+)
+
+type PlanOperation struct {
+	Action  PlanAction `json:"action"`
+	Name    string     `json:"name"`
+	JobID   string     `json:"job_id,omitempty"`
+	Changes []string   `json:"changes,omitempty"`
+}
+
+type ApplyResult struct {
+	DryRun     bool            `json:"dry_run"`
+	Operations []PlanOperation `json:"operations"`
+	Summary    map[string]int  `json:"summary"`
+
+	// Saved holds the jobs created or updated by a real apply and Deleted
+	// the ids removed, so callers can bring the scheduler in line.
+	Saved   []db.Job      `json:"-"`
+	Deleted []pgtype.UUID `json:"-"`
+}
+
+// ExportManifest returns all jobs owned by userID. Header values that look
+// like credentials are replaced by references of the form "<job>/<header>",
+// and auth secrets are left out.
+func (s *JobsService) ExportManifest(ctx context.Context, userID pgtype.UUID) (Manifest, error) {
+	jobs, err := s.q.ListAllJobsByUser(ctx, userID)
+	if err != nil {
+		return Manifest{}, err
+	}
+	certs, err := s.q.ListClientCertificatesByUser(ctx, userID)
+	if err != nil {
+		return Manifest{}, err
+	}
+	certNames := make(map[pgtype.UUID]string, len(certs))
+	for _, c := range certs {
+		certNames[c.ID] = c.Name
+	}
+
+	jobNames := make(map[string]string, len(jobs))
+	for _, j := range jobs {
+		jobNames[j.ID.String()] = j.Name
+	}
+
+	m := Manifest{APIVersion: ManifestAPIVersion, Kind: ManifestKind, Jobs: []JobSpec{}}
+	for _, j := range jobs {
+		active := j.Active
+		spec := JobSpec{
+			Name:     j.Name,
+			Schedule: j.Schedule,
+			Endpoint: j.Endpoint,
+			Method:   j.Method,
+			Active:   &active,
+		}
+		for k, v := range decodeHeaders(j.Headers) {
+			if isSecretHeader(k) {
+				if spec.SecretHeaders == nil {
+					spec.SecretHeaders = map[string]string{}
+				}
+				spec.SecretHeaders[k] = secretRef(j.Name, k)
+				continue
+			}
+			if spec.Headers == nil {
+				spec.Headers = map[string]string{}
+			}
+			spec.Headers[k] = v
+		}
+		if j.Body.Valid && j.Body.String != "" {
+			b := j.Body.String
+			spec.Body = &b
+		}
+		if tr := outbound.ParseOverrides(j.Transport); !tr.IsZero() {
+			spec.Transport = &tr
+		}
+		spec.ClientCertificate = certNames[j.ClientCertificateID]
+		if auth := jobauth.Parse(j.Auth); !auth.IsZero() {
+			spec.Auth = &auth
+		}
+		if r := outbound.ParseRedirects(j.Redirects); !r.IsZero() {
+			spec.Redirects = &r
+		}
+		if t := ParseTriggers(j.Triggers); !t.IsZero() {
+			t = t.rename(func(ref string) string { return jobNames[ref] })
+			spec.Triggers = &t
+		}
+		if j.Type != JobTypeHTTP {
+			spec.Type = j.Type
+		}
+		_ = json.Unmarshal(j.Config, &spec.Config)
+		if len(spec.Config) == 0 {
+			spec.Config = nil
+		}
+		m.Jobs = append(m.Jobs, spec)
+	}
+	return m, nil
+}
+
+// ApplyManifest brings userID's jobs in line with m: jobs missing from the
+// database are created, differing ones updated and jobs absent from the
+// manifest deleted. With dryRun set only the plan is computed.
+//
+// Jobs to be created have their endpoint tested first, as POST /api/jobs
+// does, except on a dry run, which sends nothing. The plan is made from
+// the jobs as they are inside the transaction that carries it out.
+func (s *JobsService) ApplyManifest(ctx context.Context, userID pgtype.UUID, m Manifest, dryRun bool) (*ApplyResult, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	tested := map[string]bool{}
+	if !dryRun {
+		st, err := s.manifestState(ctx, s.q, userID)
+		if err != nil {
+			return nil, err
+		}
+		var problems []string
+		for _, spec := range m.Jobs {
+			if _, ok := st.byName[spec.Name]; ok {
+				continue
+			}
+			w, p := s.desire(st, spec, m.Secrets)
+			if len(p) == 0 {
+				p = s.testNewJob(ctx, userID, w)
+			}
+			problems = append(problems, p...)
+			tested[spec.Name] = true
+		}
+		if len(problems) > 0 {
+			return nil, ValidationError("invalid manifest", problems)
+		}
+	}
+
+	var res *ApplyResult
+	apply := func(q Repository) error {
+		st, err := s.manifestState(ctx, q, userID)
+		if err != nil {
+			return err
+		}
+		byName, jobNames := st.byName, st.jobNames
+		problems := st.problems
+		wanted := make([]desired, 0, len(m.Jobs))
+		for _, spec := range m.Jobs {
+			w, p := s.desire(st, spec, m.Secrets)
+			if len(p) > 0 {
+				problems = append(problems, p...)
+				continue
+			}
+			if _, ok := byName[spec.Name]; !ok && !dryRun && !tested[spec.Name] {
+				return ConflictError(fmt.Sprintf("job %q was deleted while the manifest was applied; apply it again", spec.Name))
+			}
+			wanted = append(wanted, w)
+		}
+		if len(problems) > 0 {
+			return ValidationError("invalid manifest", problems)
+		}
+
+		res = &ApplyResult{DryRun: dryRun, Operations: []PlanOperation{}, Summary: map[string]int{}}
+		seen := make(map[string]bool, len(wanted))
+		// Triggers refer to jobs that may only exist once the others are
+		// created, so they are set in a second pass over these jobs
+		var retrigger []desired
+		ids := make(map[string]pgtype.UUID, len(wanted))
+		for name, j := range byName {
+			ids[name] = j.ID
+		}
+		for _, w := range wanted {
+			seen[w.spec.Name] = true
+			active := w.spec.Active == nil || *w.spec.Active
+			method := strings.ToUpper(w.spec.Method)
+			hdr, _ := json.Marshal(w.headers)
+			var transport outbound.Overrides
+			if w.spec.Transport != nil {
+				transport = *w.spec.Transport
+			}
+			var redirects outbound.Redirects
+			if w.spec.Redirects != nil {
+				redirects = *w.spec.Redirects
+			}
+			authJSON, authSecret, err := s.sealAuth(userID, w.auth)
+			if err != nil {
+				return err
+			}
+
+			cur, ok := byName[w.spec.Name]
+			if !ok {
+				op := PlanOperation{Action: PlanCreate, Name: w.spec.Name}
+				if !dryRun {
+					job, err := q.CreateJob(ctx, db.CreateJobParams{
+						UserID:    userID,
+						Name:      w.spec.Name,
+						Schedule:  w.spec.Schedule,
+						Endpoint:  w.spec.Endpoint,
+						Method:    method,
+						Headers:   hdr,
+						Body:      toTextPtr(w.spec.Body),
+						Active:    active,
+						Transport: transport.Encode(),
+
+						ClientCertificateID: w.cert,
+						Auth:                authJSON,
+						AuthSecret:          authSecret,
+						Redirects:           redirects.Encode(),
+						Type:                w.typ,
+						Config:              w.config,
+					})
+					if err != nil {
+						return fmt.Errorf("create job %q: %w", w.spec.Name, err)
+					}
+					op.JobID = job.ID.String()
+					ids[w.spec.Name] = job.ID
+					res.Saved = append(res.Saved, job)
+				}
+				if w.spec.Triggers != nil && !w.spec.Triggers.IsZero() {
+					retrigger = append(retrigger, w)
+				}
+				res.add(op)
+				continue
+			}
+
+			changes := diffJob(cur, w.spec.Schedule, w.spec.Endpoint, method, w.headers, getStr(w.spec.Body), active, transport, w.cert, w.curAuth, w.auth, redirects, w.typ, w.config)
+			var triggers Triggers
+			if w.spec.Triggers != nil {
+				triggers = *w.spec.Triggers
+			}
+			curTriggers := ParseTriggers(cur.Triggers).rename(func(ref string) string {
+				if name, ok := jobNames[ref]; ok {
+					return name
+				}
+				return ref
+			})
+			if string(curTriggers.Encode()) != string(triggers.Encode()) {
+				changes = append(changes, "triggers")
+				retrigger = append(retrigger, w)
+			}
+			op := PlanOperation{Action: PlanUnchanged, Name: w.spec.Name, JobID: cur.ID.String(), Changes: changes}
+			if len(changes) > 0 {
+				op.Action = PlanUpdate
+				if !dryRun {
+					body := getStr(w.spec.Body)
+					job, err := q.UpdateJob(ctx, db.UpdateJobParams{
+						ID:        cur.ID,
+						Column2:   w.spec.Name,
+						Column3:   w.spec.Schedule,
+						Column4:   w.spec.Endpoint,
+						Column5:   method,
+						Headers:   hdr,
+						Body:      pgtype.Text{String: body, Valid: true},
+						Active:    pgtype.Bool{Bool: active, Valid: true},
+						Transport: transport.Encode(),
+
+						SetClientCertificate: true,
+						ClientCertificateID:  w.cert,
+						Auth:                 authJSON,
+						AuthSecret:           authSecret,
+						Redirects:            redirects.Encode(),
+						Type:                 w.typ,
+						Config:               w.config,
+					})
+					if err != nil {
+						return fmt.Errorf("update job %q: %w", w.spec.Name, err)
+					}
+					res.Saved = append(res.Saved, job)
+				}
+			}
+			res.add(op)
+		}
+
+		if !dryRun {
+			saved := make(map[pgtype.UUID]int, len(res.Saved))
+			for i, j := range res.Saved {
+				saved[j.ID] = i
+			}
+			for _, w := range retrigger {
+				var triggers Triggers
+				if w.spec.Triggers != nil {
+					triggers = w.spec.Triggers.rename(func(name string) string { return ids[name].String() })
+				}
+				job, err := q.SetJobTriggers(ctx, db.SetJobTriggersParams{ID: ids[w.spec.Name], Triggers: triggers.Encode()})
+				if err != nil {
+					return fmt.Errorf("set triggers of job %q: %w", w.spec.Name, err)
+				}
+				if i, ok := saved[job.ID]; ok {
+					res.Saved[i] = job
+				} else {
+					res.Saved = append(res.Saved, job)
+				}
+			}
+		}
+
+		for _, j := range st.existing {
+			if seen[j.Name] {
+				continue
+			}
+			if !dryRun {
+				if err := q.DeleteJob(ctx, j.ID); err != nil {
+					return fmt.Errorf("delete job %q: %w", j.Name, err)
+				}
+				res.Deleted = append(res.Deleted, j.ID)
+			}
+			res.add(PlanOperation{Action: PlanDelete, Name: j.Name, JobID: j.ID.String()})
+		}
+		return nil
+	}
+	var err error
+	if dryRun {
+		err = apply(s.q)
+	} else {
+		// One transaction, so that a failure leaves every job as it was
+		err = inTx(ctx, s.q, apply)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range res.Deleted {
+		forgetRuns(id)
+	}
+
+	sort.SliceStable(res.Operations, func(a, b int) bool {
+		return res.Operations[a].Name < res.Operations[b].Name
+	})
+	return res, nil
+}
+
+// manifestState is what a manifest is applied against: the user's jobs by
+// name and their certificates by name.
+type manifestState struct {
+	existing []db.Job
+	byName   map[string]db.Job
+	jobNames map[string]string // by job id
+	certIDs  map[string]pgtype.UUID
+	problems []string
+}
+
+func (s *JobsService) manifestState(ctx context.Context, q Repository, userID pgtype.UUID) (manifestState, error) {
+	existing, err := q.ListAllJobsByUser(ctx, userID)
+	if err != nil {
+		return manifestState{}, err
+	}
+	certs, err := q.ListClientCertificatesByUser(ctx, userID)
+	if err != nil {
+		return manifestState{}, err
+	}
+	st := manifestState{
+		existing: existing,
+		byName:   make(map[string]db.Job, len(existing)),
+		jobNames: make(map[string]string, len(existing)),
+		certIDs:  make(map[string]pgtype.UUID, len(certs)),
+	}
+	for _, c := range certs {
+		st.certIDs[c.Name] = c.ID
+	}
+	for _, j := range existing {
+		if _, dup := st.byName[j.Name]; dup {
+			st.problems = append(st.problems, fmt.Sprintf("job name %q is used by more than one existing job; rename one before applying", j.Name))
+			continue
+		}
+		st.byName[j.Name] = j
+		st.jobNames[j.ID.String()] = j.Name
+	}
+	return st, nil
+}
+
+// desired is a job of a manifest resolved against the database.
+type desired struct {
+	spec    JobSpec
+	headers map[string]string
+	cert    pgtype.UUID
+	auth    jobauth.Config
+	curAuth jobauth.Config
+	typ     string
+	config  []byte
+}
+
+// desire resolves spec against st, filling in the secrets and certificate
+// it refers to, or lists what is wrong with it.
+func (s *JobsService) desire(st manifestState, spec JobSpec, secrets map[string]string) (desired, []string) {
+	var current map[string]string
+	var curAuth jobauth.Config
+	if j, ok := st.byName[spec.Name]; ok {
+		current = decodeHeaders(j.Headers)
+		var err error
+		if curAuth, err = s.openAuth(j); err != nil {
+			return desired{}, []string{fmt.Sprintf("job %q: %v", spec.Name, err)}
+		}
+	}
+	hdr, err := resolveHeaders(spec, secrets, current)
+	if err != nil {
+		return desired{}, []string{err.Error()}
+	}
+	typ, config := spec.Type, specConfig(spec)
+	if typ == "" {
+		typ = JobTypeHTTP
+	}
+	if err := s.ValidateJobType(typ, spec.Endpoint, config); err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			err = fmt.Errorf("%s: %v", e.Message, e.Details)
+		}
+		return desired{}, []string{fmt.Sprintf("job %q: %v", spec.Name, err)}
+	}
+	auth, authProblems := specAuth(spec, secrets, curAuth)
+	if len(authProblems) > 0 {
+		return desired{}, authProblems
+	}
+	var cert pgtype.UUID
+	if spec.ClientCertificate != "" {
+		id, ok := st.certIDs[spec.ClientCertificate]
+		if !ok {
+			return desired{}, []string{fmt.Sprintf("job %q: client certificate %q does not exist; upload it first", spec.Name, spec.ClientCertificate)}
+		}
+		cert = id
+	}
+	return desired{spec: spec, headers: hdr, cert: cert, auth: auth, curAuth: curAuth, typ: typ, config: config}, nil
+}
+
+// testNewJob tests the endpoint of a job about to be created the way
+// POST /api/jobs does.
+func (s *JobsService) testNewJob(ctx context.Context, userID pgtype.UUID, w desired) []string {
+	var err error
+	if s.IsCheck(w.typ) {
+		err = s.TestCheck(ctx, w.typ, w.spec.Endpoint, w.config)
+	} else {
+		var transport outbound.Overrides
+		if w.spec.Transport != nil {
+			transport = *w.spec.Transport
+		}
+		var redirects outbound.Redirects
+		if w.spec.Redirects != nil {
+			redirects = *w.spec.Redirects
+		}
+		err = s.TestEndpoint(ctx, userID, w.spec.Endpoint, strings.ToUpper(w.spec.Method), w.headers, w.spec.Body, transport, w.cert, w.auth, "", redirects)
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("job %q: endpoint test failed: %v", w.spec.Name, err)}
+	}
+	return nil
+}
+
+func (r *ApplyResult) add(op PlanOperation) {
+	r.Operations = append(r.Operations, op)
+	r.Summary[string(op.Action)]++
+}
+
+// Validate checks the manifest without looking at the database.
+func (m Manifest) Validate() error {
+	var problems []string
+	if m.APIVersion != "" && m.APIVersion != ManifestAPIVersion {
+		problems = append(problems, fmt.Sprintf("unsupported apiVersion %q (expected %q)", m.APIVersion, ManifestAPIVersion))
+	}
+	if m.Kind != "" && m.Kind != ManifestKind {
+		problems = append(problems, fmt.Sprintf("unsupported kind %q (expected %q)", m.Kind, ManifestKind))
+	}
+
+	names := make(map[string]bool, len(m.Jobs))
+	for i, j := range m.Jobs {
+		label := fmt.Sprintf("jobs[%d]", i)
+		if j.Name == "" {
+			problems = append(problems, label+": name is required")
+		} else {
+			label = fmt.Sprintf("job %q", j.Name)
+			if names[j.Name] {
+				problems = append(problems, label+": duplicate name")
+			}
+			names[j.Name] = true
+		}
+		if j.Endpoint == "" {
+			problems = append(problems, label+": endpoint is required")
+		}
+		if (j.Type == "" || j.Type == JobTypeHTTP) && !isValidMethod(strings.ToUpper(j.Method)) {
+			problems = append(problems, fmt.Sprintf("%s: invalid method %q", label, j.Method))
+		}
+		if _, err := cronParser.Parse(j.Schedule); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid schedule %q: %v", label, j.Schedule, err))
+		}
+		if j.Transport != nil {
+			for _, p := range j.Transport.Problems() {
+				problems = append(problems, label+": "+p)
+			}
+		}
+		if j.Redirects != nil {
+			for _, p := range j.Redirects.Problems() {
+				problems = append(problems, label+": "+p)
+			}
+		}
+		for k := range j.SecretHeaders {
+			if _, ok := j.Headers[k]; ok {
+				problems = append(problems, fmt.Sprintf("%s: header %q is set in both headers and secret_headers", label, k))
+			}
+		}
+	}
+
+	triggers := make(map[string][]string, len(m.Jobs))
+	for _, j := range m.Jobs {
+		if j.Triggers == nil {
+			continue
+		}
+		label := fmt.Sprintf("job %q", j.Name)
+		for _, p := range j.Triggers.Problems() {
+			problems = append(problems, label+": "+p)
+		}
+		for _, name := range j.Triggers.all() {
+			switch {
+			case name == j.Name:
+				problems = append(problems, label+": a job cannot trigger itself")
+			case !names[name]:
+				problems = append(problems, fmt.Sprintf("%s: triggers job %q, which is not in the manifest", label, name))
+			}
+		}
+		triggers[j.Name] = slices.DeleteFunc(j.Triggers.all(), func(name string) bool { return name == j.Name })
+	}
+	for _, j := range m.Jobs {
+		if j.Triggers == nil {
+			continue
+		}
+		if cycle := triggerCycle(j.Name, func(name string) []string { return triggers[name] }); cycle != nil {
+			problems = append(problems, fmt.Sprintf("triggers form a cycle: %s", strings.Join(cycle, " -> ")))
+			break
+		}
+	}
+	if len(problems) > 0 {
+		return ValidationError("invalid manifest", problems)
+	}
+	return nil
+}
+
+// resolveHeaders merges the plain headers of spec with its secret headers.
+// A reference is looked up in secrets first; if it is not supplied, the value
+// currently stored on the job is kept, so applying an unmodified export is a
+// no-op without ever putting credentials in the manifest.
+func resolveHeaders(spec JobSpec, secrets, current map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(spec.Headers)+len(spec.SecretHeaders))
+	for k, v := range spec.Headers {
+		out[k] = v
+	}
+	for k, ref := range spec.SecretHeaders {
+		if v, ok := secrets[ref]; ok {
+			out[k] = v
+			continue
+		}
+		if v, ok := current[k]; ok {
+			out[k] = v
+			continue
+		}
+		return nil, fmt.Errorf("job %q: secret %q for header %q was not supplied and the job has no stored value", spec.Name, ref, k)
+	}
+	return out, nil
+}
+
+// specAuth returns the auth spec asks for. Its secret is looked up in
+// secrets under "<job>/auth"; if it is not supplied, the secret of the job's
+// current auth is kept as long as the type is unchanged.
+func specAuth(spec JobSpec, secrets map[string]string, current jobauth.Config) (jobauth.Config, []string) {
+	var auth jobauth.Config
+	if spec.Auth != nil {
+		auth = *spec.Auth
+	}
+	if v, ok := secrets[secretRef(spec.Name, "auth")]; ok {
+		auth = auth.WithSecret(v)
+	} else if auth.Secret() == "" && auth.Type == current.Type {
+		auth = auth.WithSecret(current.Secret())
+	}
+	var problems []string
+	for _, p := range auth.Problems() {
+		problems = append(problems, fmt.Sprintf("job %q: %s", spec.Name, p))
+	}
+	return auth, problems
+}
+
+func diffJob(cur db.Job, schedule, endpoint, method string, headers map[string]string, body string, active bool, transport outbound.Overrides, cert pgtype.UUID, curAuth, auth jobauth.Config, redirects outbound.Redirects, typ string, config []byte) []string {
+	var changes []string
+	if cur.Schedule != schedule {
+		changes = append(changes, "schedule")
+	}
+	if cur.Endpoint != endpoint {
+		changes = append(changes, "endpoint")
+	}
+	if cur.Method != method {
+		changes = append(changes, "method")
+	}
+	if !sameHeaders(decodeHeaders(cur.Headers), headers) {
+		changes = append(changes, "headers")
+	}
+	if cur.Body.String != body {
+		changes = append(changes, "body")
+	}
+	if cur.Active != active {
+		changes = append(changes, "active")
+	}
+	if string(outbound.ParseOverrides(cur.Transport).Encode()) != string(transport.Encode()) {
+		changes = append(changes, "transport")
+	}
+	if cur.ClientCertificateID != cert {
+		changes = append(changes, "client_certificate")
+	}
+	if string(curAuth.Encode()) != string(auth.Encode()) || curAuth.Secret() != auth.Secret() {
+		changes = append(changes, "auth")
+	}
+	if string(outbound.ParseRedirects(cur.Redirects).Encode()) != string(redirects.Encode()) {
+		changes = append(changes, "redirects")
+	}
+	if cur.Type != typ {
+		changes = append(changes, "type")
+	}
+	if !sameJSON(cur.Config, config) {
+		changes = append(changes, "config")
+	}
+	return changes
+}
+
+// specConfig returns spec's config as stored in jobs.config.
+func specConfig(spec JobSpec) []byte {
+	if len(spec.Config) == 0 {
+		return []byte("{}")
+	}
+	b, _ := json.Marshal(spec.Config)
+	return b
+}
+
+// sameJSON reports whether a and b encode the same value.
+func sameJSON(a, b []byte) bool {
+	var av, bv any
+	_ = json.Unmarshal(a, &av)
+	_ = json.Unmarshal(b, &bv)
+	return reflect.DeepEqual(av, bv)
+}
+
+func sameHeaders(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeHeaders(raw []byte) map[string]string {
+	hdr := map[string]string{}
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &hdr)
+	}
+	return hdr
+}
+
+func isSecretHeader(name string) bool {
+	n := strings.ToLower(name)
+	switch n {
+	case "authorization", "proxy-authorization", "cookie":
+		return true
+	}
+	for _, marker := range []string{"token", "secret", "api-key", "apikey", "password"} {
+		if strings.Contains(n, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func secretRef(jobName, header string) string {
+	return jobName + "/" + header
+}
+
+// cronParser parses schedules the way cron.WithSeconds does (six fields,
+// seconds first, plus descriptors such as @hourly).
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+func isValidMethod(method string) bool {
+	switch method {
+	case "GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,281 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/jobauth"
+	"cronix.ashutosh.net/internals/netguard"
+	"cronix.ashutosh.net/internals/outbound"
+	"cronix.ashutosh.net/internals/services"
+	"cronix.ashutosh.net/internals/store/memory"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// loopbackOutbound lets jobs call loopback addresses only.
+func loopbackOutbound(t *testing.T) *outbound.Factory {
+	t.Helper()
+	guard, err := netguard.New([]string{"127.0.0.0/8", "::1/128"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := outbound.New(outbound.Options{}, guard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// failingDeletes is a memory store whose deletes fail, in or out of a
+// transaction.
+type failingDeletes struct {
+	*memory.Store
+}
+
+func (f failingDeletes) DeleteJob(context.Context, pgtype.UUID) error {
+	return errors.New("disk full")
+}
+
+func (f failingDeletes) InTx(ctx context.Context, fn func(db.Querier) error) error {
+	return f.Store.InTx(ctx, func(q db.Querier) error {
+		return fn(failingDeletes{q.(*memory.Store)})
+	})
+}
+
+func TestApplyManifestRollsBackOnFailure(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New()
+	user, err := st.CreateUser(ctx, db.CreateUserParams{Email: "a@example.com", Provider: "google"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := st.CreateJob(ctx, db.CreateJobParams{
+		UserID: user.ID, Name: "old", Schedule: "@hourly", Endpoint: "https://example.com/old",
+		Method: "GET", Headers: []byte("{}"), Active: true, Type: services.JobTypeHTTP,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	js := services.NewJobsService(failingDeletes{st}, services.JobsSettings{Outbound: loopbackOutbound(t), TestTimeout: 5 * time.Second})
+
+	// Creates "new", updates nothing, then fails deleting "old"
+	m := services.Manifest{Jobs: []services.JobSpec{{
+		Name: "new", Schedule: "@daily", Endpoint: target.URL, Method: "POST",
+	}}}
+	if _, err := js.ApplyManifest(ctx, user.ID, m, false); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("apply = %v, want the delete to fail", err)
+	}
+
+	jobs, err := st.ListAllJobsByUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != old.ID {
+		names := make([]string, len(jobs))
+		for i, j := range jobs {
+			names[i] = j.Name
+		}
+		t.Fatalf("jobs after failed apply = %v, want only old", names)
+	}
+}
+
+// counter counts the requests it gets.
+func counter(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.Add(1)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, &n
+}
+
+func TestApplyManifestDryRun(t *testing.T) {
+	e := newEnv(t, services.JobsSettings{})
+	ctx := context.Background()
+	url, requests := counter(t)
+	e.job(t, "kept", url)
+	e.job(t, "gone", url)
+	before, err := e.st.ListAllJobsByUser(ctx, e.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := services.Manifest{Jobs: []services.JobSpec{
+		{Name: "kept", Schedule: "@hourly", Endpoint: url, Method: "POST"},
+		{Name: "new", Schedule: "@daily", Endpoint: url, Method: "GET"},
+	}}
+	res, err := e.js.ApplyManifest(ctx, e.user.ID, m, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"update": 1, "create": 1, "delete": 1}
+	if !res.DryRun || !reflect.DeepEqual(res.Summary, want) {
+		t.Errorf("plan = %+v, want %v", res, want)
+	}
+	if len(res.Saved) != 0 || len(res.Deleted) != 0 {
+		t.Errorf("dry run reports saved %d and deleted %d jobs", len(res.Saved), len(res.Deleted))
+	}
+
+	after, err := e.st.ListAllJobsByUser(ctx, e.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(before, after) {
+		t.Errorf("dry run changed the jobs: %+v", after)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("dry run sent %d requests", n)
+	}
+}
+
+func TestApplyManifestTestsNewEndpoints(t *testing.T) {
+	e := newEnv(t, services.JobsSettings{})
+	ctx := context.Background()
+	url, requests := counter(t)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		problem  string
+	}{
+		{"unreachable", closed.URL, "connection refused"},
+		{"internal", "http://10.1.2.3/", "endpoint not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := services.Manifest{Jobs: []services.JobSpec{{Name: tt.name, Schedule: "@daily", Endpoint: tt.endpoint, Method: "GET"}}}
+			_, err := e.js.ApplyManifest(ctx, e.user.ID, m, false)
+			if !errors.Is(err, services.ErrValidation) || !strings.Contains(err.Error(), tt.problem) {
+				t.Fatalf("apply = %v, want a validation error mentioning %q", err, tt.problem)
+			}
+			if jobs, _ := e.st.ListAllJobsByUser(ctx, e.user.ID); len(jobs) != 0 {
+				t.Errorf("stored %d jobs", len(jobs))
+			}
+		})
+	}
+
+	// Only jobs that are created are tested
+	e.job(t, "existing", url, func(p *db.CreateJobParams) { p.Active = true })
+	m := services.Manifest{Jobs: []services.JobSpec{
+		{Name: "existing", Schedule: "@daily", Endpoint: url, Method: "POST"},
+		{Name: "created", Schedule: "@daily", Endpoint: url, Method: "GET"},
+	}}
+	res, err := e.js.ApplyManifest(ctx, e.user.ID, m, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Summary["create"] != 1 || res.Summary["unchanged"] != 1 {
+		t.Errorf("summary = %v", res.Summary)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("sent %d test requests, want 1", n)
+	}
+}
+
+func TestExportManifestRoundTrip(t *testing.T) {
+	e := newAuthEnv(t)
+	ctx := context.Background()
+	url, _ := counter(t)
+	next := e.job(t, "next", url)
+	job := e.job(t, "first", url, func(p *db.CreateJobParams) {
+		p.Headers = []byte(`{"Authorization":"Bearer s3cret","X-Api-Key":"k3y","X-Team":"infra"}`)
+		p.Transport = outbound.Overrides{TLSMinVersion: "1.3"}.Encode()
+		p.Triggers = services.Triggers{OnSuccess: []string{next.ID.String()}}.Encode()
+	})
+	job = e.withAuth(t, job, jobauth.Config{Type: jobauth.Basic, Username: "ops", Password: "hunter2"})
+
+	m, err := e.js.ExportManifest(ctx, e.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec services.JobSpec
+	for _, s := range m.Jobs {
+		if s.Name == "first" {
+			spec = s
+		}
+	}
+	wantRefs := map[string]string{"Authorization": "first/Authorization", "X-Api-Key": "first/X-Api-Key"}
+	if !reflect.DeepEqual(spec.SecretHeaders, wantRefs) || !reflect.DeepEqual(spec.Headers, map[string]string{"X-Team": "infra"}) {
+		t.Errorf("headers = %v, secret headers = %v", spec.Headers, spec.SecretHeaders)
+	}
+	if spec.Auth == nil || spec.Auth.Username != "ops" || spec.Auth.Password != "" {
+		t.Errorf("auth = %+v, want it without the password", spec.Auth)
+	}
+	if len(m.Secrets) != 0 {
+		t.Errorf("export carries secrets %v", m.Secrets)
+	}
+
+	// Applying the export changes nothing and keeps the secrets
+	res, err := e.js.ApplyManifest(ctx, e.user.ID, m, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Summary, map[string]int{"unchanged": 2}) || len(res.Saved) != 0 || len(res.Deleted) != 0 {
+		t.Errorf("re-apply = %+v", res)
+	}
+	stored, err := e.js.Get(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stored, job) {
+		t.Errorf("re-apply changed the job:\n got %+v\nwant %+v", stored, job)
+	}
+	auth, err := e.js.JobAuth(stored, nil)
+	if err != nil || auth.Password != "hunter2" {
+		t.Errorf("auth after re-apply = %+v, %v", auth, err)
+	}
+}
+
+// txProbe is a memory store that records whether jobs were listed through
+// a transaction.
+type txProbe struct {
+	*memory.Store
+	tx       bool
+	listedTx *bool
+}
+
+func (p txProbe) ListAllJobsByUser(ctx context.Context, userID pgtype.UUID) ([]db.Job, error) {
+	if p.tx {
+		*p.listedTx = true
+	}
+	return p.Store.ListAllJobsByUser(ctx, userID)
+}
+
+func (p txProbe) InTx(ctx context.Context, fn func(db.Querier) error) error {
+	return p.Store.InTx(ctx, func(q db.Querier) error {
+		return fn(txProbe{Store: q.(*memory.Store), tx: true, listedTx: p.listedTx})
+	})
+}
+
+// The plan is made from the jobs as the transaction sees them, not from a
+// read before it began.
+func TestApplyManifestPlansInTransaction(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New()
+	user, err := st.CreateUser(ctx, db.CreateUserParams{Email: "a@example.com", Provider: "google"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listedTx bool
+	js := services.NewJobsService(txProbe{Store: st, listedTx: &listedTx}, services.JobsSettings{})
+
+	if _, err := js.ApplyManifest(ctx, user.ID, services.Manifest{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if !listedTx {
+		t.Error("jobs were not listed inside the transaction")
+	}
+}
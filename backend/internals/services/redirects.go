@@ -0,0 +1,39 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"cronix.ashutosh.net/internals/jobauth"
+	"cronix.ashutosh.net/internals/logging"
+	"cronix.ashutosh.net/internals/outbound"
+)
+
+// FollowRedirects makes client follow redirects as r says, and returns
+// where the redirects it follows are recorded. Call it after Authenticate
+// so a token request is not part of the chain.
+func (s *JobsService) FollowRedirects(client *http.Client, r outbound.Redirects) *[]outbound.Hop {
+	var hops []outbound.Hop
+	client.CheckRedirect = r.CheckRedirect(s.outbound.Guard(), &hops)
+	return &hops
+}
+
+// RedirectChain returns the redirect chain of a request that ended with
+// resp, with query secrets and auth's secret hidden in the URLs. It is nil
+// if the request was not redirected.
+func RedirectChain(followed []outbound.Hop, resp *http.Response, auth jobauth.Config) []outbound.Hop {
+	chain := outbound.Chain(followed, resp)
+	for i := range chain {
+		chain[i].URL = auth.Redact(logging.RedactURL(chain[i].URL))
+	}
+	return chain
+}
+
+// encodeChain returns chain as stored in job_logs.redirects.
+func encodeChain(chain []outbound.Hop) []byte {
+	if len(chain) == 0 {
+		return nil
+	}
+	b, _ := json.Marshal(chain)
+	return b
+}
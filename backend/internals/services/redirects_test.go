@@ -0,0 +1,119 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/outbound"
+	"cronix.ashutosh.net/internals/services"
+)
+
+// redirector serves /hop/<n> as a 302 to /hop/<n-1>, /hop/0 as 200 and
+// /away as a 302 to other.
+func redirector(t *testing.T, other string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hop/3":
+			http.Redirect(w, r, "/hop/2?token=s3cret", http.StatusFound)
+		case "/hop/2":
+			http.Redirect(w, r, "/hop/1", http.StatusMovedPermanently)
+		case "/hop/1":
+			http.Redirect(w, r, "/hop/0", http.StatusTemporaryRedirect)
+		case "/hop/0":
+			w.Write([]byte("landed"))
+		case "/away":
+			http.Redirect(w, r, other, http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func hops(t *testing.T, raw []byte) []outbound.Hop {
+	t.Helper()
+	if len(raw) == 0 {
+		return nil
+	}
+	var h []outbound.Hop
+	if err := json.Unmarshal(raw, &h); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestRedirects(t *testing.T) {
+	e := newEnv(t, services.JobsSettings{})
+	// Same server, other host name
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("elsewhere"))
+	}))
+	defer other.Close()
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	srv := redirector(t, otherURL+"/there")
+	no, yes := false, true
+	followed := []outbound.Hop{
+		{URL: srv.URL + "/hop/3", Status: 302},
+		{URL: srv.URL + "/hop/2?token=%5BREDACTED%5D", Status: 301},
+		{URL: srv.URL + "/hop/1", Status: 307},
+		{URL: srv.URL + "/hop/0", Status: 200},
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		redirects outbound.Redirects
+		status    string
+		code      int32
+		err       string
+		chain     []outbound.Hop
+	}{
+		{"followed", "/hop/3", outbound.Redirects{}, "success", 200, "", followed},
+		{"not redirected", "/hop/0", outbound.Redirects{}, "success", 200, "", nil},
+		{"within max_hops", "/hop/3", outbound.Redirects{MaxHops: 3}, "success", 200, "", followed},
+		// A refused redirect fails the run; the chain ends with it
+		{"beyond max_hops", "/hop/3", outbound.Redirects{MaxHops: 2}, "failure", 307, "stopped after 2 redirects", followed[:3]},
+		{"not followed", "/hop/3", outbound.Redirects{Follow: &no}, "success", 302, "", []outbound.Hop{
+			{URL: srv.URL + "/hop/3", Status: 302},
+		}},
+		{"cross host allowed", "/away", outbound.Redirects{AllowCrossHost: &yes}, "success", 200, "", []outbound.Hop{
+			{URL: srv.URL + "/away", Status: 302},
+			{URL: otherURL + "/there", Status: 200},
+		}},
+		{"cross host refused", "/away", outbound.Redirects{AllowCrossHost: &no}, "failure", 302, "redirect to another host is not allowed", []outbound.Hop{
+			{URL: srv.URL + "/away", Status: 302},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := e.job(t, tt.name, srv.URL+tt.path, func(p *db.CreateJobParams) {
+				p.Method = "GET"
+				p.Redirects = tt.redirects.Encode()
+			})
+			log, err := e.js.RunOnce(context.Background(), job)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if log.Status != tt.status || log.ResponseCode.Int32 != tt.code || !strings.Contains(log.Error.String, tt.err) {
+				t.Errorf("run = %s %d %q, want %s %d %q", log.Status, log.ResponseCode.Int32, log.Error.String, tt.status, tt.code, tt.err)
+			}
+			if got := hops(t, log.Redirects); !reflect.DeepEqual(got, tt.chain) {
+				t.Errorf("chain = %+v, want %+v", got, tt.chain)
+			}
+
+			// The log as stored carries the same chain
+			stored := e.logs(t, job)
+			if len(stored) != 1 || string(stored[0].Redirects) != string(log.Redirects) {
+				t.Errorf("stored logs = %+v", stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"context"
+
+	"cronix.ashutosh.net/internals/db"
+)
+
+// Repository is the storage the services depend on. It is the Querier
+// interface sqlc generates, so store/postgres.Store satisfies it for
+// Postgres, store/sqlite.Store for single-file deployments and
+// store/memory.Store provides an in-process implementation for tests.
+type Repository = db.Querier
+
+// Transactor is implemented by repositories that can make several writes
+// atomically. fn gets a repository bound to the transaction; an error from
+// it undoes everything fn wrote.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(db.Querier) error) error
+}
+
+// inTx runs fn in a transaction if the repository supports them and on the
+// repository itself otherwise.
+func inTx(ctx context.Context, q Repository, fn func(Repository) error) error {
+	if t, ok := q.(Transactor); ok {
+		return t.InTx(ctx, fn)
+	}
+	return fn(q)
+}
@@ -2,25 +2,51 @@ package services
 
 import (
 	"context"
-	"log"
-
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/logging"
+	"cronix.ashutosh.net/internals/metrics"
+	"cronix.ashutosh.net/internals/tracing"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// abortGrace is how long Stop waits, after cancelling runs that outlived
+// the drain period, for them to record their log rows.
+const abortGrace = 5 * time.Second
+
 type Scheduler struct {
-	c   *cron.Cron
-	js  *JobsService
-	ids map[string]cron.EntryID
+	c          *cron.Cron
+	js         *JobsService
+	runTimeout time.Duration
+	mu         sync.Mutex
+	ids        map[string]cron.EntryID
+
+	// runs tracks fires in progress; stopping rejects new ones (both under mu)
+	runs       sync.WaitGroup
+	started    bool
+	stopping   bool
+	runCtx     context.Context
+	cancelRuns context.CancelCauseFunc
 }
 
-func NewScheduler(js *JobsService) *Scheduler {
+// NewScheduler returns a scheduler whose fires each get runTimeout to finish.
+func NewScheduler(js *JobsService, runTimeout time.Duration) *Scheduler {
+	runCtx, cancel := context.WithCancelCause(context.Background())
 	return &Scheduler{
-		c:   cron.New(cron.WithSeconds()),
-		js:  js,
-		ids: make(map[string]cron.EntryID),
+		c:          cron.New(cron.WithSeconds()),
+		js:         js,
+		runTimeout: runTimeout,
+		ids:        make(map[string]cron.EntryID),
+		runCtx:     runCtx,
+		cancelRuns: cancel,
 	}
 }
 func (s *Scheduler) Start(ctx context.Context, jobs []db.Job) error {
@@ -31,43 +57,221 @@ func (s *Scheduler) Start(ctx context.Context, jobs []db.Job) error {
 
 	}
 	s.c.Start()
+	s.mu.Lock()
+	s.started = true
+	s.mu.Unlock()
 	return nil
 }
 
-func (s *Scheduler) Stop() { s.c.Stop() }
+// Stop stops firing jobs and waits for runs in flight until ctx is done.
+// Runs still going at that point are cancelled with ErrAborted, which
+// RunOnce records as "aborted", and ctx's error is returned.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopping = true
+	s.mu.Unlock()
+
+	// Fires only spawn a goroutine, so cron's own jobs finish at once
+	select {
+	case <-s.c.Stop().Done():
+	case <-ctx.Done():
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.cancelRuns(ErrAborted)
+	select {
+	case <-done:
+	case <-time.After(abortGrace):
+		slog.Warn("scheduled runs did not finish after being aborted")
+	}
+	return ctx.Err()
+}
+
+// begin registers a run unless the scheduler is stopping.
+func (s *Scheduler) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopping {
+		return false
+	}
+	s.runs.Add(1)
+	return true
+}
 
 func (s *Scheduler) AddJob(job db.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// If job already scheduled, remove and replace
 	if entry, ok := s.ids[job.ID.String()]; ok {
 		s.c.Remove(entry)
 		delete(s.ids, job.ID.String())
 	}
 
+	sched, err := cronParser.Parse(job.Schedule)
+	if err != nil {
+		return err
+	}
+	// Track the planned fire time ourselves to measure scheduling lag
+	var planned atomic.Int64
+	planned.Store(sched.Next(time.Now()).UnixNano())
+
 	// Register the cron task; run each fire in its own goroutine with timeout
-	id, err := s.c.AddFunc(job.Schedule, func() {
+	id := s.c.Schedule(sched, cron.FuncJob(func() {
+		fired := time.Now()
+		plannedAt := time.Unix(0, planned.Load())
+		lag := fired.Sub(plannedAt)
+		metrics.ScheduleLag.Observe(lag.Seconds())
+		planned.Store(sched.Next(fired).UnixNano())
+
+		if !s.begin() {
+			return
+		}
 		go func(j db.Job) {
+			defer s.runs.Done()
+			ctx := logging.WithRunID(s.runCtx, logging.NewID())
 			defer func() {
 				if r := recover(); r != nil {
-					log.Printf("panic in job %s: %v", j.ID.String(), r)
+					slog.ErrorContext(ctx, "panic in scheduled run", "job_id", j.ID.String(), "panic", r)
 				}
 			}()
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			defer cancel()
-			if _, err := s.js.RunOnce(ctx, j); err != nil {
-				log.Printf("run job %s error: %v", j.ID.String(), err)
-			}
+			ctx, span := tracing.Tracer().Start(ctx, "scheduler.fire",
+				trace.WithNewRoot(),
+				trace.WithTimestamp(fired),
+				trace.WithAttributes(
+					attribute.String("cronix.job.id", j.ID.String()),
+					attribute.String("cronix.job.schedule", j.Schedule),
+					attribute.String("cronix.schedule.planned_at", plannedAt.Format(time.RFC3339Nano)),
+					attribute.Int64("cronix.schedule.lag_ms", lag.Milliseconds()),
+				))
+			defer span.End()
+			slog.DebugContext(ctx, "scheduler fired", "job_id", j.ID.String(), "lag_ms", lag.Milliseconds())
+			s.runChain(ctx, j)
 		}(job)
-	})
-	if err != nil {
-		return err
-	}
+	}))
 	s.ids[job.ID.String()] = id
 	return nil
 }
 
+// runChain runs job and then the jobs its outcome triggers, as stored
+// when it finishes, theirs in turn and so on, one at a time and each job
+// at most once. Every run gets runTimeout to finish and all share the run
+// group of the first. Triggered jobs run whether or not they are active.
+func (s *Scheduler) runChain(ctx context.Context, job db.Job) {
+	ctx = logging.WithRunGroup(ctx, logging.RunID(ctx))
+	type step struct {
+		job  db.Job
+		body *string // upstream response body for an http job to send instead of its own
+	}
+	queue := []step{{job: job}}
+	ran := map[pgtype.UUID]bool{}
+	for first := true; len(queue) > 0; first = false {
+		st := queue[0]
+		queue = queue[1:]
+		if ran[st.job.ID] {
+			continue
+		}
+		ran[st.job.ID] = true
+
+		runCtx := ctx
+		if !first {
+			if s.State().Stopping || ctx.Err() != nil {
+				return
+			}
+			runCtx = logging.WithRunID(ctx, logging.NewID())
+		}
+		j := st.job
+		if st.body != nil {
+			j.Body = pgtype.Text{String: *st.body, Valid: true}
+		}
+		log, err := s.run(runCtx, j)
+		if err != nil {
+			slog.ErrorContext(runCtx, "scheduled run failed", "job_id", j.ID.String(), "error", err)
+			continue
+		}
+
+		src := st.job
+		if first {
+			// The scheduler's copy dates from when the job was scheduled,
+			// and its triggers may have been edited or dropped since
+			if src, err = s.js.Get(runCtx, st.job.ID); err != nil {
+				if errors.Is(err, ErrNotFound) {
+					return // deleted while it ran
+				}
+				slog.ErrorContext(runCtx, "failed to reload job", "job_id", j.ID.String(), "error", err)
+				return
+			}
+		}
+		next, err := s.js.Triggered(runCtx, src, log.Status)
+		if err != nil {
+			slog.ErrorContext(runCtx, "failed to load triggered jobs", "job_id", j.ID.String(), "error", err)
+		}
+		var body *string
+		if ParseTriggers(src.Triggers).PassBody && log.ResponseBody.Valid {
+			body = &log.ResponseBody.String
+		}
+		for _, n := range next {
+			slog.DebugContext(runCtx, "job triggered", "job_id", j.ID.String(), "triggered_job_id", n.ID.String(), "status", log.Status)
+			queued := step{job: n}
+			if jobType(n) == JobTypeHTTP {
+				// Other job types send no body
+				queued.body = body
+			}
+			queue = append(queue, queued)
+		}
+	}
+}
+
+// run runs job once with the scheduler's run timeout.
+func (s *Scheduler) run(ctx context.Context, job db.Job) (db.JobLog, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.runTimeout)
+	defer cancel()
+	return s.js.RunOnce(ctx, job)
+}
+
 func (s *Scheduler) RemoveJob(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if entry, ok := s.ids[jobID]; ok {
 		s.c.Remove(entry)
 		delete(s.ids, jobID)
 	}
 }
+
+// Len returns the number of scheduled jobs.
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.ids)
+}
+
+// SchedulerState is a snapshot of the scheduler for health checks.
+type SchedulerState struct {
+	Started  bool `json:"started"`
+	Stopping bool `json:"stopping"`
+	Entries  int  `json:"entries"`
+	// Leader reports whether this instance fires jobs. There is no leader
+	// election yet, so every running scheduler fires all active jobs.
+	Leader bool `json:"leader"`
+}
+
+func (s *Scheduler) State() SchedulerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SchedulerState{
+		Started:  s.started,
+		Stopping: s.stopping,
+		Entries:  len(s.ids),
+		Leader:   s.started && !s.stopping,
+	}
+}
@@ -0,0 +1,210 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/executor"
+	"cronix.ashutosh.net/internals/services"
+)
+
+func everySecond(p *db.CreateJobParams) {
+	p.Schedule = "* * * * * *"
+	p.Active = true
+}
+
+func TestSchedulerRunsActiveJobs(t *testing.T) {
+	e := newEnv(t, services.JobsSettings{})
+	var hits atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits.Add(1) }))
+	defer target.Close()
+	active := e.job(t, "active", target.URL+"/active", everySecond)
+	inactive := e.job(t, "inactive", target.URL+"/inactive", func(p *db.CreateJobParams) { p.Schedule = "* * * * * *" })
+
+	s := services.NewScheduler(e.js, 5*time.Second)
+	if err := s.Start(context.Background(), []db.Job{active, inactive}); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Len(); n != 1 {
+		t.Errorf("scheduled %d jobs, want only the active one", n)
+	}
+	waitFor(t, 3*time.Second, "a scheduled run", func() bool { return len(e.logs(t, active)) > 0 })
+	stop(t, s)
+
+	if logs := e.logs(t, inactive); len(logs) != 0 {
+		t.Errorf("inactive job ran %d times", len(logs))
+	}
+	if st := s.State(); !st.Stopping || st.Leader {
+		t.Errorf("state after stop = %+v", st)
+	}
+}
+
+func TestSchedulerRemoveJob(t *testing.T) {
+	e := newEnv(t, services.JobsSettings{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	job := e.job(t, "removed", target.URL, everySecond)
+
+	s := services.NewScheduler(e.js, 5*time.Second)
+	if err := s.Start(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, s)
+	if err := s.AddJob(job); err != nil {
+		t.Fatal(err)
+	}
+	// Adding a job again replaces its entry
+	if err := s.AddJob(job); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Len(); n != 1 {
+		t.Fatalf("Len = %d after adding one job twice", n)
+	}
+	s.RemoveJob(job.ID.String())
+	if n := s.Len(); n != 0 {
+		t.Fatalf("Len = %d after removing the job", n)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if logs := e.logs(t, job); len(logs) != 0 {
+		t.Errorf("removed job ran %d times", len(logs))
+	}
+}
+
+func TestSchedulerRejectsInvalidSchedule(t *testing.T) {
+	e := newEnv(t, services.JobsSettings{})
+	job := e.job(t, "bad", "http://127.0.0.1/", func(p *db.CreateJobParams) { p.Schedule = "often" })
+	s := services.NewScheduler(e.js, time.Second)
+	if err := s.AddJob(job); err == nil {
+		t.Error("AddJob accepted an invalid schedule")
+	}
+}
+
+func TestSchedulerStopAbortsRuns(t *testing.T) {
+	e := newEnv(t, services.JobsSettings{})
+	started := make(chan struct{}, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done()
+	}))
+	defer target.Close()
+	job := e.job(t, "hanging", target.URL, everySecond)
+
+	s := services.NewScheduler(e.js, time.Minute)
+	if err := s.Start(context.Background(), []db.Job{job}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the job did not run")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := s.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Stop = %v, want the drain deadline", err)
+	}
+	logs := e.logs(t, job)
+	if len(logs) != 1 || logs[0].Status != "aborted" {
+		t.Fatalf("logs = %+v, want one aborted run", logs)
+	}
+}
+
+func stop(t *testing.T, s *services.Scheduler) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A scheduled run fires the triggers the job has when it finishes, not
+// those it had when it was scheduled.
+func TestSchedulerUsesStoredTriggers(t *testing.T) {
+	e := newEnv(t, services.JobsSettings{})
+	bodies := make(chan string, 10)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/follower" {
+			b, _ := io.ReadAll(r.Body)
+			bodies <- string(b)
+			return
+		}
+		w.Write([]byte("from the root"))
+	}))
+	defer target.Close()
+	root := e.job(t, "root", target.URL+"/root", everySecond)
+	follower := e.job(t, "follower", target.URL+"/follower")
+
+	s := services.NewScheduler(e.js, 5*time.Second)
+	if err := s.Start(context.Background(), []db.Job{root}); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, s)
+	// Edited without rescheduling the root, like the triggers Delete drops
+	triggers := &services.Triggers{OnSuccess: []string{follower.ID.String()}, PassBody: true}
+	active := true
+	if _, err := e.js.Update(context.Background(), root.ID, nil, nil, nil, nil, nil, nil, &active, nil, nil, nil, nil, nil, nil, triggers); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case body := <-bodies:
+		if body != "from the root" {
+			t.Errorf("follower got %q, want the root's response", body)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the root's new trigger did not fire")
+	}
+}
+
+// bodyProbe is a job type that records the body of each job it runs.
+type bodyProbe struct {
+	bodies chan db.Job
+}
+
+func (p bodyProbe) Problems(db.Job) []string { return nil }
+
+func (p bodyProbe) Execute(_ context.Context, job db.Job) executor.Result {
+	p.bodies <- job
+	return executor.Result{}
+}
+
+// pass_body gives the upstream response to the http jobs triggered, and
+// leaves jobs of other types as stored.
+func TestPassBodyOnlyReachesHTTPJobs(t *testing.T) {
+	probe := bodyProbe{bodies: make(chan db.Job, 10)}
+	e := newEnv(t, services.JobsSettings{Executors: map[string]executor.Executor{"probe": probe}})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("from the root"))
+	}))
+	defer target.Close()
+	follower := e.job(t, "probe", "probe.example.com", func(p *db.CreateJobParams) { p.Type = "probe" })
+	root := e.job(t, "root", target.URL, everySecond, func(p *db.CreateJobParams) {
+		p.Triggers = []byte(fmt.Sprintf(`{"on_success":[%q],"pass_body":true}`, follower.ID))
+	})
+
+	s := services.NewScheduler(e.js, 5*time.Second)
+	if err := s.Start(context.Background(), []db.Job{root}); err != nil {
+		t.Fatal(err)
+	}
+	defer stop(t, s)
+	select {
+	case job := <-probe.bodies:
+		if job.Body.Valid {
+			t.Errorf("probe job got body %q", job.Body.String)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the root did not trigger the probe job")
+	}
+}
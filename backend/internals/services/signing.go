@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/signature"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// errSigningDisabled is returned when a job's signing secret cannot be
+// sealed or opened because no secrets key is configured.
+var errSigningDisabled = &Error{
+	Kind:    ErrValidation,
+	Code:    "signing_disabled",
+	Message: "request signing is not enabled on this server",
+	Details: "the administrator must set secrets.key (SECRETS_KEY)",
+}
+
+// JobSigningSecret returns the secret job signs its requests with, or ""
+// if they are not signed.
+func (s *JobsService) JobSigningSecret(job db.Job) (string, error) {
+	if len(job.SigningSecret) == 0 {
+		return "", nil
+	}
+	if s.settings.Secrets == nil {
+		return "", errSigningDisabled
+	}
+	secret, err := s.settings.Secrets.Open(job.SigningSecret, job.UserID.Bytes[:])
+	if err != nil {
+		return "", fmt.Errorf("job signing secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+// SigningSecret returns the signing secret of userID's job id.
+func (s *JobsService) SigningSecret(ctx context.Context, userID, id pgtype.UUID) (string, error) {
+	job, err := s.ownJob(ctx, userID, id)
+	if err != nil {
+		return "", err
+	}
+	secret, err := s.JobSigningSecret(job)
+	if err == nil && secret == "" {
+		return "", NotFoundError("signing secret")
+	}
+	return secret, err
+}
+
+// RotateSigningSecret gives userID's job id a new signing secret, replacing
+// the current one at once, and returns the updated job and the secret.
+// Runs use the secret of the job they are given, so a scheduled job must be
+// rescheduled with the returned one.
+func (s *JobsService) RotateSigningSecret(ctx context.Context, userID, id pgtype.UUID) (db.Job, string, error) {
+	if s.settings.Secrets == nil {
+		return db.Job{}, "", errSigningDisabled
+	}
+	job, err := s.ownJob(ctx, userID, id)
+	if err != nil {
+		return db.Job{}, "", err
+	}
+	secret, err := signature.NewSecret()
+	if err != nil {
+		return db.Job{}, "", err
+	}
+	job, err = s.q.SetJobSigningSecret(ctx, db.SetJobSigningSecretParams{
+		ID:            job.ID,
+		SigningSecret: s.settings.Secrets.Seal([]byte(secret), job.UserID.Bytes[:]),
+	})
+	if err != nil {
+		return db.Job{}, "", dbError(err, "job")
+	}
+	return job, secret, nil
+}
+
+// DisableSigning removes the signing secret of userID's job id, so its
+// requests are no longer signed, and returns the updated job, which must
+// be rescheduled like after RotateSigningSecret.
+func (s *JobsService) DisableSigning(ctx context.Context, userID, id pgtype.UUID) (db.Job, error) {
+	job, err := s.ownJob(ctx, userID, id)
+	if err != nil {
+		return db.Job{}, err
+	}
+	job, err = s.q.SetJobSigningSecret(ctx, db.SetJobSigningSecretParams{ID: job.ID})
+	if err != nil {
+		return db.Job{}, dbError(err, "job")
+	}
+	return job, nil
+}
+
+// ownJob loads job id, treating a job of another user as missing.
+func (s *JobsService) ownJob(ctx context.Context, userID, id pgtype.UUID) (db.Job, error) {
+	job, err := s.Get(ctx, id)
+	if err == nil && job.UserID != userID {
+		return db.Job{}, NotFoundError("job")
+	}
+	return job, err
+}
+
+// sign adds the signature header to req, which carries body, if secret is
+// set. It must run last, after anything that changes the request URI.
+func sign(req *http.Request, secret string, body []byte) {
+	if secret == "" {
+		return
+	}
+	req.Header.Set(signature.Header, signature.Sign(secret, time.Now(), req.Method, req.URL.RequestURI(), body))
+}
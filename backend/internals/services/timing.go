@@ -0,0 +1,101 @@
+package services
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"cronix.ashutosh.net/internals/executor"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// runTimer records the phases of one outbound request through
+// net/http/httptrace. Phases that did not happen, such as DNS and connect on
+// a reused connection, are left zero and stored as NULL.
+//
+//	dns      DNSStart -> DNSDone
+//	connect  ConnectStart -> ConnectDone (TCP only)
+//	tls      TLSHandshakeStart -> TLSHandshakeDone
+//	ttfb     request fully written -> first response byte
+//	download first response byte -> body read
+type runTimer struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wrote        time.Time
+	firstByte    time.Time
+	bodyDone     time.Time
+}
+
+func (t *runTimer) set(field *time.Time) {
+	t.mu.Lock()
+	*field = time.Now()
+	t.mu.Unlock()
+}
+
+// trace returns hooks to install with httptrace.WithClientTrace. They are
+// composed with any trace already on the context, such as the tracing
+// package's spans.
+func (t *runTimer) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone) },
+		ConnectStart: func(_, _ string) {
+			// With several addresses the dialer may race connections;
+			// measure from the first attempt.
+			t.mu.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.set(&t.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				t.set(&t.tlsDone)
+			}
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wrote) },
+		GotFirstResponseByte: func() { t.set(&t.firstByte) },
+	}
+}
+
+// finishBody marks the response body as fully read.
+func (t *runTimer) finishBody() { t.set(&t.bodyDone) }
+
+// record sets the phase durations of res.
+func (t *runTimer) record(res *executor.Result) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	res.DNS = phase(t.dnsStart, t.dnsDone)
+	res.Connect = phase(t.connectStart, t.connectDone)
+	res.TLS = phase(t.tlsStart, t.tlsDone)
+	res.TTFB = phase(t.wrote, t.firstByte)
+	res.Download = phase(t.firstByte, t.bodyDone)
+}
+
+func phase(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// durationMs is a phase duration as stored, NULL if the phase did not
+// happen.
+func durationMs(d time.Duration) pgtype.Int4 {
+	if d <= 0 {
+		return pgtype.Int4{}
+	}
+	return pgtype.Int4{Int32: int32(d.Milliseconds()), Valid: true}
+}
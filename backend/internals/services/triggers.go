@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"cronix.ashutosh.net/internals/db"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxTriggered bounds the jobs one outcome of a job triggers.
+const maxTriggered = 20
+
+// Triggers are the jobs run after a scheduled run of a job, stored as JSON
+// in jobs.triggers. The API refers to the jobs by id and manifests by name.
+type Triggers struct {
+	OnSuccess []string `json:"on_success,omitempty" yaml:"on_success,omitempty"`
+	OnFailure []string `json:"on_failure,omitempty" yaml:"on_failure,omitempty"`
+	// PassBody makes the response body of the run the request body of the
+	// http jobs it triggers.
+	PassBody bool `json:"pass_body,omitempty" yaml:"pass_body,omitempty"`
+}
+
+// IsZero reports whether t triggers nothing.
+func (t Triggers) IsZero() bool {
+	return len(t.OnSuccess) == 0 && len(t.OnFailure) == 0 && !t.PassBody
+}
+
+// For returns the jobs triggered by a run that ended with status. Aborted
+// runs trigger nothing.
+func (t Triggers) For(status string) []string {
+	switch status {
+	case "success":
+		return t.OnSuccess
+	case "failure":
+		return t.OnFailure
+	}
+	return nil
+}
+
+// all returns every job t triggers, each once.
+func (t Triggers) all() []string {
+	out := slices.Concat(t.OnSuccess, t.OnFailure)
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// Problems lists what is wrong with t on its own, for validation errors.
+func (t Triggers) Problems() []string {
+	var p []string
+	for _, l := range []struct {
+		name string
+		refs []string
+	}{{"on_success", t.OnSuccess}, {"on_failure", t.OnFailure}} {
+		if len(l.refs) > maxTriggered {
+			p = append(p, fmt.Sprintf("triggers.%s lists more than %d jobs", l.name, maxTriggered))
+		}
+		seen := make(map[string]bool, len(l.refs))
+		for _, ref := range l.refs {
+			if seen[ref] {
+				p = append(p, fmt.Sprintf("triggers.%s lists %s twice", l.name, ref))
+			}
+			seen[ref] = true
+		}
+	}
+	return p
+}
+
+// ParseTriggers decodes a jobs.triggers value; anything unreadable
+// triggers nothing.
+func ParseTriggers(raw []byte) Triggers {
+	var t Triggers
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &t)
+	}
+	return t
+}
+
+// Encode returns t as stored in jobs.triggers.
+func (t Triggers) Encode() []byte {
+	b, _ := json.Marshal(t)
+	return b
+}
+
+// rename returns t with every job replaced by name(job).
+func (t Triggers) rename(name func(string) string) Triggers {
+	out := Triggers{PassBody: t.PassBody}
+	for _, ref := range t.OnSuccess {
+		out.OnSuccess = append(out.OnSuccess, name(ref))
+	}
+	for _, ref := range t.OnFailure {
+		out.OnFailure = append(out.OnFailure, name(ref))
+	}
+	return out
+}
+
+// triggerCycle returns a path of triggers from start back to start, or nil
+// if there is none. next returns the jobs a job triggers.
+func triggerCycle(start string, next func(string) []string) []string {
+	visited := map[string]bool{}
+	var path []string
+	var visit func(job string) bool
+	visit = func(job string) bool {
+		path = append(path, job)
+		for _, n := range next(job) {
+			if n == start {
+				path = append(path, n)
+				return true
+			}
+			if !visited[n] {
+				visited[n] = true
+				if visit(n) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	if visit(start) {
+		return path
+	}
+	return nil
+}
+
+// CheckTriggers validates the triggers of job id of userID; id is not
+// set for a job being created. Every triggered job must be another of the
+// user's jobs, and following triggers must never lead back to the job. It
+// returns t with the ids in canonical form, as they are stored.
+func (s *JobsService) CheckTriggers(ctx context.Context, userID, id pgtype.UUID, t Triggers) (Triggers, error) {
+	if p := t.Problems(); len(p) > 0 {
+		return t, ValidationError("invalid triggers", p)
+	}
+	if t.IsZero() {
+		return t, nil
+	}
+	jobs, err := s.q.ListAllJobsByUser(ctx, userID)
+	if err != nil {
+		return t, err
+	}
+	byID := make(map[string]db.Job, len(jobs))
+	for _, j := range jobs {
+		byID[j.ID.String()] = j
+	}
+	var p []string
+	for _, ref := range t.all() {
+		var tid pgtype.UUID
+		switch {
+		case tid.Scan(ref) != nil:
+			p = append(p, fmt.Sprintf("%q is not a job id", ref))
+		case id.Valid && tid == id:
+			p = append(p, "a job cannot trigger itself")
+		case byID[tid.String()].ID != tid:
+			p = append(p, fmt.Sprintf("job %s does not exist", ref))
+		}
+	}
+	if len(p) > 0 {
+		return t, ValidationError("invalid triggers", p)
+	}
+	t = t.rename(func(ref string) string {
+		var tid pgtype.UUID
+		_ = tid.Scan(ref)
+		return tid.String()
+	})
+	if p := t.Problems(); len(p) > 0 {
+		return t, ValidationError("invalid triggers", p)
+	}
+	// A new job cannot be triggered by anything yet
+	if !id.Valid {
+		return t, nil
+	}
+	self := id.String()
+	cycle := triggerCycle(self, func(job string) []string {
+		if job == self {
+			return t.all()
+		}
+		return ParseTriggers(byID[job].Triggers).all()
+	})
+	if cycle == nil {
+		return t, nil
+	}
+	names := make([]string, len(cycle))
+	for i, ref := range cycle {
+		names[i] = byID[ref].Name
+	}
+	return t, ValidationError("invalid triggers", fmt.Sprintf("triggers would form a cycle: %s", strings.Join(names, " -> ")))
+}
+
+// Triggered returns the jobs to run after a run of job that ended with
+// status. Jobs deleted since or owned by another user are skipped.
+func (s *JobsService) Triggered(ctx context.Context, job db.Job, status string) ([]db.Job, error) {
+	var out []db.Job
+	for _, ref := range ParseTriggers(job.Triggers).For(status) {
+		var id pgtype.UUID
+		if err := id.Scan(ref); err != nil {
+			continue
+		}
+		next, err := s.q.GetJob(ctx, id)
+		if err = dbError(err, "job"); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				slog.WarnContext(ctx, "triggered job no longer exists", "job_id", job.ID.String(), "triggered_job_id", ref)
+				continue
+			}
+			return out, err
+		}
+		if next.UserID != job.UserID {
+			continue
+		}
+		out = append(out, next)
+	}
+	return out, nil
+}
+
+// dropTriggersOf removes the deleted job from the triggers of the other
+// jobs of userID.
+func (s *JobsService) dropTriggersOf(ctx context.Context, userID, deleted pgtype.UUID) error {
+	jobs, err := s.q.ListAllJobsByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	ref := deleted.String()
+	for _, j := range jobs {
+		t := ParseTriggers(j.Triggers)
+		if !slices.Contains(t.all(), ref) {
+			continue
+		}
+		t.OnSuccess = slices.DeleteFunc(t.OnSuccess, func(r string) bool { return r == ref })
+		t.OnFailure = slices.DeleteFunc(t.OnFailure, func(r string) bool { return r == ref })
+		if _, err := s.q.SetJobTriggers(ctx, db.SetJobTriggersParams{ID: j.ID, Triggers: t.Encode()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,622 @@
+// Package memory is an in-process implementation of db.Querier for tests.
+//
+// It follows the semantics of the Postgres queries the services rely on:
+// ordering and pagination of list queries, COALESCE-style partial updates,
+// per-job log pruning, cascading deletes and foreign keys, and the errors Postgres returns
+// (pgx.ErrNoRows for missing rows, *pgconn.PgError for constraint
+// violations) so that services map them the same way.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type Store struct {
+	mu    sync.Mutex
+	now   func() time.Time
+	users []db.User
+	jobs  []db.Job
+	logs  []db.JobLog
+	certs []db.ClientCertificate
+}
+
+var _ db.Querier = (*Store)(nil)
+
+type Option func(*Store)
+
+// WithClock replaces time.Now for created_at, updated_at and similar
+// defaults, so tests can control ordering.
+func WithClock(now func() time.Time) Option {
+	return func(s *Store) { s.now = now }
+}
+
+func New(opts ...Option) *Store {
+	s := &Store{now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// timestamp matches Postgres' microsecond precision.
+func (s *Store) timestamp() pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: s.now().Truncate(time.Microsecond), Valid: true}
+}
+
+func pgError(code, constraint, format string, args ...any) error {
+	return &pgconn.PgError{Severity: "ERROR", Code: code, ConstraintName: constraint, Message: fmt.Sprintf(format, args...)}
+}
+
+func notNull(column string) error {
+	return pgError("23502", "", "null value in column %q violates not-null constraint", column)
+}
+
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return slices.Clone(b)
+}
+
+func cloneJob(j db.Job) db.Job {
+	j.Headers = cloneBytes(j.Headers)
+	j.Transport = cloneBytes(j.Transport)
+	j.Auth = cloneBytes(j.Auth)
+	j.AuthSecret = cloneBytes(j.AuthSecret)
+	j.SigningSecret = cloneBytes(j.SigningSecret)
+	j.Redirects = cloneBytes(j.Redirects)
+	j.Config = cloneBytes(j.Config)
+	j.Triggers = cloneBytes(j.Triggers)
+	return j
+}
+
+func cloneLog(l db.JobLog) db.JobLog {
+	l.Redirects = cloneBytes(l.Redirects)
+	return l
+}
+
+func cloneCert(c db.ClientCertificate) db.ClientCertificate {
+	c.PrivateKey = cloneBytes(c.PrivateKey)
+	return c
+}
+
+func cloneJobs(jobs []db.Job) []db.Job {
+	out := make([]db.Job, len(jobs))
+	for i, j := range jobs {
+		out[i] = cloneJob(j)
+	}
+	return out
+}
+
+// page applies LIMIT and OFFSET; Postgres rejects negative values.
+func page[T any](items []T, limit, offset int32) ([]T, error) {
+	if limit < 0 {
+		return nil, pgError("2201W", "", "LIMIT must not be negative")
+	}
+	if offset < 0 {
+		return nil, pgError("2201X", "", "OFFSET must not be negative")
+	}
+	if int(offset) >= len(items) {
+		return []T{}, nil
+	}
+	items = items[offset:]
+	if int(limit) < len(items) {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+// InTx runs fn on the store and puts back what the store held before if fn
+// fails. Unlike a real transaction it does not isolate fn: other callers
+// see its writes at once, and theirs are undone along with fn's.
+func (s *Store) InTx(ctx context.Context, fn func(db.Querier) error) error {
+	s.mu.Lock()
+	users, jobs, logs, certs := slices.Clone(s.users), slices.Clone(s.jobs), slices.Clone(s.logs), slices.Clone(s.certs)
+	s.mu.Unlock()
+	if err := fn(s); err != nil {
+		s.mu.Lock()
+		s.users, s.jobs, s.logs, s.certs = users, jobs, logs, certs
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s *Store) userIndex(id pgtype.UUID) int {
+	return slices.IndexFunc(s.users, func(u db.User) bool { return u.ID == id })
+}
+
+func (s *Store) jobIndex(id pgtype.UUID) int {
+	return slices.IndexFunc(s.jobs, func(j db.Job) bool { return j.ID == id })
+}
+
+func (s *Store) certIndex(id pgtype.UUID) int {
+	return slices.IndexFunc(s.certs, func(c db.ClientCertificate) bool { return c.ID == id })
+}
+
+// checkCertificate enforces jobs_client_certificate_id_fkey.
+func (s *Store) checkCertificate(id pgtype.UUID) error {
+	if id.Valid && s.certIndex(id) < 0 {
+		return pgError("23503", "jobs_client_certificate_id_fkey", "insert or update on table \"jobs\" violates foreign key constraint \"jobs_client_certificate_id_fkey\"")
+	}
+	return nil
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.users, func(u db.User) bool { return u.Email == arg.Email }) {
+		return db.User{}, pgError("23505", "users_email_key", "duplicate key value violates unique constraint \"users_email_key\"")
+	}
+	now := s.timestamp()
+	u := db.User{
+		ID:        store.NewUUID(),
+		Email:     arg.Email,
+		Name:      arg.Name,
+		AvatarUrl: arg.AvatarUrl,
+		Provider:  arg.Provider,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.users = append(s.users, u)
+	return u, nil
+}
+
+func (s *Store) GetUser(ctx context.Context, id pgtype.UUID) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.userIndex(id); i >= 0 {
+		return s.users[i], nil
+	}
+	return db.User{}, pgx.ErrNoRows
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return db.User{}, pgx.ErrNoRows
+}
+
+func (s *Store) ListUsers(ctx context.Context) ([]db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := slices.Clone(s.users)
+	slices.SortStableFunc(out, func(a, b db.User) int { return b.CreatedAt.Time.Compare(a.CreatedAt.Time) })
+	if out == nil {
+		out = []db.User{}
+	}
+	return out, nil
+}
+
+func (s *Store) UpdateUser(ctx context.Context, arg db.UpdateUserParams) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndex(arg.ID)
+	if i < 0 {
+		return db.User{}, pgx.ErrNoRows
+	}
+	u := &s.users[i]
+	u.Name = arg.Name
+	u.AvatarUrl = arg.AvatarUrl
+	u.UpdatedAt = s.timestamp()
+	return *u, nil
+}
+
+// DeleteUser removes the user and, as ON DELETE CASCADE does, their jobs,
+// logs and client certificates.
+func (s *Store) DeleteUser(ctx context.Context, id pgtype.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = slices.DeleteFunc(s.users, func(u db.User) bool { return u.ID == id })
+	for _, j := range s.jobs {
+		if j.UserID == id {
+			s.deleteJobLocked(j.ID)
+		}
+	}
+	s.certs = slices.DeleteFunc(s.certs, func(c db.ClientCertificate) bool { return c.UserID == id })
+	return nil
+}
+
+func (s *Store) CreateJob(ctx context.Context, arg db.CreateJobParams) (db.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if arg.Headers == nil {
+		return db.Job{}, notNull("headers")
+	}
+	if s.userIndex(arg.UserID) < 0 {
+		return db.Job{}, pgError("23503", "jobs_user_id_fkey", "insert or update on table \"jobs\" violates foreign key constraint \"jobs_user_id_fkey\"")
+	}
+	if err := s.checkCertificate(arg.ClientCertificateID); err != nil {
+		return db.Job{}, err
+	}
+	transport := []byte("{}")
+	if arg.Transport != nil {
+		transport = cloneBytes(arg.Transport)
+	}
+	auth := []byte("{}")
+	if arg.Auth != nil {
+		auth = cloneBytes(arg.Auth)
+	}
+	redirects := []byte("{}")
+	if arg.Redirects != nil {
+		redirects = cloneBytes(arg.Redirects)
+	}
+	typ := arg.Type
+	if typ == "" {
+		typ = "http"
+	}
+	config := []byte("{}")
+	if arg.Config != nil {
+		config = cloneBytes(arg.Config)
+	}
+	triggers := []byte("{}")
+	if arg.Triggers != nil {
+		triggers = cloneBytes(arg.Triggers)
+	}
+	now := s.timestamp()
+	j := db.Job{
+		ID:        store.NewUUID(),
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		Schedule:  arg.Schedule,
+		Endpoint:  arg.Endpoint,
+		Method:    arg.Method,
+		Headers:   cloneBytes(arg.Headers),
+		Body:      arg.Body,
+		Active:    arg.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Transport: transport,
+
+		ClientCertificateID: arg.ClientCertificateID,
+		Auth:                auth,
+		AuthSecret:          cloneBytes(arg.AuthSecret),
+		Redirects:           redirects,
+		Type:                typ,
+		Config:              config,
+		Triggers:            triggers,
+	}
+	s.jobs = append(s.jobs, j)
+	return cloneJob(j), nil
+}
+
+func (s *Store) GetJob(ctx context.Context, id pgtype.UUID) (db.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.jobIndex(id); i >= 0 {
+		return cloneJob(s.jobs[i]), nil
+	}
+	return db.Job{}, pgx.ErrNoRows
+}
+
+// coalesceText keeps current unless v is a non-empty string, as the
+// COALESCE(NULLIF(...)) in UpdateJob does for its untyped parameters.
+func coalesceText(v any, current string) string {
+	if s, ok := v.(string); ok && s != "" {
+		return s
+	}
+	return current
+}
+
+func (s *Store) UpdateJob(ctx context.Context, arg db.UpdateJobParams) (db.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.jobIndex(arg.ID)
+	if i < 0 {
+		return db.Job{}, pgx.ErrNoRows
+	}
+	if arg.SetClientCertificate {
+		if err := s.checkCertificate(arg.ClientCertificateID); err != nil {
+			return db.Job{}, err
+		}
+	}
+	j := &s.jobs[i]
+	j.Name = coalesceText(arg.Column2, j.Name)
+	j.Schedule = coalesceText(arg.Column3, j.Schedule)
+	j.Endpoint = coalesceText(arg.Column4, j.Endpoint)
+	j.Method = coalesceText(arg.Column5, j.Method)
+	if arg.Headers != nil {
+		j.Headers = cloneBytes(arg.Headers)
+	}
+	if arg.Body.Valid {
+		j.Body = arg.Body
+	}
+	if arg.Active.Valid {
+		j.Active = arg.Active.Bool
+	}
+	if arg.Transport != nil {
+		j.Transport = cloneBytes(arg.Transport)
+	}
+	if arg.SetClientCertificate {
+		j.ClientCertificateID = arg.ClientCertificateID
+	}
+	if arg.Auth != nil {
+		j.Auth = cloneBytes(arg.Auth)
+		j.AuthSecret = cloneBytes(arg.AuthSecret)
+	}
+	if arg.Redirects != nil {
+		j.Redirects = cloneBytes(arg.Redirects)
+	}
+	if arg.Type != "" {
+		j.Type = arg.Type
+	}
+	if arg.Config != nil {
+		j.Config = cloneBytes(arg.Config)
+	}
+	if arg.Triggers != nil {
+		j.Triggers = cloneBytes(arg.Triggers)
+	}
+	j.UpdatedAt = s.timestamp()
+	return cloneJob(*j), nil
+}
+
+func (s *Store) SetJobSigningSecret(ctx context.Context, arg db.SetJobSigningSecretParams) (db.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.jobIndex(arg.ID)
+	if i < 0 {
+		return db.Job{}, pgx.ErrNoRows
+	}
+	j := &s.jobs[i]
+	j.SigningSecret = cloneBytes(arg.SigningSecret)
+	j.UpdatedAt = s.timestamp()
+	return cloneJob(*j), nil
+}
+
+func (s *Store) SetJobTriggers(ctx context.Context, arg db.SetJobTriggersParams) (db.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.jobIndex(arg.ID)
+	if i < 0 {
+		return db.Job{}, pgx.ErrNoRows
+	}
+	j := &s.jobs[i]
+	j.Triggers = cloneBytes(arg.Triggers)
+	j.UpdatedAt = s.timestamp()
+	return cloneJob(*j), nil
+}
+
+func (s *Store) DeleteJob(ctx context.Context, id pgtype.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteJobLocked(id)
+	return nil
+}
+
+func (s *Store) deleteJobLocked(id pgtype.UUID) {
+	s.jobs = slices.DeleteFunc(s.jobs, func(j db.Job) bool { return j.ID == id })
+	s.logs = slices.DeleteFunc(s.logs, func(l db.JobLog) bool { return l.JobID == id })
+}
+
+// jobsWhere returns matching jobs, newest first as ORDER BY created_at DESC.
+func (s *Store) jobsWhere(match func(db.Job) bool) []db.Job {
+	var out []db.Job
+	for _, j := range s.jobs {
+		if match(j) {
+			out = append(out, cloneJob(j))
+		}
+	}
+	slices.SortStableFunc(out, func(a, b db.Job) int { return b.CreatedAt.Time.Compare(a.CreatedAt.Time) })
+	if out == nil {
+		out = []db.Job{}
+	}
+	return out
+}
+
+func (s *Store) ListJobsByUser(ctx context.Context, arg db.ListJobsByUserParams) ([]db.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return page(s.jobsWhere(func(j db.Job) bool { return j.UserID == arg.UserID }), arg.Limit, arg.Offset)
+}
+
+func (s *Store) ListAllJobsByUser(ctx context.Context, userID pgtype.UUID) ([]db.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := s.jobsWhere(func(j db.Job) bool { return j.UserID == userID })
+	slices.SortStableFunc(out, func(a, b db.Job) int {
+		if a.Name != b.Name {
+			if a.Name < b.Name {
+				return -1
+			}
+			return 1
+		}
+		return a.CreatedAt.Time.Compare(b.CreatedAt.Time)
+	})
+	return out, nil
+}
+
+func (s *Store) ListActiveJobs(ctx context.Context) ([]db.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobsWhere(func(j db.Job) bool { return j.Active }), nil
+}
+
+func (s *Store) InsertJobLog(ctx context.Context, arg db.InsertJobLogParams) (db.JobLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.jobIndex(arg.JobID) < 0 {
+		return db.JobLog{}, pgError("23503", "job_logs_job_id_fkey", "insert or update on table \"job_logs\" violates foreign key constraint \"job_logs_job_id_fkey\"")
+	}
+	started := arg.StartedAt
+	if !started.Valid {
+		return db.JobLog{}, notNull("started_at")
+	}
+	l := db.JobLog{
+		ID:           store.NewUUID(),
+		JobID:        arg.JobID,
+		StartedAt:    pgtype.Timestamptz{Time: started.Time.Truncate(time.Microsecond), Valid: true},
+		FinishedAt:   arg.FinishedAt,
+		DurationMs:   arg.DurationMs,
+		Status:       arg.Status,
+		ResponseCode: arg.ResponseCode,
+		Error:        arg.Error,
+		ResponseBody: arg.ResponseBody,
+		DnsMs:        arg.DnsMs,
+		ConnectMs:    arg.ConnectMs,
+		TlsMs:        arg.TlsMs,
+		TtfbMs:       arg.TtfbMs,
+		DownloadMs:   arg.DownloadMs,
+		Redirects:    cloneBytes(arg.Redirects),
+		RunGroup:     arg.RunGroup,
+	}
+	s.logs = append(s.logs, l)
+	return cloneLog(l), nil
+}
+
+// logsOf returns a job's logs, newest first as ORDER BY started_at DESC.
+func (s *Store) logsOf(jobID pgtype.UUID) []db.JobLog {
+	out := []db.JobLog{}
+	for _, l := range s.logs {
+		if l.JobID == jobID {
+			out = append(out, cloneLog(l))
+		}
+	}
+	slices.SortStableFunc(out, func(a, b db.JobLog) int { return b.StartedAt.Time.Compare(a.StartedAt.Time) })
+	return out
+}
+
+func (s *Store) ListJobLogs(ctx context.Context, arg db.ListJobLogsParams) ([]db.JobLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return page(s.logsOf(arg.JobID), arg.Limit, arg.Offset)
+}
+
+func (s *Store) ListRecentJobLogs(ctx context.Context, arg db.ListRecentJobLogsParams) ([]db.JobLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return page(s.logsOf(arg.JobID), arg.Limit, 0)
+}
+
+// DeleteOldJobLogs deletes the job's logs that started before its keep-th
+// newest one. Logs sharing that start time survive, as in the SQL version.
+func (s *Store) DeleteOldJobLogs(ctx context.Context, arg db.DeleteOldJobLogsParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if arg.Keep < 1 {
+		return pgError("2201X", "", "OFFSET must not be negative")
+	}
+	logs := s.logsOf(arg.JobID)
+	if len(logs) < int(arg.Keep) {
+		return nil
+	}
+	cutoff := logs[arg.Keep-1].StartedAt.Time
+	s.logs = slices.DeleteFunc(s.logs, func(l db.JobLog) bool {
+		return l.JobID == arg.JobID && l.StartedAt.Time.Before(cutoff)
+	})
+	return nil
+}
+
+// CleanupAllOldLogs keeps the keep newest logs of every job.
+func (s *Store) CleanupAllOldLogs(ctx context.Context, keep int32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	retained := map[pgtype.UUID]bool{}
+	seen := map[pgtype.UUID]bool{}
+	for _, l := range s.logs {
+		if seen[l.JobID] {
+			continue
+		}
+		seen[l.JobID] = true
+		for i, kept := range s.logsOf(l.JobID) {
+			if int32(i) >= keep {
+				break
+			}
+			retained[kept.ID] = true
+		}
+	}
+	s.logs = slices.DeleteFunc(s.logs, func(l db.JobLog) bool { return !retained[l.ID] })
+	return nil
+}
+
+func (s *Store) CreateClientCertificate(ctx context.Context, arg db.CreateClientCertificateParams) (db.ClientCertificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if arg.PrivateKey == nil {
+		return db.ClientCertificate{}, notNull("private_key")
+	}
+	if !arg.NotAfter.Valid {
+		return db.ClientCertificate{}, notNull("not_after")
+	}
+	if s.userIndex(arg.UserID) < 0 {
+		return db.ClientCertificate{}, pgError("23503", "client_certificates_user_id_fkey", "insert or update on table \"client_certificates\" violates foreign key constraint \"client_certificates_user_id_fkey\"")
+	}
+	if slices.ContainsFunc(s.certs, func(c db.ClientCertificate) bool { return c.UserID == arg.UserID && c.Name == arg.Name }) {
+		return db.ClientCertificate{}, pgError("23505", "client_certificates_user_id_name_key", "duplicate key value violates unique constraint \"client_certificates_user_id_name_key\"")
+	}
+	c := db.ClientCertificate{
+		ID:          store.NewUUID(),
+		UserID:      arg.UserID,
+		Name:        arg.Name,
+		Certificate: arg.Certificate,
+		PrivateKey:  cloneBytes(arg.PrivateKey),
+		CaBundle:    arg.CaBundle,
+		Subject:     arg.Subject,
+		Fingerprint: arg.Fingerprint,
+		NotAfter:    pgtype.Timestamptz{Time: arg.NotAfter.Time.Truncate(time.Microsecond), Valid: true},
+		CreatedAt:   s.timestamp(),
+	}
+	s.certs = append(s.certs, c)
+	return cloneCert(c), nil
+}
+
+func (s *Store) GetClientCertificate(ctx context.Context, arg db.GetClientCertificateParams) (db.ClientCertificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.certIndex(arg.ID); i >= 0 && s.certs[i].UserID == arg.UserID {
+		return cloneCert(s.certs[i]), nil
+	}
+	return db.ClientCertificate{}, pgx.ErrNoRows
+}
+
+// ListClientCertificatesByUser orders by name, then created_at.
+func (s *Store) ListClientCertificatesByUser(ctx context.Context, userID pgtype.UUID) ([]db.ClientCertificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []db.ClientCertificate{}
+	for _, c := range s.certs {
+		if c.UserID == userID {
+			out = append(out, cloneCert(c))
+		}
+	}
+	slices.SortStableFunc(out, func(a, b db.ClientCertificate) int {
+		if a.Name != b.Name {
+			if a.Name < b.Name {
+				return -1
+			}
+			return 1
+		}
+		return a.CreatedAt.Time.Compare(b.CreatedAt.Time)
+	})
+	return out, nil
+}
+
+// DeleteClientCertificate fails with a foreign key violation while jobs
+// still refer to the certificate, as the SQL schema does.
+func (s *Store) DeleteClientCertificate(ctx context.Context, arg db.DeleteClientCertificateParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.certIndex(arg.ID)
+	if i < 0 || s.certs[i].UserID != arg.UserID {
+		return nil
+	}
+	if slices.ContainsFunc(s.jobs, func(j db.Job) bool { return j.ClientCertificateID == arg.ID }) {
+		return pgError("23503", "jobs_client_certificate_id_fkey", "update or delete on table \"client_certificates\" violates foreign key constraint \"jobs_client_certificate_id_fkey\" on table \"jobs\"")
+	}
+	s.certs = slices.Delete(s.certs, i, i+1)
+	return nil
+}
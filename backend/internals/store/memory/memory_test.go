@@ -0,0 +1,13 @@
+package memory_test
+
+import (
+	"testing"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/store/memory"
+	"cronix.ashutosh.net/internals/store/storetest"
+)
+
+func TestContract(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) db.Querier { return memory.New() })
+}
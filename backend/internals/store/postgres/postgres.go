@@ -0,0 +1,30 @@
+// Package postgres is the Postgres repository: the sqlc-generated queries
+// on a pgx pool, plus transactions, which db.Queries cannot start itself.
+package postgres
+
+import (
+	"context"
+
+	"cronix.ashutosh.net/internals/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Store struct {
+	*db.Queries
+	pool *pgxpool.Pool
+}
+
+var _ db.Querier = (*Store)(nil)
+
+func New(pool *pgxpool.Pool) *Store {
+	return &Store{Queries: db.New(pool), pool: pool}
+}
+
+// InTx runs fn with queries bound to a transaction, committed if fn
+// succeeds and rolled back otherwise.
+func (s *Store) InTx(ctx context.Context, fn func(db.Querier) error) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		return fn(s.Queries.WithTx(tx))
+	})
+}
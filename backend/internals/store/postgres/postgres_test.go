@@ -0,0 +1,74 @@
+package postgres_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"cronix.ashutosh.net/database"
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/store/postgres"
+	"cronix.ashutosh.net/internals/store/storetest"
+)
+
+// postgresDSNEnv names a Postgres database the tests may create schemas
+// in; they are skipped without it.
+const postgresDSNEnv = "CRONIX_TEST_POSTGRES_DSN"
+
+func TestContract(t *testing.T) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skip(postgresDSNEnv + " is not set")
+	}
+	storetest.Run(t, func(t *testing.T) db.Querier { return postgres.New(migratedPool(t, dsn)) })
+}
+
+// migratedPool returns a pool whose connections use a new schema, dropped
+// after the test, with the migrations applied.
+func migratedPool(t *testing.T, dsn string) *pgxpool.Pool {
+	t.Helper()
+	ctx := context.Background()
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	name := "cronix_test_" + hex.EncodeToString(b)
+
+	admin, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.Exec(ctx, `CREATE SCHEMA `+name); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec(ctx, `DROP SCHEMA `+name+` CASCADE`)
+		admin.Close(ctx)
+	})
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = name + ",public"
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	sqlDB := stdlib.OpenDBFromPool(pool)
+	defer sqlDB.Close()
+	m, err := database.NewMigrator(sqlDB, database.Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
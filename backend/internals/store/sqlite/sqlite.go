@@ -0,0 +1,475 @@
+// Package sqlite implements db.Querier on SQLite through the pure-Go
+// modernc.org/sqlite driver, so a single binary can run without Postgres.
+//
+// The queries mirror database/queries against the schema in
+// database/sqlite. IDs are stored as UUID text, timestamps as fixed-width
+// UTC text with microseconds so that they sort chronologically, booleans as
+// integers and headers as JSON text. Errors are translated to what the
+// Postgres queries return (pgx.ErrNoRows for missing rows, *pgconn.PgError
+// for constraint violations) so that services map them the same way.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	driver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// timeLayout is how timestamps are stored; it must stay fixed-width.
+const timeLayout = "2006-01-02T15:04:05.000000Z"
+
+// Open opens or creates the database file at path with foreign keys
+// enforced and write-ahead logging. The pool holds a single connection:
+// SQLite allows one writer at a time, and queuing in database/sql is
+// kinder than SQLITE_BUSY errors.
+func Open(path string) (*sql.DB, error) {
+	q := url.Values{"_pragma": {"foreign_keys(1)", "journal_mode(WAL)", "busy_timeout(5000)"}}
+	sqlDB, err := sql.Open("sqlite", "file:"+path+"?"+q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return sqlDB, nil
+}
+
+type Store struct {
+	db  conn
+	now func() time.Time
+}
+
+// conn is what queries run on: the pool, or a transaction from InTx.
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+var _ db.Querier = (*Store)(nil)
+
+type Option func(*Store)
+
+// WithClock replaces time.Now for created_at, updated_at and similar
+// defaults, so tests can control ordering.
+func WithClock(now func() time.Time) Option {
+	return func(s *Store) { s.now = now }
+}
+
+func New(sqlDB *sql.DB, opts ...Option) *Store {
+	s := &Store{db: sqlDB, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// InTx runs fn with a store bound to a transaction, committed if fn
+// succeeds and rolled back otherwise. The pool has a single connection, so
+// fn must only use the store it is given. Within a transaction fn runs on
+// the same one.
+func (s *Store) InTx(ctx context.Context, fn func(db.Querier) error) error {
+	sqlDB, ok := s.db.(*sql.DB)
+	if !ok {
+		return fn(s)
+	}
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return mapError(err)
+	}
+	if err := fn(&Store{db: tx, now: s.now}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return mapError(tx.Commit())
+}
+
+func (s *Store) timestamp() string {
+	return s.now().UTC().Format(timeLayout)
+}
+
+// timeArg binds a nullable timestamp.
+func timeArg(t pgtype.Timestamptz) any {
+	if !t.Valid {
+		return nil
+	}
+	return t.Time.UTC().Format(timeLayout)
+}
+
+// jsonArg binds headers, keeping nil as NULL so NOT NULL still applies.
+func jsonArg(b []byte) any {
+	if b == nil {
+		return nil
+	}
+	return string(b)
+}
+
+// blobArg binds a nil byte slice as NULL rather than an empty blob.
+func blobArg(b []byte) any {
+	if b == nil {
+		return nil
+	}
+	return b
+}
+
+// timestamptz scans a stored timestamp into a pgtype.Timestamptz.
+type timestamptz struct{ dst *pgtype.Timestamptz }
+
+func (t timestamptz) Scan(src any) error {
+	var raw string
+	switch v := src.(type) {
+	case nil:
+		*t.dst = pgtype.Timestamptz{}
+		return nil
+	case time.Time:
+		*t.dst = pgtype.Timestamptz{Time: v, Valid: true}
+		return nil
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("sqlite: cannot scan %T into a timestamp", src)
+	}
+	parsed, err := time.Parse(timeLayout, raw)
+	if err != nil {
+		return fmt.Errorf("sqlite: parse timestamp %q: %w", raw, err)
+	}
+	*t.dst = pgtype.Timestamptz{Time: parsed, Valid: true}
+	return nil
+}
+
+// mapError translates driver errors into the ones pgx would return.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return pgx.ErrNoRows
+	}
+	var sqErr *driver.Error
+	if !errors.As(err, &sqErr) {
+		return err
+	}
+	pgErr := &pgconn.PgError{Severity: "ERROR", Message: sqErr.Error()}
+	switch sqErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		pgErr.Code = "23505"
+		pgErr.ConstraintName = uniqueConstraint(sqErr.Error())
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		pgErr.Code = "23503"
+	case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+		pgErr.Code = "23502"
+	default:
+		return err
+	}
+	return pgErr
+}
+
+// uniqueConstraint turns "UNIQUE constraint failed: users.email" into the
+// name Postgres gives the same constraint, users_email_key; a constraint on
+// several columns names them all, as in client_certificates_user_id_name_key.
+func uniqueConstraint(msg string) string {
+	const marker = "constraint failed: "
+	i := strings.LastIndex(msg, marker)
+	if i < 0 {
+		return ""
+	}
+	cols, _, _ := strings.Cut(msg[i+len(marker):], " (")
+	var table string
+	var names []string
+	for _, c := range strings.Split(cols, ", ") {
+		t, col, ok := strings.Cut(c, ".")
+		if !ok {
+			return ""
+		}
+		table = t
+		names = append(names, col)
+	}
+	return table + "_" + strings.Join(names, "_") + "_key"
+}
+
+// checkPage rejects what Postgres rejects; SQLite reads a negative LIMIT as
+// no limit.
+func checkPage(limit, offset int32) error {
+	if limit < 0 {
+		return &pgconn.PgError{Severity: "ERROR", Code: "2201W", Message: "LIMIT must not be negative"}
+	}
+	if offset < 0 {
+		return &pgconn.PgError{Severity: "ERROR", Code: "2201X", Message: "OFFSET must not be negative"}
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+const userColumns = "id, email, name, avatar_url, provider, created_at, updated_at"
+
+func scanUser(row scanner) (db.User, error) {
+	var u db.User
+	err := row.Scan(&u.ID, &u.Email, &u.Name, &u.AvatarUrl, &u.Provider,
+		timestamptz{&u.CreatedAt}, timestamptz{&u.UpdatedAt})
+	return u, mapError(err)
+}
+
+const jobColumns = "id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at, transport, client_certificate_id, auth, auth_secret, signing_secret, redirects, type, config, triggers"
+
+func scanJob(row scanner) (db.Job, error) {
+	var j db.Job
+	err := row.Scan(&j.ID, &j.UserID, &j.Name, &j.Schedule, &j.Endpoint, &j.Method,
+		&j.Headers, &j.Body, &j.Active, timestamptz{&j.CreatedAt}, timestamptz{&j.UpdatedAt}, &j.Transport,
+		&j.ClientCertificateID, &j.Auth, &j.AuthSecret, &j.SigningSecret, &j.Redirects, &j.Type, &j.Config, &j.Triggers)
+	return j, mapError(err)
+}
+
+const certColumns = "id, user_id, name, certificate, private_key, ca_bundle, subject, fingerprint, not_after, created_at"
+
+func scanCert(row scanner) (db.ClientCertificate, error) {
+	var c db.ClientCertificate
+	err := row.Scan(&c.ID, &c.UserID, &c.Name, &c.Certificate, &c.PrivateKey, &c.CaBundle,
+		&c.Subject, &c.Fingerprint, timestamptz{&c.NotAfter}, timestamptz{&c.CreatedAt})
+	return c, mapError(err)
+}
+
+const jobLogColumns = "id, job_id, started_at, finished_at, duration_ms, status, response_code, error, response_body, dns_ms, connect_ms, tls_ms, ttfb_ms, download_ms, redirects, run_group"
+
+func scanJobLog(row scanner) (db.JobLog, error) {
+	var l db.JobLog
+	err := row.Scan(&l.ID, &l.JobID, timestamptz{&l.StartedAt}, timestamptz{&l.FinishedAt},
+		&l.DurationMs, &l.Status, &l.ResponseCode, &l.Error, &l.ResponseBody,
+		&l.DnsMs, &l.ConnectMs, &l.TlsMs, &l.TtfbMs, &l.DownloadMs, &l.Redirects, &l.RunGroup)
+	return l, mapError(err)
+}
+
+// list runs a query and scans every row with scan, returning an empty
+// slice rather than nil when nothing matches, as pgx does.
+func list[T any](ctx context.Context, s *Store, scan func(scanner) (T, error), query string, args ...any) ([]T, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+	items := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return items, nil
+}
+
+func (s *Store) exec(ctx context.Context, query string, args ...any) error {
+	_, err := s.db.ExecContext(ctx, query, args...)
+	return mapError(err)
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+	now := s.timestamp()
+	return scanUser(s.db.QueryRowContext(ctx, `INSERT INTO users (id, email, name, avatar_url, provider, created_at, updated_at)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?6)
+RETURNING `+userColumns,
+		store.NewUUID(), arg.Email, arg.Name, arg.AvatarUrl, arg.Provider, now))
+}
+
+func (s *Store) GetUser(ctx context.Context, id pgtype.UUID) (db.User, error) {
+	return scanUser(s.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?1`, id))
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (db.User, error) {
+	return scanUser(s.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE email = ?1`, email))
+}
+
+func (s *Store) ListUsers(ctx context.Context) ([]db.User, error) {
+	return list(ctx, s, scanUser, `SELECT `+userColumns+` FROM users ORDER BY created_at DESC`)
+}
+
+func (s *Store) UpdateUser(ctx context.Context, arg db.UpdateUserParams) (db.User, error) {
+	return scanUser(s.db.QueryRowContext(ctx, `UPDATE users
+SET name = ?2, avatar_url = ?3, updated_at = ?4
+WHERE id = ?1
+RETURNING `+userColumns,
+		arg.ID, arg.Name, arg.AvatarUrl, s.timestamp()))
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id pgtype.UUID) error {
+	return s.exec(ctx, `DELETE FROM users WHERE id = ?1`, id)
+}
+
+func (s *Store) CreateJob(ctx context.Context, arg db.CreateJobParams) (db.Job, error) {
+	now := s.timestamp()
+	return scanJob(s.db.QueryRowContext(ctx, `INSERT INTO jobs (id, user_id, name, schedule, endpoint, method, headers, body, active, created_at, updated_at, transport, client_certificate_id, auth, auth_secret, redirects, type, config, triggers)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?10, COALESCE(?11, '{}'), ?12, COALESCE(?13, '{}'), ?14, COALESCE(?15, '{}'),
+  COALESCE(NULLIF(?16, ''), 'http'), COALESCE(?17, '{}'), COALESCE(?18, '{}'))
+RETURNING `+jobColumns,
+		store.NewUUID(), arg.UserID, arg.Name, arg.Schedule, arg.Endpoint, arg.Method,
+		jsonArg(arg.Headers), arg.Body, arg.Active, now, jsonArg(arg.Transport), arg.ClientCertificateID,
+		jsonArg(arg.Auth), blobArg(arg.AuthSecret), jsonArg(arg.Redirects), arg.Type, jsonArg(arg.Config), jsonArg(arg.Triggers)))
+}
+
+func (s *Store) GetJob(ctx context.Context, id pgtype.UUID) (db.Job, error) {
+	return scanJob(s.db.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = ?1`, id))
+}
+
+func (s *Store) ListJobsByUser(ctx context.Context, arg db.ListJobsByUserParams) ([]db.Job, error) {
+	if err := checkPage(arg.Limit, arg.Offset); err != nil {
+		return nil, err
+	}
+	return list(ctx, s, scanJob, `SELECT `+jobColumns+` FROM jobs
+WHERE user_id = ?1
+ORDER BY created_at DESC
+LIMIT ?2 OFFSET ?3`, arg.UserID, arg.Limit, arg.Offset)
+}
+
+func (s *Store) ListAllJobsByUser(ctx context.Context, userID pgtype.UUID) ([]db.Job, error) {
+	return list(ctx, s, scanJob, `SELECT `+jobColumns+` FROM jobs
+WHERE user_id = ?1
+ORDER BY name ASC, created_at ASC`, userID)
+}
+
+func (s *Store) ListActiveJobs(ctx context.Context) ([]db.Job, error) {
+	return list(ctx, s, scanJob, `SELECT `+jobColumns+` FROM jobs
+WHERE active = 1
+ORDER BY created_at DESC`)
+}
+
+func (s *Store) UpdateJob(ctx context.Context, arg db.UpdateJobParams) (db.Job, error) {
+	return scanJob(s.db.QueryRowContext(ctx, `UPDATE jobs
+SET
+  name = COALESCE(NULLIF(?2, ''), name),
+  schedule = COALESCE(NULLIF(?3, ''), schedule),
+  endpoint = COALESCE(NULLIF(?4, ''), endpoint),
+  method = COALESCE(NULLIF(?5, ''), method),
+  headers = COALESCE(?6, headers),
+  body = COALESCE(?7, body),
+  active = COALESCE(?8, active),
+  transport = COALESCE(?10, transport),
+  client_certificate_id = CASE WHEN ?11 THEN ?12 ELSE client_certificate_id END,
+  auth = COALESCE(?13, auth),
+  auth_secret = CASE WHEN ?13 IS NULL THEN auth_secret ELSE ?14 END,
+  redirects = COALESCE(?15, redirects),
+  type = COALESCE(NULLIF(?16, ''), type),
+  config = COALESCE(?17, config),
+  triggers = COALESCE(?18, triggers),
+  updated_at = ?9
+WHERE id = ?1
+RETURNING `+jobColumns,
+		arg.ID, arg.Column2, arg.Column3, arg.Column4, arg.Column5,
+		jsonArg(arg.Headers), arg.Body, arg.Active, s.timestamp(), jsonArg(arg.Transport),
+		arg.SetClientCertificate, arg.ClientCertificateID, jsonArg(arg.Auth), blobArg(arg.AuthSecret),
+		jsonArg(arg.Redirects), arg.Type, jsonArg(arg.Config), jsonArg(arg.Triggers)))
+}
+
+func (s *Store) SetJobSigningSecret(ctx context.Context, arg db.SetJobSigningSecretParams) (db.Job, error) {
+	return scanJob(s.db.QueryRowContext(ctx, `UPDATE jobs
+SET signing_secret = ?2, updated_at = ?3
+WHERE id = ?1
+RETURNING `+jobColumns, arg.ID, blobArg(arg.SigningSecret), s.timestamp()))
+}
+
+func (s *Store) SetJobTriggers(ctx context.Context, arg db.SetJobTriggersParams) (db.Job, error) {
+	return scanJob(s.db.QueryRowContext(ctx, `UPDATE jobs
+SET triggers = ?2, updated_at = ?3
+WHERE id = ?1
+RETURNING `+jobColumns, arg.ID, jsonArg(arg.Triggers), s.timestamp()))
+}
+
+func (s *Store) DeleteJob(ctx context.Context, id pgtype.UUID) error {
+	return s.exec(ctx, `DELETE FROM jobs WHERE id = ?1`, id)
+}
+
+func (s *Store) InsertJobLog(ctx context.Context, arg db.InsertJobLogParams) (db.JobLog, error) {
+	return scanJobLog(s.db.QueryRowContext(ctx, `INSERT INTO job_logs (id, job_id, started_at, finished_at, duration_ms, status, response_code, error, response_body, dns_ms, connect_ms, tls_ms, ttfb_ms, download_ms, redirects, run_group)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14, ?15, ?16)
+RETURNING `+jobLogColumns,
+		store.NewUUID(), arg.JobID, timeArg(arg.StartedAt), timeArg(arg.FinishedAt),
+		arg.DurationMs, arg.Status, arg.ResponseCode, arg.Error, arg.ResponseBody,
+		arg.DnsMs, arg.ConnectMs, arg.TlsMs, arg.TtfbMs, arg.DownloadMs, jsonArg(arg.Redirects), arg.RunGroup))
+}
+
+func (s *Store) ListJobLogs(ctx context.Context, arg db.ListJobLogsParams) ([]db.JobLog, error) {
+	if err := checkPage(arg.Limit, arg.Offset); err != nil {
+		return nil, err
+	}
+	return list(ctx, s, scanJobLog, `SELECT `+jobLogColumns+` FROM job_logs
+WHERE job_id = ?1
+ORDER BY started_at DESC
+LIMIT ?2 OFFSET ?3`, arg.JobID, arg.Limit, arg.Offset)
+}
+
+func (s *Store) ListRecentJobLogs(ctx context.Context, arg db.ListRecentJobLogsParams) ([]db.JobLog, error) {
+	if err := checkPage(arg.Limit, 0); err != nil {
+		return nil, err
+	}
+	return list(ctx, s, scanJobLog, `SELECT `+jobLogColumns+` FROM job_logs
+WHERE job_id = ?1
+ORDER BY started_at DESC
+LIMIT ?2`, arg.JobID, arg.Limit)
+}
+
+func (s *Store) DeleteOldJobLogs(ctx context.Context, arg db.DeleteOldJobLogsParams) error {
+	if err := checkPage(0, arg.Keep-1); err != nil {
+		return err
+	}
+	return s.exec(ctx, `DELETE FROM job_logs
+WHERE job_id = ?1
+AND started_at < (
+    SELECT inner_logs.started_at
+    FROM job_logs AS inner_logs
+    WHERE inner_logs.job_id = ?1
+    ORDER BY inner_logs.started_at DESC
+    LIMIT 1 OFFSET ?2 - 1
+)`, arg.JobID, arg.Keep)
+}
+
+func (s *Store) CleanupAllOldLogs(ctx context.Context, keep int32) error {
+	return s.exec(ctx, `DELETE FROM job_logs
+WHERE id NOT IN (
+    SELECT id
+    FROM (
+        SELECT id,
+               ROW_NUMBER() OVER (PARTITION BY job_id ORDER BY started_at DESC) AS rn
+        FROM job_logs
+    ) ranked
+    WHERE rn <= ?1
+)`, keep)
+}
+
+func (s *Store) CreateClientCertificate(ctx context.Context, arg db.CreateClientCertificateParams) (db.ClientCertificate, error) {
+	return scanCert(s.db.QueryRowContext(ctx, `INSERT INTO client_certificates (id, user_id, name, certificate, private_key, ca_bundle, subject, fingerprint, not_after, created_at)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10)
+RETURNING `+certColumns,
+		store.NewUUID(), arg.UserID, arg.Name, arg.Certificate, arg.PrivateKey, arg.CaBundle,
+		arg.Subject, arg.Fingerprint, timeArg(arg.NotAfter), s.timestamp()))
+}
+
+func (s *Store) GetClientCertificate(ctx context.Context, arg db.GetClientCertificateParams) (db.ClientCertificate, error) {
+	return scanCert(s.db.QueryRowContext(ctx, `SELECT `+certColumns+` FROM client_certificates
+WHERE id = ?1 AND user_id = ?2`, arg.ID, arg.UserID))
+}
+
+func (s *Store) ListClientCertificatesByUser(ctx context.Context, userID pgtype.UUID) ([]db.ClientCertificate, error) {
+	return list(ctx, s, scanCert, `SELECT `+certColumns+` FROM client_certificates
+WHERE user_id = ?1
+ORDER BY name ASC, created_at ASC`, userID)
+}
+
+func (s *Store) DeleteClientCertificate(ctx context.Context, arg db.DeleteClientCertificateParams) error {
+	return s.exec(ctx, `DELETE FROM client_certificates WHERE id = ?1 AND user_id = ?2`, arg.ID, arg.UserID)
+}
@@ -0,0 +1,30 @@
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"cronix.ashutosh.net/database"
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/store/sqlite"
+	"cronix.ashutosh.net/internals/store/storetest"
+)
+
+func TestContract(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) db.Querier {
+		sqlDB, err := sqlite.Open(filepath.Join(t.TempDir(), "cronix.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { sqlDB.Close() })
+		m, err := database.NewMigrator(sqlDB, database.SQLite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Up(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return sqlite.New(sqlDB)
+	})
+}
@@ -0,0 +1,19 @@
+// Package store holds the storage backends behind services.Repository,
+// one per subpackage.
+package store
+
+import (
+	"crypto/rand"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// NewUUID returns a random version 4 UUID, as gen_random_uuid() does.
+func NewUUID() pgtype.UUID {
+	var u pgtype.UUID
+	_, _ = rand.Read(u.Bytes[:])
+	u.Bytes[6] = (u.Bytes[6] & 0x0f) | 0x40 // version 4
+	u.Bytes[8] = (u.Bytes[8] & 0x3f) | 0x80 // RFC 4122 variant
+	u.Valid = true
+	return u
+}
@@ -0,0 +1,860 @@
+// Package storetest is the contract of a db.Querier: the semantics of the
+// Postgres queries in database/queries that the services rely on. Each
+// backend's tests run it, so the in-memory and SQLite stores cannot drift
+// from Postgres unnoticed.
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"cronix.ashutosh.net/internals/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// transactor is implemented by stores that can run queries atomically,
+// as services.Transactor.
+type transactor interface {
+	InTx(ctx context.Context, fn func(db.Querier) error) error
+}
+
+// Run runs every case of the contract on its own store. open must return
+// an empty store with the schema migrated.
+func Run(t *testing.T, open func(t *testing.T) db.Querier) {
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.run(t, open(t))
+		})
+	}
+}
+
+var cases = []struct {
+	name string
+	run  func(t *testing.T, q db.Querier)
+}{
+	{"users", testUsers},
+	{"missing rows", testMissingRows},
+	{"constraints", testConstraints},
+	{"job defaults", testJobDefaults},
+	{"partial job update", testUpdateJob},
+	{"auth secret follows auth", testAuthSecret},
+	{"job lists", testJobLists},
+	{"signing secret and triggers", testJobSetters},
+	{"job logs", testJobLogs},
+	{"log pruning", testLogPruning},
+	{"negative pages", testNegativePages},
+	{"cascading deletes", testCascades},
+	{"certificates", testCertificates},
+	{"transactions", testTransactions},
+}
+
+var ctx = context.Background()
+
+func testUsers(t *testing.T, q db.Querier) {
+	u, err := q.CreateUser(ctx, db.CreateUserParams{Email: "a@example.com", Name: text("A"), Provider: "google"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.ID.Valid || !u.CreatedAt.Valid || !u.UpdatedAt.Valid || u.Name.String != "A" || u.AvatarUrl.Valid {
+		t.Errorf("created user = %+v", u)
+	}
+	for _, get := range []func() (db.User, error){
+		func() (db.User, error) { return q.GetUser(ctx, u.ID) },
+		func() (db.User, error) { return q.GetUserByEmail(ctx, u.Email) },
+	} {
+		got, err := get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		same(t, "user", normUser(got), normUser(u))
+	}
+
+	tick()
+	updated, err := q.UpdateUser(ctx, db.UpdateUserParams{ID: u.ID, AvatarUrl: text("https://example.com/a.png")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both columns are replaced, even by NULL
+	if updated.Name.Valid || updated.AvatarUrl.String != "https://example.com/a.png" {
+		t.Errorf("updated user = %+v", updated)
+	}
+	if !updated.UpdatedAt.Time.After(u.UpdatedAt.Time) {
+		t.Error("UpdateUser did not move updated_at")
+	}
+
+	tick()
+	newer := user(t, q, "b@example.com")
+	users, err := q.ListUsers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].ID != newer.ID {
+		t.Errorf("ListUsers = %v, want the newest user first", userIDs(users))
+	}
+
+	if err := q.DeleteUser(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.GetUser(ctx, u.ID); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetUser after DeleteUser = %v", err)
+	}
+}
+
+func testMissingRows(t *testing.T, q db.Querier) {
+	u := user(t, q, "a@example.com")
+	missing := uuid(0x7f)
+	calls := map[string]func() error{
+		"GetUser":        func() error { _, err := q.GetUser(ctx, missing); return err },
+		"GetUserByEmail": func() error { _, err := q.GetUserByEmail(ctx, "nobody@example.com"); return err },
+		"UpdateUser":     func() error { _, err := q.UpdateUser(ctx, db.UpdateUserParams{ID: missing}); return err },
+		"GetJob":         func() error { _, err := q.GetJob(ctx, missing); return err },
+		"UpdateJob":      func() error { _, err := q.UpdateJob(ctx, db.UpdateJobParams{ID: missing, Column2: "x"}); return err },
+		"SetJobSigningSecret": func() error {
+			_, err := q.SetJobSigningSecret(ctx, db.SetJobSigningSecretParams{ID: missing, SigningSecret: []byte("s")})
+			return err
+		},
+		"SetJobTriggers": func() error {
+			_, err := q.SetJobTriggers(ctx, db.SetJobTriggersParams{ID: missing, Triggers: []byte("{}")})
+			return err
+		},
+		"GetClientCertificate": func() error {
+			_, err := q.GetClientCertificate(ctx, db.GetClientCertificateParams{ID: missing, UserID: u.ID})
+			return err
+		},
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, pgx.ErrNoRows) {
+			t.Errorf("%s = %v, want pgx.ErrNoRows", name, err)
+		}
+	}
+
+	// Deleting what does not exist is not an error
+	if err := q.DeleteJob(ctx, missing); err != nil {
+		t.Errorf("DeleteJob = %v", err)
+	}
+	if err := q.DeleteUser(ctx, missing); err != nil {
+		t.Errorf("DeleteUser = %v", err)
+	}
+	if err := q.DeleteClientCertificate(ctx, db.DeleteClientCertificateParams{ID: missing, UserID: u.ID}); err != nil {
+		t.Errorf("DeleteClientCertificate = %v", err)
+	}
+}
+
+func testConstraints(t *testing.T, q db.Querier) {
+	u := user(t, q, "a@example.com")
+	_, err := q.CreateUser(ctx, db.CreateUserParams{Email: "a@example.com", Provider: "google"})
+	wantPgError(t, "duplicate email", err, "23505", "users_email_key")
+
+	p := jobParams(u, "j")
+	p.UserID = uuid(0x7f)
+	_, err = q.CreateJob(ctx, p)
+	wantPgError(t, "job of a missing user", err, "23503", "")
+
+	p = jobParams(u, "j")
+	p.Headers = nil
+	_, err = q.CreateJob(ctx, p)
+	wantPgError(t, "job without headers", err, "23502", "")
+
+	p = jobParams(u, "j")
+	p.ClientCertificateID = uuid(0x7f)
+	_, err = q.CreateJob(ctx, p)
+	wantPgError(t, "job with a missing certificate", err, "23503", "")
+
+	_, err = q.InsertJobLog(ctx, logParams(uuid(0x7f), time.Now(), "success"))
+	wantPgError(t, "log of a missing job", err, "23503", "")
+}
+
+func testJobDefaults(t *testing.T, q db.Querier) {
+	u := user(t, q, "a@example.com")
+	p := jobParams(u, "j")
+	p.Headers = []byte(`{"X-Team": "infra"}`)
+	p.Body = text(`{"ping":true}`)
+	j, err := q.CreateJob(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !j.ID.Valid || j.UserID != u.ID || !j.CreatedAt.Valid || !j.UpdatedAt.Valid {
+		t.Errorf("created job = %+v", j)
+	}
+	if j.Type != "http" {
+		t.Errorf("type = %q, want http for an empty type", j.Type)
+	}
+	for name, b := range map[string][]byte{
+		"transport": j.Transport, "auth": j.Auth, "redirects": j.Redirects, "config": j.Config, "triggers": j.Triggers,
+	} {
+		sameJSON(t, name, b, "{}")
+	}
+	sameJSON(t, "headers", j.Headers, `{"X-Team":"infra"}`)
+	if j.Body.String != `{"ping":true}` || j.AuthSecret != nil || j.SigningSecret != nil || j.ClientCertificateID.Valid {
+		t.Errorf("created job = %+v", j)
+	}
+
+	got, err := q.GetJob(ctx, j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same(t, "job", normJob(got), normJob(j))
+
+	p.Type = "dns"
+	p.Config = []byte(`{"record_type":"A"}`)
+	p.Triggers = []byte(`{"on_success":[]}`)
+	dns, err := q.CreateJob(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dns.Type != "dns" {
+		t.Errorf("type = %q, want dns", dns.Type)
+	}
+	sameJSON(t, "config", dns.Config, `{"record_type":"A"}`)
+	sameJSON(t, "triggers", dns.Triggers, `{"on_success":[]}`)
+}
+
+func testUpdateJob(t *testing.T, q db.Querier) {
+	u := user(t, q, "a@example.com")
+	cert := certificate(t, q, u, "c")
+	p := jobParams(u, "j")
+	p.Body = text("body")
+	p.Active = true
+	p.Transport = []byte(`{"tls_min_version":"1.2"}`)
+	p.ClientCertificateID = cert.ID
+	j, err := q.CreateJob(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tick()
+	// Empty strings and nil values keep the column.
+	// Columns 2 to 5 are name, schedule, endpoint and method.
+	got, err := q.UpdateJob(ctx, db.UpdateJobParams{ID: j.ID, Column2: "renamed", Column3: "", Column4: "", Column5: ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := normJob(j)
+	want.Name = "renamed"
+	want.UpdatedAt = pgtype.Timestamptz{}
+	if !got.UpdatedAt.Time.After(j.UpdatedAt.Time) {
+		t.Error("UpdateJob did not move updated_at")
+	}
+	got = normJob(got)
+	got.UpdatedAt = pgtype.Timestamptz{}
+	same(t, "job after a partial update", got, want)
+
+	// active is a nullable flag like the other columns
+	for _, step := range []struct {
+		active pgtype.Bool
+		want   bool
+	}{
+		{pgtype.Bool{Bool: false, Valid: true}, false},
+		{pgtype.Bool{}, false},
+		{pgtype.Bool{Bool: true, Valid: true}, true},
+		{pgtype.Bool{}, true},
+	} {
+		got, err := q.UpdateJob(ctx, db.UpdateJobParams{ID: j.ID, Active: step.active})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Active != step.want {
+			t.Errorf("active = %v after updating it with %+v, want %v", got.Active, step.active, step.want)
+		}
+	}
+
+	got, err = q.UpdateJob(ctx, db.UpdateJobParams{
+		ID:        j.ID,
+		Column3:   "@hourly",
+		Column4:   "https://example.com/other",
+		Column5:   "PUT",
+		Headers:   []byte(`{"A":"b"}`),
+		Body:      text("other"),
+		Active:    pgtype.Bool{Bool: true, Valid: true},
+		Transport: []byte(`{}`),
+		Redirects: []byte(`{"follow":false}`),
+		Type:      "tcp",
+		Config:    []byte(`{"port":22}`),
+		Triggers:  []byte(`{"pass_body":true}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Schedule != "@hourly" || got.Endpoint != "https://example.com/other" || got.Method != "PUT" ||
+		got.Body.String != "other" || !got.Active || got.Type != "tcp" {
+		t.Errorf("job after a full update = %+v", got)
+	}
+	sameJSON(t, "headers", got.Headers, `{"A":"b"}`)
+	sameJSON(t, "transport", got.Transport, `{}`)
+	sameJSON(t, "redirects", got.Redirects, `{"follow":false}`)
+	sameJSON(t, "config", got.Config, `{"port":22}`)
+	sameJSON(t, "triggers", got.Triggers, `{"pass_body":true}`)
+	if got.ClientCertificateID != cert.ID {
+		t.Error("the certificate changed without set_client_certificate")
+	}
+
+	// set_client_certificate replaces the certificate, even with NULL
+	got, err = q.UpdateJob(ctx, db.UpdateJobParams{ID: j.ID, SetClientCertificate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ClientCertificateID.Valid {
+		t.Errorf("certificate = %v, want it cleared", got.ClientCertificateID)
+	}
+	got, err = q.UpdateJob(ctx, db.UpdateJobParams{ID: j.ID, SetClientCertificate: true, ClientCertificateID: cert.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ClientCertificateID != cert.ID {
+		t.Errorf("certificate = %v, want %v", got.ClientCertificateID, cert.ID)
+	}
+}
+
+func testAuthSecret(t *testing.T, q db.Querier) {
+	u := user(t, q, "a@example.com")
+	p := jobParams(u, "j")
+	p.Auth = []byte(`{"type":"basic","username":"u"}`)
+	p.AuthSecret = []byte("sealed")
+	j, err := q.CreateJob(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(j.AuthSecret) != "sealed" {
+		t.Fatalf("auth secret = %q", j.AuthSecret)
+	}
+
+	// Without auth, the secret is kept whatever auth_secret says
+	got, err := q.UpdateJob(ctx, db.UpdateJobParams{ID: j.ID, AuthSecret: []byte("ignored")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.AuthSecret) != "sealed" {
+		t.Errorf("auth secret = %q, want it kept", got.AuthSecret)
+	}
+	got, err = q.UpdateJob(ctx, db.UpdateJobParams{ID: j.ID, Auth: []byte(`{"type":"basic","username":"v"}`), AuthSecret: []byte("new")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.AuthSecret) != "new" {
+		t.Errorf("auth secret = %q, want it replaced", got.AuthSecret)
+	}
+	// With auth, a NULL secret clears it
+	got, err = q.UpdateJob(ctx, db.UpdateJobParams{ID: j.ID, Auth: []byte(`{}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AuthSecret != nil {
+		t.Errorf("auth secret = %q, want it cleared", got.AuthSecret)
+	}
+	sameJSON(t, "auth", got.Auth, `{}`)
+}
+
+func testJobLists(t *testing.T, q db.Querier) {
+	u := user(t, q, "a@example.com")
+	other := user(t, q, "b@example.com")
+	var ids []pgtype.UUID // oldest first
+	for i, name := range []string{"c", "a", "b", "a"} {
+		p := jobParams(u, name)
+		p.Active = i%2 == 0
+		ids = append(ids, job(t, q, p).ID)
+		tick()
+	}
+	p := jobParams(other, "z")
+	p.Active = true
+	otherJob := job(t, q, p)
+
+	jobs, err := q.ListJobsByUser(ctx, db.ListJobsByUserParams{UserID: u.ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	same(t, "ListJobsByUser", jobIDs(jobs), []pgtype.UUID{ids[3], ids[2], ids[1], ids[0]})
+	jobs, err = q.ListJobsByUser(ctx, db.ListJobsByUserParams{UserID: u.ID, Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	same(t, "ListJobsByUser page", jobIDs(jobs), []pgtype.UUID{ids[2], ids[1]})
+	jobs, err = q.ListJobsByUser(ctx, db.ListJobsByUserParams{UserID: u.ID, Limit: 10, Offset: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs == nil || len(jobs) != 0 {
+		t.Errorf("ListJobsByUser past the end = %v, want an empty slice", jobs)
+	}
+
+	// By name, then oldest first
+	jobs, err = q.ListAllJobsByUser(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same(t, "ListAllJobsByUser", jobIDs(jobs), []pgtype.UUID{ids[1], ids[3], ids[2], ids[0]})
+
+	jobs, err = q.ListActiveJobs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same(t, "ListActiveJobs", jobIDs(jobs), []pgtype.UUID{otherJob.ID, ids[2], ids[0]})
+}
+
+func testJobSetters(t *testing.T, q db.Querier) {
+	u := user(t, q, "a@example.com")
+	j := job(t, q, jobParams(u, "j"))
+
+	tick()
+	got, err := q.SetJobSigningSecret(ctx, db.SetJobSigningSecretParams{ID: j.ID, SigningSecret: []byte("sealed")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.SigningSecret) != "sealed" || !got.UpdatedAt.Time.After(j.UpdatedAt.Time) {
+		t.Errorf("job after SetJobSigningSecret = %+v", got)
+	}
+	got, err = q.SetJobSigningSecret(ctx, db.SetJobSigningSecretParams{ID: j.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SigningSecret != nil {
+		t.Errorf("signing secret = %q, want it cleared", got.SigningSecret)
+	}
+
+	got, err = q.SetJobTriggers(ctx, db.SetJobTriggersParams{ID: j.ID, Triggers: []byte(`{"on_failure":["x"]}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameJSON(t, "triggers", got.Triggers, `{"on_failure":["x"]}`)
+	if got, err = q.GetJob(ctx, j.ID); err != nil {
+		t.Fatal(err)
+	}
+	sameJSON(t, "stored triggers", got.Triggers, `{"on_failure":["x"]}`)
+}
+
+func testJobLogs(t *testing.T, q db.Querier) {
+	u := user(t, q, "a@example.com")
+	j := job(t, q, jobParams(u, "j"))
+	other := job(t, q, jobParams(u, "k"))
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	p := logParams(j.ID, start, "failure")
+	p.FinishedAt = timestamptz(start.Add(1500 * time.Microsecond))
+	p.DurationMs = pgtype.Int4{Int32: 1, Valid: true}
+	p.ResponseCode = pgtype.Int4{Int32: 502, Valid: true}
+	p.Error = text("bad gateway")
+	p.ResponseBody = text("<html>")
+	p.DnsMs = pgtype.Int4{Int32: 0, Valid: true}
+	p.TtfbMs = pgtype.Int4{Int32: 1, Valid: true}
+	p.Redirects = []byte(`[{"url":"http://a","status":301}]`)
+	p.RunGroup = text("group")
+	first, err := q.InsertJobLog(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.ID.Valid || first.JobID != j.ID {
+		t.Errorf("inserted log = %+v", first)
+	}
+	var ids []pgtype.UUID
+	for i := 1; i < 4; i++ {
+		ids = append(ids, log(t, q, j.ID, start.Add(time.Duration(i)*time.Second)).ID)
+	}
+	log(t, q, other.ID, start.Add(time.Hour))
+
+	logs, err := q.ListJobLogs(ctx, db.ListJobLogsParams{JobID: j.ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	same(t, "ListJobLogs", logIDs(logs), []pgtype.UUID{ids[2], ids[1], ids[0], first.ID})
+	same(t, "stored log", normLog(logs[3]), normLog(first))
+	if logs, err = q.ListJobLogs(ctx, db.ListJobLogsParams{JobID: j.ID, Limit: 2, Offset: 1}); err != nil {
+		t.Fatal(err)
+	}
+	same(t, "ListJobLogs page", logIDs(logs), []pgtype.UUID{ids[1], ids[0]})
+	recent, err := q.ListRecentJobLogs(ctx, db.ListRecentJobLogsParams{JobID: j.ID, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	same(t, "ListRecentJobLogs", logIDs(recent), []pgtype.UUID{ids[2], ids[1]})
+}
+
+func testLogPruning(t *testing.T, q db.Querier) {
+	u := user(t, q, "a@example.com")
+	j := job(t, q, jobParams(u, "j"))
+	k := job(t, q, jobParams(u, "k"))
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		log(t, q, j.ID, start.Add(time.Duration(i)*time.Second))
+		log(t, q, k.ID, start.Add(time.Duration(i)*time.Second))
+	}
+	// Logs sharing the cutoff's start time are kept
+	log(t, q, j.ID, start.Add(3*time.Second))
+
+	if err := q.DeleteOldJobLogs(ctx, db.DeleteOldJobLogsParams{JobID: j.ID, Keep: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if n := countLogs(t, q, j.ID); n != 3 {
+		t.Errorf("%d logs after keeping 2, want 3 with the tie", n)
+	}
+	if n := countLogs(t, q, k.ID); n != 5 {
+		t.Errorf("pruning one job left %d logs of another, want 5", n)
+	}
+	// Keeping more than there are deletes nothing
+	if err := q.DeleteOldJobLogs(ctx, db.DeleteOldJobLogsParams{JobID: k.ID, Keep: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if n := countLogs(t, q, k.ID); n != 5 {
+		t.Errorf("%d logs after keeping 10, want 5", n)
+	}
+
+	if err := q.CleanupAllOldLogs(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []pgtype.UUID{j.ID, k.ID} {
+		logs, err := q.ListJobLogs(ctx, db.ListJobLogsParams{JobID: id, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(logs) != 2 || !logs[0].StartedAt.Time.Equal(start.Add(4*time.Second)) {
+			t.Errorf("after CleanupAllOldLogs: %d logs, want the newest 2", len(logs))
+		}
+	}
+}
+
+func testNegativePages(t *testing.T, q db.Querier) {
+	u := user(t, q, "a@example.com")
+	_, err := q.ListJobsByUser(ctx, db.ListJobsByUserParams{UserID: u.ID, Limit: -1})
+	wantPgError(t, "negative limit", err, "2201W", "")
+	_, err = q.ListJobLogs(ctx, db.ListJobLogsParams{JobID: u.ID, Limit: 10, Offset: -1})
+	wantPgError(t, "negative offset", err, "2201X", "")
+}
+
+func testCascades(t *testing.T, q db.Querier) {
+	u := user(t, q, "a@example.com")
+	other := user(t, q, "b@example.com")
+	cert := certificate(t, q, u, "c")
+	p := jobParams(u, "j")
+	p.ClientCertificateID = cert.ID
+	j := job(t, q, p)
+	k := job(t, q, jobParams(u, "k"))
+	kept := job(t, q, jobParams(other, "kept"))
+	for _, id := range []pgtype.UUID{j.ID, k.ID, kept.ID} {
+		log(t, q, id, time.Now())
+	}
+
+	if err := q.DeleteJob(ctx, k.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := countLogs(t, q, k.ID); n != 0 {
+		t.Errorf("DeleteJob left %d logs", n)
+	}
+
+	// The certificate is in use, so the user goes with all they own at once
+	if err := q.DeleteUser(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.GetJob(ctx, j.ID); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetJob of a deleted user's job = %v", err)
+	}
+	if n := countLogs(t, q, j.ID); n != 0 {
+		t.Errorf("DeleteUser left %d logs", n)
+	}
+	if _, err := q.GetClientCertificate(ctx, db.GetClientCertificateParams{ID: cert.ID, UserID: u.ID}); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetClientCertificate of a deleted user = %v", err)
+	}
+	if n := countLogs(t, q, kept.ID); n != 1 {
+		t.Errorf("another user's job has %d logs, want 1", n)
+	}
+}
+
+func testCertificates(t *testing.T, q db.Querier) {
+	u := user(t, q, "a@example.com")
+	other := user(t, q, "b@example.com")
+	b := certificate(t, q, u, "b")
+	tick()
+	a := certificate(t, q, u, "a")
+	certificate(t, q, other, "a")
+	if !a.ID.Valid || !a.CreatedAt.Valid || string(a.PrivateKey) != "sealed key" || a.CaBundle.Valid {
+		t.Errorf("created certificate = %+v", a)
+	}
+
+	got, err := q.GetClientCertificate(ctx, db.GetClientCertificateParams{ID: a.ID, UserID: u.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	same(t, "certificate", normCert(got), normCert(a))
+	if _, err := q.GetClientCertificate(ctx, db.GetClientCertificateParams{ID: a.ID, UserID: other.ID}); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetClientCertificate of another user = %v, want pgx.ErrNoRows", err)
+	}
+
+	certs, err := q.ListClientCertificatesByUser(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 2 || certs[0].ID != a.ID || certs[1].ID != b.ID {
+		t.Errorf("ListClientCertificatesByUser = %+v, want a then b", certs)
+	}
+
+	_, err = q.CreateClientCertificate(ctx, certParams(u, "a"))
+	wantPgError(t, "duplicate certificate name", err, "23505", "client_certificates_user_id_name_key")
+
+	// Another user's id deletes nothing
+	if err := q.DeleteClientCertificate(ctx, db.DeleteClientCertificateParams{ID: a.ID, UserID: other.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.GetClientCertificate(ctx, db.GetClientCertificateParams{ID: a.ID, UserID: u.ID}); err != nil {
+		t.Errorf("certificate deleted by another user: %v", err)
+	}
+
+	p := jobParams(u, "j")
+	p.ClientCertificateID = a.ID
+	j := job(t, q, p)
+	err = q.DeleteClientCertificate(ctx, db.DeleteClientCertificateParams{ID: a.ID, UserID: u.ID})
+	wantPgError(t, "deleting a certificate in use", err, "23503", "")
+	if err := q.DeleteJob(ctx, j.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.DeleteClientCertificate(ctx, db.DeleteClientCertificateParams{ID: a.ID, UserID: u.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.GetClientCertificate(ctx, db.GetClientCertificateParams{ID: a.ID, UserID: u.ID}); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetClientCertificate after delete = %v", err)
+	}
+}
+
+func testTransactions(t *testing.T, q db.Querier) {
+	tx, ok := q.(transactor)
+	if !ok {
+		t.Skip("the store has no transactions")
+	}
+	u := user(t, q, "a@example.com")
+
+	failed := errors.New("failed")
+	var created db.Job
+	err := tx.InTx(ctx, func(q db.Querier) error {
+		var err error
+		if created, err = q.CreateJob(ctx, jobParams(u, "rolled back")); err != nil {
+			return err
+		}
+		if _, err := q.GetJob(ctx, created.ID); err != nil {
+			t.Errorf("the transaction cannot read its own write: %v", err)
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("InTx = %v, want fn's error", err)
+	}
+	if _, err := q.GetJob(ctx, created.ID); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetJob after rollback = %v, want pgx.ErrNoRows", err)
+	}
+
+	err = tx.InTx(ctx, func(q db.Querier) error {
+		created, err = q.CreateJob(ctx, jobParams(u, "committed"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.GetJob(ctx, created.ID); err != nil {
+		t.Errorf("GetJob after commit = %v", err)
+	}
+}
+
+// tick lets the clock move on, so rows created after it sort as newer.
+func tick() {
+	time.Sleep(2 * time.Millisecond)
+}
+
+func text(s string) pgtype.Text {
+	return pgtype.Text{String: s, Valid: true}
+}
+
+func timestamptz(t time.Time) pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: t, Valid: true}
+}
+
+// uuid returns an id made of b, which no store generates: they make
+// version 4 ids.
+func uuid(b byte) pgtype.UUID {
+	return pgtype.UUID{Bytes: [16]byte{b, b, b, b, b, b, b, b, b, b, b, b, b, b, b, b}, Valid: true}
+}
+
+func user(t *testing.T, q db.Querier, email string) db.User {
+	t.Helper()
+	u, err := q.CreateUser(ctx, db.CreateUserParams{Email: email, Provider: "google"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func jobParams(u db.User, name string) db.CreateJobParams {
+	return db.CreateJobParams{
+		UserID:   u.ID,
+		Name:     name,
+		Schedule: "@daily",
+		Endpoint: "https://example.com/hook",
+		Method:   "POST",
+		Headers:  []byte("{}"),
+	}
+}
+
+func job(t *testing.T, q db.Querier, p db.CreateJobParams) db.Job {
+	t.Helper()
+	j, err := q.CreateJob(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func logParams(jobID pgtype.UUID, start time.Time, status string) db.InsertJobLogParams {
+	return db.InsertJobLogParams{JobID: jobID, StartedAt: timestamptz(start), Status: status}
+}
+
+func log(t *testing.T, q db.Querier, jobID pgtype.UUID, start time.Time) db.JobLog {
+	t.Helper()
+	l, err := q.InsertJobLog(ctx, logParams(jobID, start, "success"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func countLogs(t *testing.T, q db.Querier, jobID pgtype.UUID) int {
+	t.Helper()
+	logs, err := q.ListJobLogs(ctx, db.ListJobLogsParams{JobID: jobID, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(logs)
+}
+
+func certParams(u db.User, name string) db.CreateClientCertificateParams {
+	return db.CreateClientCertificateParams{
+		UserID:      u.ID,
+		Name:        name,
+		Certificate: "-----BEGIN CERTIFICATE-----",
+		PrivateKey:  []byte("sealed key"),
+		Subject:     "CN=" + name,
+		Fingerprint: "00:11",
+		NotAfter:    timestamptz(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+}
+
+func certificate(t *testing.T, q db.Querier, u db.User, name string) db.ClientCertificate {
+	t.Helper()
+	c, err := q.CreateClientCertificate(ctx, certParams(u, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func wantPgError(t *testing.T, what string, err error, code, constraint string) {
+	t.Helper()
+	var pgErr *pgconn.PgError
+	switch {
+	case !errors.As(err, &pgErr):
+		t.Errorf("%s: error = %v, want a *pgconn.PgError with code %s", what, err, code)
+	case pgErr.Code != code:
+		t.Errorf("%s: code = %s, want %s (%v)", what, pgErr.Code, code, err)
+	case constraint != "" && pgErr.ConstraintName != constraint:
+		t.Errorf("%s: constraint = %q, want %q", what, pgErr.ConstraintName, constraint)
+	}
+}
+
+func same(t *testing.T, what string, got, want any) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %+v\nwant %+v", what, got, want)
+	}
+}
+
+// sameJSON compares JSON by value: Postgres rewrites jsonb with its own
+// spacing and key order.
+func sameJSON(t *testing.T, what string, got []byte, want string) {
+	t.Helper()
+	if !bytes.Equal(canonicalJSON(got), canonicalJSON([]byte(want))) {
+		t.Errorf("%s = %s, want %s", what, got, want)
+	}
+}
+
+func canonicalJSON(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return b
+	}
+	out, _ := json.Marshal(v)
+	return out
+}
+
+// The norm functions make rows of different stores comparable: JSON
+// by value, times in UTC and empty byte slices nil.
+
+func normTime(ts pgtype.Timestamptz) pgtype.Timestamptz {
+	if ts.Valid {
+		ts.Time = ts.Time.UTC()
+	}
+	return ts
+}
+
+func normBytes(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+func normUser(u db.User) db.User {
+	u.CreatedAt = normTime(u.CreatedAt)
+	u.UpdatedAt = normTime(u.UpdatedAt)
+	return u
+}
+
+func normJob(j db.Job) db.Job {
+	j.CreatedAt = normTime(j.CreatedAt)
+	j.UpdatedAt = normTime(j.UpdatedAt)
+	for _, b := range []*[]byte{&j.Headers, &j.Transport, &j.Auth, &j.Redirects, &j.Config, &j.Triggers} {
+		*b = canonicalJSON(*b)
+	}
+	j.AuthSecret = normBytes(j.AuthSecret)
+	j.SigningSecret = normBytes(j.SigningSecret)
+	return j
+}
+
+func normLog(l db.JobLog) db.JobLog {
+	l.StartedAt = normTime(l.StartedAt)
+	l.FinishedAt = normTime(l.FinishedAt)
+	l.Redirects = canonicalJSON(l.Redirects)
+	return l
+}
+
+func normCert(c db.ClientCertificate) db.ClientCertificate {
+	c.NotAfter = normTime(c.NotAfter)
+	c.CreatedAt = normTime(c.CreatedAt)
+	return c
+}
+
+func userIDs(users []db.User) []pgtype.UUID {
+	var ids []pgtype.UUID
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
+
+func jobIDs(jobs []db.Job) []pgtype.UUID {
+	var ids []pgtype.UUID
+	for _, j := range jobs {
+		ids = append(ids, j.ID)
+	}
+	return ids
+}
+
+func logIDs(logs []db.JobLog) []pgtype.UUID {
+	var ids []pgtype.UUID
+	for _, l := range logs {
+		ids = append(ids, l.ID)
+	}
+	return ids
+}
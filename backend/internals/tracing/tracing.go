@@ -0,0 +1,85 @@
+// Package tracing configures OpenTelemetry tracing.
+//
+// Export is enabled by setting OTEL_EXPORTER_OTLP_ENDPOINT (or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT); the remaining standard OTEL_* variables
+// such as OTEL_EXPORTER_OTLP_HEADERS and OTEL_SERVICE_NAME are honoured by the
+// SDK. Without an endpoint spans are still created and W3C trace context is
+// still propagated, but nothing is exported.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ServiceName = "cronix"
+	tracerName  = "cronix.ashutosh.net"
+)
+
+// Init installs the global tracer provider and propagator. The returned
+// function flushes and stops the exporter.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		tp := NewProvider(nil)
+		otel.SetTracerProvider(tp)
+		return tp.Shutdown, nil
+	}
+
+	exp, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tp := NewProvider(exp)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// NewProvider builds a tracer provider that batches spans to exp, or
+// records without exporting when exp is nil. Tests can pass a
+// tracetest.InMemoryExporter and install the result with otel.SetTracerProvider.
+func NewProvider(exp sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	res, _ := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName())))
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if exp != nil {
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	}
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+func serviceName() string {
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		return name
+	}
+	return ServiceName
+}
+
+// Tracer returns the tracer used for CroniX's own spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Transport wraps base so that every outbound request gets a client span,
+// child spans for DNS, connect, TLS and time to first byte, and a
+// traceparent header for the target.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base,
+		otelhttp.WithClientTrace(func(ctx context.Context) *httptrace.ClientTrace {
+			return otelhttptrace.NewClientTrace(ctx)
+		}),
+	)
+}
@@ -0,0 +1,114 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"cronix.ashutosh.net/internals/db"
+	"cronix.ashutosh.net/internals/netguard"
+	"cronix.ashutosh.net/internals/outbound"
+	"cronix.ashutosh.net/internals/services"
+	"cronix.ashutosh.net/internals/store/memory"
+	"cronix.ashutosh.net/internals/tracing"
+)
+
+// A scheduled run is traced as scheduler.fire > job.run > the HTTP client
+// span, and the target receives the client span as its traceparent.
+func TestScheduledRunTrace(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := tracing.NewProvider(exp)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	traceparent := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case traceparent <- r.Header.Get("traceparent"):
+		default:
+		}
+	}))
+	defer target.Close()
+
+	ctx := context.Background()
+	st := memory.New()
+	user, err := st.CreateUser(ctx, db.CreateUserParams{Email: "a@example.com", Provider: "google"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := st.CreateJob(ctx, db.CreateJobParams{
+		UserID: user.ID, Name: "traced", Schedule: "* * * * * *", Endpoint: target.URL,
+		Method: "POST", Headers: []byte("{}"), Active: true, Type: services.JobTypeHTTP,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard, err := netguard.New([]string{"127.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob, err := outbound.New(outbound.Options{}, guard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js := services.NewJobsService(st, services.JobsSettings{MaxResponseBytes: 1 << 20, LogsPerJob: 10, Outbound: ob})
+	sched := services.NewScheduler(js, 5*time.Second)
+	if err := sched.Start(ctx, []db.Job{job}); err != nil {
+		t.Fatal(err)
+	}
+
+	var header string
+	select {
+	case header = <-traceparent:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the job did not run")
+	}
+	stopCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := sched.Stop(stopCtx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tp.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exp.GetSpans()
+	byName := map[string]tracetest.SpanStub{}
+	for _, s := range spans {
+		if _, seen := byName[s.Name]; !seen {
+			byName[s.Name] = s
+		}
+	}
+	fire, ok := byName["scheduler.fire"]
+	if !ok {
+		t.Fatalf("no scheduler.fire span among %d spans", len(spans))
+	}
+	run, ok := byName["job.run"]
+	if !ok {
+		t.Fatal("no job.run span")
+	}
+	if run.Parent.SpanID() != fire.SpanContext.SpanID() {
+		t.Errorf("job.run parent = %s, want scheduler.fire %s", run.Parent.SpanID(), fire.SpanContext.SpanID())
+	}
+	var client *tracetest.SpanStub
+	for i, s := range spans {
+		if s.Parent.SpanID() == run.SpanContext.SpanID() && s.SpanKind.String() == "client" {
+			client = &spans[i]
+		}
+	}
+	if client == nil {
+		t.Fatal("no client span under job.run")
+	}
+
+	want := "00-" + fire.SpanContext.TraceID().String() + "-" + client.SpanContext.SpanID().String() + "-01"
+	if header != want {
+		t.Errorf("traceparent = %q, want %q", header, want)
+	}
+}
@@ -2,212 +2,234 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
 
 	"cronix.ashutosh.net/internals/config"
-	"cronix.ashutosh.net/internals/db"
-	"cronix.ashutosh.net/internals/handlers"
-	"cronix.ashutosh.net/internals/middleware"
+	"cronix.ashutosh.net/internals/logging"
+	"cronix.ashutosh.net/internals/metrics"
+	"cronix.ashutosh.net/internals/netguard"
+	"cronix.ashutosh.net/internals/openapi"
+	"cronix.ashutosh.net/internals/outbound"
+	"cronix.ashutosh.net/internals/router"
+	"cronix.ashutosh.net/internals/secretbox"
 	"cronix.ashutosh.net/internals/services"
+	"cronix.ashutosh.net/internals/tracing"
 )
 
-type DBConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	Name     string
-	SSLMode  string
-}
-
-func LoadDBconfig() (*DBConfig, error) {
-	if err := godotenv.Load(".env"); err != nil {
-		log.Println("NO .env file found ")
-	}
-
-	portStr := os.Getenv("DB_PORT")
-	if portStr == "" {
-		portStr = "5432" // default port
+func main() {
+	logging.Setup()
+	gin.DebugPrintFunc = func(format string, values ...interface{}) {
+		slog.Debug(strings.TrimSpace(fmt.Sprintf(format, values...)), "component", "gin")
 	}
-
-	cfg := &DBConfig{
-		Host:     os.Getenv("DB_HOST"),
-		Port:     portStr,
-		User:     os.Getenv("DB_USER"),
-		Password: os.Getenv("DB_PASSWORD"),
-		Name:     os.Getenv("DB_NAME"),
-		SSLMode:  os.Getenv("DB_SSLMODE"),
+	gin.DebugPrintRouteFunc = func(method, path, handler string, _ int) {
+		slog.Debug("route registered", "method", method, "path", path, "handler", handler)
 	}
 
-	return cfg, nil
-
-}
-
-func main() {
 	if os.Getenv("GIN_MODE") != "release" {
 		if err := godotenv.Load(".env"); err != nil {
-			log.Println("NO .env file found, relying on environment variables.")
+			slog.Info("no .env file found, relying on environment variables")
 		}
 	}
 
-	dsn := os.Getenv("DATABASE_URL")
-
-	if dsn == "" {
-		log.Println("DATABASE_URL not found, building from individual DB_* variables for local development.")
-		dsn = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-			os.Getenv("DB_USER"),
-			os.Getenv("DB_PASSWORD"),
-			os.Getenv("DB_HOST"),
-			os.Getenv("DB_PORT"),
-			os.Getenv("DB_NAME"),
-			os.Getenv("DB_SSLMODE"),
-		)
-	}
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to the YAML config file (env CONFIG_FILE, default "+config.DefaultPath+" if present)")
+	migrateMode := flag.String("migrate", "", "handle embedded migrations at startup: off, verify or up (overrides server.migrate_on_boot)")
+	flag.Parse()
 
-	pool, err := pgxpool.New(context.Background(), dsn)
+	cfg, err := config.Load(*configPath)
 	if err != nil {
-		panic(fmt.Sprintf("failed to connect to database: %v", err))
+		fatal("failed to load configuration", err)
+	}
+	if *migrateMode != "" {
+		cfg.Server.MigrateOnBoot = *migrateMode
 	}
-	defer pool.Close()
-
-	fmt.Println("Connected to the database successfully !!!")
-
-	queries := db.New(pool)
-	authConfig := config.LoadAuthConfig()
-	authService := services.NewAuthService(queries, authConfig.JWTSecret)
 
-	jobsService := services.NewJobsService(queries)
-	scheduler := services.NewScheduler(jobsService)
-	jobsHandler := handlers.NewJobsHandler(jobsService, scheduler)
+	if flag.Arg(0) == "migrate" {
+		if err := cfg.Database.Validate(); err != nil {
+			fatal("invalid configuration", err)
+		}
+		st, err := openStorage(context.Background(), &cfg.Database)
+		if err != nil {
+			fatal("failed to open database", err)
+		}
+		code := runMigrate(flag.Args()[1:], st.migrator)
+		st.Close()
+		os.Exit(code)
+	}
+	if err := cfg.Validate(); err != nil {
+		fatal("invalid configuration", err)
+	}
 
-	// After creating queries, jobsService, scheduler
-	activeJobs, err := queries.ListActiveJobs(context.Background())
+	shutdownTracing, err := tracing.Init(context.Background())
 	if err != nil {
-		log.Printf("failed to load active jobs: %v", err)
-	}
-	if err := scheduler.Start(context.Background(), activeJobs); err != nil {
-		log.Printf("failed to start scheduler: %v", err)
+		slog.Error("failed to set up tracing exporter", "error", err)
 	} else {
-		log.Printf("scheduler started with %d active jobs", len(activeJobs))
+		defer shutdownTracing(context.Background())
 	}
 
-	authHandler := handlers.NewAuthHandler(authService, authConfig)
+	st, err := openStorage(context.Background(), &cfg.Database)
+	if err != nil {
+		fatal("failed to open database", err)
+	}
+	defer st.Close()
 
-	r := gin.Default()
+	slog.Info("connected to the database", "driver", st.driver)
 
-	// Setup test routes for endpoint validation testing
-	SetupTestRoutes(r)
+	if err := migrateOnBoot(context.Background(), st, cfg.Server.MigrateOnBoot); err != nil {
+		fatal("database migrations", err)
+	}
 
-	// Configure CORS (supports comma-separated ALLOWED_ORIGINS env and FRONTEND_URL)
-	corsCfg := cors.DefaultConfig()
-	allowedOrigins := []string{}
-	if envOrigins := os.Getenv("ALLOWED_ORIGINS"); envOrigins != "" {
-		for _, origin := range strings.Split(envOrigins, ",") {
-			if trimmed := strings.TrimSpace(origin); trimmed != "" {
-				allowedOrigins = append(allowedOrigins, trimmed)
-			}
-		}
+	guard, err := netguard.New(cfg.HTTPClient.AllowedNetworks)
+	if err != nil {
+		fatal("invalid configuration", err)
 	}
-	if fe := os.Getenv("FRONTEND_URL"); fe != "" {
-		allowedOrigins = append(allowedOrigins, fe)
+	outboundFactory, err := outbound.New(outbound.Options{
+		MaxIdleConns:        cfg.HTTPClient.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.HTTPClient.MaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.HTTPClient.IdleConnTimeout,
+		HTTP2:               cfg.HTTPClient.HTTP2,
+		Proxy:               cfg.HTTPClient.Proxy,
+		NoProxy:             cfg.HTTPClient.NoProxy,
+		CAFile:              cfg.HTTPClient.CAFile,
+		TLSMinVersion:       cfg.HTTPClient.TLSMinVersion,
+		MaxTransports:       cfg.HTTPClient.MaxTransports,
+	}, guard)
+	if err != nil {
+		fatal("invalid configuration", err)
 	}
 
-	// Ensure we have at least one valid origin
-	if len(allowedOrigins) == 0 {
-		allowedOrigins = []string{"http://localhost:5173"}
+	var box *secretbox.Box
+	if cfg.Secrets.Key != "" {
+		key, _ := secretbox.ParseKey(cfg.Secrets.Key) // checked by Validate
+		if box, err = secretbox.New(key); err != nil {
+			fatal("invalid configuration", err)
+		}
 	}
 
-	corsCfg.AllowOrigins = allowedOrigins
-	corsCfg.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	corsCfg.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"}
-	corsCfg.AllowCredentials = true
-	r.Use(cors.New(corsCfg))
-
-	r.GET("/auth/google", authHandler.Login)
-	r.GET("/auth/google/callback", authHandler.Callback)
-	r.POST("/auth/logout", authHandler.Logout)
-
-	// Health/readiness
-	r.GET("/healthz", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
-
-	// Lightweight ping endpoint for uptime checks
-	r.GET("/ping", func(c *gin.Context) {
-		c.String(200, "pong")
-	})
+	authService := services.NewAuthService(st.repo, cfg.Auth.JWTSecret)
+	certsService := services.NewCertificatesService(st.repo, box, outboundFactory)
 
-	// Test routes (no auth required for testing)
-	r.GET("/test", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"message":   "Backend is working!",
-			"timestamp": "2024-01-01T00:00:00Z",
-			"status":    "success",
-		})
+	jobsService := services.NewJobsService(st.repo, services.JobsSettings{
+		TestTimeout:      cfg.HTTPClient.Timeout,
+		MaxResponseBytes: cfg.HTTPClient.MaxResponseBytes,
+		LogsPerJob:       cfg.Retention.LogsPerJob,
+		Outbound:         outboundFactory,
+		Certificates:     certsService,
+		Secrets:          box,
 	})
+	scheduler := services.NewScheduler(jobsService, cfg.Scheduler.RunTimeout)
 
-	// Webhook test endpoint that jobs can hit
-	r.POST("/webhook/test", func(c *gin.Context) {
-		var body map[string]interface{}
-		if err := c.ShouldBindJSON(&body); err != nil {
-			c.JSON(400, gin.H{"error": "Invalid JSON"})
-			return
-		}
-
-		c.JSON(200, gin.H{
-			"message":       "Webhook received successfully!",
-			"received_data": body,
-			"timestamp":     "2024-01-01T00:00:00Z",
-			"status":        "success",
-		})
-	})
+	// After creating the services and scheduler
+	activeJobs, err := st.repo.ListActiveJobs(context.Background())
+	if err != nil {
+		slog.Error("failed to load active jobs", "error", err)
+	}
+	if err := scheduler.Start(context.Background(), activeJobs); err != nil {
+		slog.Error("failed to start scheduler", "error", err)
+	} else {
+		slog.Info("scheduler started", "active_jobs", len(activeJobs))
+	}
 
-	// Simple GET webhook test
-	r.GET("/webhook/test", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"message":   "GET webhook test successful!",
-			"timestamp": "2024-01-01T00:00:00Z",
-			"status":    "success",
-		})
+	// Prometheus metrics of the pool and scheduler
+	if st.pool != nil {
+		metrics.RegisterDBPool(st.pool)
+	}
+	metrics.RegisterScheduler(scheduler.Len)
+
+	r := router.New(router.Deps{
+		Auth:           authService,
+		AuthConfig:     &cfg.Auth,
+		AuthHTTPClient: &http.Client{Transport: outboundFactory.System()},
+		Jobs:           jobsService,
+		Certificates:   certsService,
+		Scheduler:      scheduler,
+		DB:             st.sqlDB,
+		Driver:         st.driver,
+		AllowedOrigins: cfg.AllowedOrigins(),
+		MetricsToken:   cfg.Metrics.Token,
+		// Endpoint test routes for trying out jobs
+		Extra: SetupTestRoutes,
 	})
 
-	api := r.Group("/api")
-	api.Use(middleware.AuthMiddleware(authService))
-	{
-		api.GET("/profile", authHandler.GetProfile)
-		api.POST("/jobs", jobsHandler.Create)
-		api.GET("/jobs", jobsHandler.List)
-		api.GET("/jobs/:id", jobsHandler.Get)
-		api.PUT("/jobs/:id", jobsHandler.Update)
-		api.DELETE("/jobs/:id", jobsHandler.Delete)
-		api.POST("/jobs/:id/run", jobsHandler.RunNow)
-		api.GET("/jobs/:id/logs", jobsHandler.ListLogs)
-		api.POST("/jobs/test", jobsHandler.TestEndpoint)
-		api.POST("/jobs/cleanup-logs", jobsHandler.CleanupAllLogs)
+	// Keep openapi.yaml honest: every route registered above must be described there
+	if missing := openapi.Undocumented(r.Routes()); len(missing) > 0 {
+		slog.Warn("routes missing from the OpenAPI document", "routes", missing)
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Requests derive from baseCtx so that manual runs still going when the
+	// drain period ends are aborted the same way as scheduled ones
+	baseCtx, abort := context.WithCancelCause(context.Background())
+	srv := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+		ErrorLog:          logging.StdLogger(slog.LevelWarn),
 	}
 
-	log.Printf("Server starting on port %s", port)
+	slog.Info("server starting", "port", cfg.Server.Port)
+	serve(srv, scheduler, cfg.Server.ShutdownDrainPeriod, abort)
+}
 
-	// Graceful shutdown
-	defer func() {
-		log.Println("Stopping scheduler...")
-		scheduler.Stop()
+// serve runs srv until SIGINT or SIGTERM, then drains: the scheduler stops
+// firing and the listener closes while in-flight runs and requests get up
+// to drain to finish. Whatever is left after that is aborted through abort
+// and recorded as such.
+func serve(srv *http.Server, scheduler *services.Scheduler, drain time.Duration, abort context.CancelCauseFunc) {
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-serveErr:
+		slog.Error("server stopped", "error", err)
+	case <-sigCtx.Done():
+	}
+	stop()
+
+	slog.Info("shutting down", "drain_period", drain.String())
+	drainCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := scheduler.Stop(drainCtx); err != nil {
+			slog.Warn("scheduled runs aborted at end of drain period")
+		}
 	}()
 
-	r.Run(":" + port)
+	if err := srv.Shutdown(drainCtx); err != nil {
+		slog.Warn("requests still running at end of drain period, aborting them")
+		abort(services.ErrAborted)
+		graceCtx, cancelGrace := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := srv.Shutdown(graceCtx); err != nil {
+			_ = srv.Close()
+		}
+		cancelGrace()
+	}
+	wg.Wait()
+	slog.Info("shutdown complete")
+}
 
+// fatal logs err and exits; used for startup failures the server cannot run without.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"cronix.ashutosh.net/internals/openapi"
+	"cronix.ashutosh.net/internals/router"
+)
+
+// Every route the server registers must be described in openapi.yaml.
+func TestRoutesDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := router.New(router.Deps{
+		AllowedOrigins: []string{"http://localhost:3000"},
+		Extra:          SetupTestRoutes,
+	})
+	if missing := openapi.Undocumented(r.Routes()); len(missing) > 0 {
+		t.Errorf("routes missing from the OpenAPI document: %v", missing)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pressly/goose/v3"
+
+	"cronix.ashutosh.net/database"
+)
+
+const migrateUsage = `usage: cronix migrate <command>
+
+commands:
+  up      apply all pending migrations
+  down    roll back the most recent migration
+  status  list migrations and whether they are applied
+`
+
+// runMigrate implements the migrate subcommand and returns the exit code.
+func runMigrate(args []string, m *goose.Provider) int {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		results, err := m.Up(ctx)
+		for _, r := range results {
+			fmt.Println(r)
+		}
+		if err != nil {
+			slog.Error("migrate up", "error", err)
+			return 1
+		}
+		if len(results) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		r, err := m.Down(ctx)
+		if r != nil {
+			fmt.Println(r)
+		}
+		if err != nil {
+			slog.Error("migrate down", "error", err)
+			return 1
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			slog.Error("migrate status", "error", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+		for _, s := range statuses {
+			applied := "-"
+			if !s.AppliedAt.IsZero() {
+				applied = s.AppliedAt.Local().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, applied, s.Source.Path)
+		}
+		w.Flush()
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
+
+// migrateOnBoot applies or verifies the embedded migrations according to
+// mode: "up", "verify" or "off".
+func migrateOnBoot(ctx context.Context, st *storage, mode string) error {
+	m := st.migrator
+	switch mode {
+	case "off":
+		return nil
+	case "up":
+		results, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		slog.Info("migrations applied", "count", len(results), "version", database.LatestVersion(st.driver))
+		return nil
+	case "verify":
+		return database.Verify(ctx, m)
+	}
+	return fmt.Errorf("unknown migrate mode %q, want off, verify or up", mode)
+}
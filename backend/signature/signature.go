@@ -0,0 +1,142 @@
+// Package signature signs the requests CroniX sends to job endpoints and
+// lets receivers verify them. See SIGNATURES.md for the scheme.
+//
+// A receiver checks an incoming request with VerifyRequest:
+//
+//	func hook(w http.ResponseWriter, r *http.Request) {
+//		if err := signature.VerifyRequest(r, secret, signature.DefaultTolerance); err != nil {
+//			http.Error(w, "bad signature", http.StatusUnauthorized)
+//			return
+//		}
+//		// r.Body can still be read
+//	}
+package signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header carries the signature of a request.
+const Header = "X-Cronix-Signature"
+
+// SecretPrefix starts every secret generated by NewSecret.
+const SecretPrefix = "cxsig_"
+
+// DefaultTolerance is how far a signature timestamp may be from the
+// receiver's clock.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrMissing   = errors.New("signature: header missing")
+	ErrMalformed = errors.New("signature: header malformed")
+	ErrTimestamp = errors.New("signature: timestamp outside tolerance")
+	ErrMismatch  = errors.New("signature: no matching signature")
+)
+
+// NewSecret returns a random signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return SecretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Payload returns the bytes that are signed: the timestamp, method,
+// request URI and body joined by newlines.
+func Payload(t int64, method, requestURI string, body []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(strconv.FormatInt(t, 10))
+	b.WriteByte('\n')
+	b.WriteString(strings.ToUpper(method))
+	b.WriteByte('\n')
+	b.WriteString(requestURI)
+	b.WriteByte('\n')
+	b.Write(body)
+	return b.Bytes()
+}
+
+// Compute returns the hex HMAC-SHA256 of Payload keyed with secret, as
+// used for v1.
+func Compute(secret string, t int64, method, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(Payload(t, method, requestURI, body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns the Header value for a request sent at t.
+func Sign(secret string, t time.Time, method, requestURI string, body []byte) string {
+	ts := t.Unix()
+	return "t=" + strconv.FormatInt(ts, 10) + ",v1=" + Compute(secret, ts, method, requestURI, body)
+}
+
+// Verify checks a Header value against the request it came with. Any v1
+// signature may match, and the timestamp must be within tolerance of now.
+func Verify(header, secret, method, requestURI string, body []byte, tolerance time.Duration, now time.Time) error {
+	if header == "" {
+		return ErrMissing
+	}
+	var ts int64
+	var sigs []string
+	haveTS := false
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrMalformed
+		}
+		switch k {
+		case "t":
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return ErrMalformed
+			}
+			ts, haveTS = n, true
+		case "v1":
+			sigs = append(sigs, v)
+		}
+		// unknown schemes are skipped so new ones can be added
+	}
+	if !haveTS || len(sigs) == 0 {
+		return ErrMalformed
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return ErrTimestamp
+	}
+	want := []byte(Compute(secret, ts, method, requestURI, body))
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), want) {
+			return nil
+		}
+	}
+	return ErrMismatch
+}
+
+// VerifyRequest verifies r as received by a server. The body is read in
+// full and replaced, so the handler can still read it.
+func VerifyRequest(r *http.Request, secret string, tolerance time.Duration) error {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return err
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	uri := r.RequestURI // exactly as sent; unset on client requests
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+	return Verify(r.Header.Get(Header), secret, r.Method, uri, body, tolerance, time.Now())
+}
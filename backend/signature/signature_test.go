@@ -0,0 +1,160 @@
+package signature_test
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"cronix.ashutosh.net/signature"
+)
+
+type vector struct {
+	t          int64
+	method     string
+	requestURI string
+	body       string
+	v1         string
+}
+
+var vectorSecret = regexp.MustCompile("All vectors use the secret `([^`]+)`")
+
+// readVectors returns the secret and test vectors of SIGNATURES.md, so the
+// document cannot drift from the code.
+func readVectors(t *testing.T) (string, []vector) {
+	t.Helper()
+	b, err := os.ReadFile("../SIGNATURES.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := string(b)
+	m := vectorSecret.FindStringSubmatch(doc)
+	if m == nil {
+		t.Fatal("no vector secret in SIGNATURES.md")
+	}
+	var vectors []vector
+	_, table, _ := strings.Cut(doc, "## Test Vectors")
+	for _, line := range strings.Split(table, "\n") {
+		cells := strings.Split(line, "|")
+		if len(cells) != 7 {
+			continue
+		}
+		ts, err := strconv.ParseInt(strings.TrimSpace(cells[1]), 10, 64)
+		if err != nil {
+			continue // header or separator
+		}
+		body := strings.TrimSpace(cells[4])
+		if body == "(empty)" {
+			body = ""
+		}
+		vectors = append(vectors, vector{
+			t:          ts,
+			method:     strings.TrimSpace(cells[2]),
+			requestURI: strings.Trim(strings.TrimSpace(cells[3]), "`"),
+			body:       strings.Trim(body, "`"),
+			v1:         strings.Trim(strings.TrimSpace(cells[5]), "`"),
+		})
+	}
+	if len(vectors) == 0 {
+		t.Fatal("no test vectors in SIGNATURES.md")
+	}
+	return m[1], vectors
+}
+
+func TestVectors(t *testing.T) {
+	secret, vectors := readVectors(t)
+	for _, v := range vectors {
+		at := time.Unix(v.t, 0)
+		header := signature.Sign(secret, at, v.method, v.requestURI, []byte(v.body))
+		want := "t=" + strconv.FormatInt(v.t, 10) + ",v1=" + v.v1
+		if header != want {
+			t.Errorf("Sign(%s %s) = %s, want %s", v.method, v.requestURI, header, want)
+		}
+		if err := signature.Verify(want, secret, v.method, v.requestURI, []byte(v.body), signature.DefaultTolerance, at); err != nil {
+			t.Errorf("Verify(%s %s) = %v", v.method, v.requestURI, err)
+		}
+	}
+
+	// The first vector's payload is spelled out in the document
+	first := vectors[0]
+	payload := signature.Payload(first.t, first.method, first.requestURI, []byte(first.body))
+	if want := "1760000000\nPOST\n/hooks/refresh\n{\"cache\":\"all\"}"; string(payload) != want || len(payload) != 46 {
+		t.Errorf("payload = %q (%d bytes), want %q (46 bytes)", payload, len(payload), want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	const secret = "cxsig_test"
+	now := time.Unix(1760000000, 0)
+	body := []byte(`{"a":1}`)
+	valid := signature.Sign(secret, now, "POST", "/hook?x=1", body)
+	_, sig, _ := strings.Cut(valid, ",")
+
+	tests := []struct {
+		name   string
+		header string
+		secret string
+		method string
+		uri    string
+		body   []byte
+		now    time.Time
+		want   error
+	}{
+		{"valid", valid, secret, "POST", "/hook?x=1", body, now, nil},
+		{"method in any case", valid, secret, "post", "/hook?x=1", body, now, nil},
+		{"within tolerance", valid, secret, "POST", "/hook?x=1", body, now.Add(5 * time.Minute), nil},
+		{"too old", valid, secret, "POST", "/hook?x=1", body, now.Add(5*time.Minute + time.Second), signature.ErrTimestamp},
+		{"from the future", valid, secret, "POST", "/hook?x=1", body, now.Add(-6 * time.Minute), signature.ErrTimestamp},
+		{"other secret", valid, "cxsig_other", "POST", "/hook?x=1", body, now, signature.ErrMismatch},
+		{"other method", valid, secret, "PUT", "/hook?x=1", body, now, signature.ErrMismatch},
+		{"other query", valid, secret, "POST", "/hook?x=2", body, now, signature.ErrMismatch},
+		{"other body", valid, secret, "POST", "/hook?x=1", []byte(`{"a":2}`), now, signature.ErrMismatch},
+		{"any v1 may match", valid + ",v1=00" + ",v2=ff", secret, "POST", "/hook?x=1", body, now, nil},
+		{"unknown schemes are skipped", "t=1760000000,v0=00," + sig, secret, "POST", "/hook?x=1", body, now, nil},
+		{"missing", "", secret, "POST", "/hook?x=1", body, now, signature.ErrMissing},
+		{"no timestamp", sig, secret, "POST", "/hook?x=1", body, now, signature.ErrMalformed},
+		{"no signature", "t=1760000000", secret, "POST", "/hook?x=1", body, now, signature.ErrMalformed},
+		{"bad timestamp", "t=soon," + sig, secret, "POST", "/hook?x=1", body, now, signature.ErrMalformed},
+		{"no equals sign", "t=1760000000,v1", secret, "POST", "/hook?x=1", body, now, signature.ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := signature.Verify(tt.header, tt.secret, tt.method, tt.uri, tt.body, signature.DefaultTolerance, tt.now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	secret, err := signature.NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, signature.SecretPrefix) {
+		t.Errorf("secret %q lacks the prefix", secret)
+	}
+
+	r := httptest.NewRequest("POST", "/hooks/refresh?full=1", strings.NewReader(`{"cache":"all"}`))
+	r.Header.Set(signature.Header, signature.Sign(secret, time.Now(), "POST", "/hooks/refresh?full=1", []byte(`{"cache":"all"}`)))
+	if err := signature.VerifyRequest(r, secret, signature.DefaultTolerance); err != nil {
+		t.Fatal(err)
+	}
+	// The body can still be read
+	body, err := io.ReadAll(r.Body)
+	if err != nil || string(body) != `{"cache":"all"}` {
+		t.Errorf("body after verifying = %q, %v", body, err)
+	}
+
+	r = httptest.NewRequest("POST", "/hooks/refresh?full=1", strings.NewReader(`{"cache":"none"}`))
+	r.Header.Set(signature.Header, signature.Sign(secret, time.Now(), "POST", "/hooks/refresh?full=1", []byte(`{"cache":"all"}`)))
+	if err := signature.VerifyRequest(r, secret, signature.DefaultTolerance); !errors.Is(err, signature.ErrMismatch) {
+		t.Errorf("VerifyRequest of a changed body = %v, want ErrMismatch", err)
+	}
+}
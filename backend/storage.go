@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/exaring/otelpgx"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+
+	"cronix.ashutosh.net/database"
+	"cronix.ashutosh.net/internals/config"
+	"cronix.ashutosh.net/internals/services"
+	"cronix.ashutosh.net/internals/store/postgres"
+	"cronix.ashutosh.net/internals/store/sqlite"
+)
+
+// storage is the database the server runs on, whichever driver it uses.
+type storage struct {
+	driver   string
+	repo     services.Repository
+	sqlDB    *sql.DB // for migrations and health checks
+	migrator *goose.Provider
+	pool     *pgxpool.Pool // nil unless driver is postgres
+}
+
+// openStorage opens the database selected by cfg.Driver. Connections are
+// made lazily, so it succeeds while the database is still unreachable.
+func openStorage(ctx context.Context, cfg *config.DatabaseConfig) (*storage, error) {
+	st := &storage{driver: cfg.Driver}
+	switch cfg.Driver {
+	case database.Postgres:
+		poolCfg, err := pgxpool.ParseConfig(cfg.DSN())
+		if err != nil {
+			return nil, fmt.Errorf("invalid database configuration: %w", err)
+		}
+		if cfg.MaxConns > 0 {
+			poolCfg.MaxConns = cfg.MaxConns
+		}
+		poolCfg.ConnConfig.Tracer = otelpgx.NewTracer()
+		if st.pool, err = pgxpool.NewWithConfig(ctx, poolCfg); err != nil {
+			return nil, err
+		}
+		st.repo = postgres.New(st.pool)
+		st.sqlDB = stdlib.OpenDBFromPool(st.pool)
+	case database.SQLite:
+		sqlDB, err := sqlite.Open(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		st.repo = sqlite.New(sqlDB)
+		st.sqlDB = sqlDB
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+	}
+
+	m, err := database.NewMigrator(st.sqlDB, cfg.Driver)
+	if err != nil {
+		st.Close()
+		return nil, fmt.Errorf("load migrations: %w", err)
+	}
+	st.migrator = m
+	return st, nil
+}
+
+func (st *storage) Close() {
+	_ = st.sqlDB.Close()
+	if st.pool != nil {
+		st.pool.Close()
+	}
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -196,10 +196,11 @@ func SetupTestRoutes(r *gin.Engine) {
 		testRoutes.DELETE("/echo", testHandler.Echo)
 	}
 
-	log.Println("Test routes registered:")
-	log.Println("  GET/POST/PUT/DELETE /test-routes/success - Returns 200 OK")
-	log.Println("  GET/POST /test-routes/error - Returns 500 Internal Server Error")
-	log.Println("  GET/POST /test-routes/notfound - Returns 404 Not Found")
-	log.Println("  GET/POST /test-routes/slow - Takes 2 seconds, returns 200 OK")
-	log.Println("  GET/POST/PUT/DELETE /test-routes/echo - Returns request data")
+	slog.Info("test routes registered", "routes", []string{
+		"GET/POST/PUT/DELETE /test-routes/success",
+		"GET/POST /test-routes/error",
+		"GET/POST /test-routes/notfound",
+		"GET/POST /test-routes/slow",
+		"GET/POST/PUT/DELETE /test-routes/echo",
+	})
 }
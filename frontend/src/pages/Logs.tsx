@@ -143,6 +143,8 @@ const LogDetailDialog = ({
         return "bg-green-500/20 text-green-400 border-green-500/30";
       case "failure":
         return "bg-red-500/20 text-red-400 border-red-500/30";
+      case "aborted":
+        return "bg-yellow-500/20 text-yellow-400 border-yellow-500/30";
       default:
         return "bg-neutral-500/20 text-neutral-400 border-neutral-500/30";
     }
@@ -474,6 +476,8 @@ export default function Logs() {
         return "bg-green-500/20 text-green-400 border-green-500/30";
       case "failure":
         return "bg-red-500/20 text-red-400 border-red-500/30";
+      case "aborted":
+        return "bg-yellow-500/20 text-yellow-400 border-yellow-500/30";
       default:
         return "bg-neutral-500/20 text-neutral-400 border-neutral-500/30";
     }